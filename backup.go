@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var restoreForce bool
+
+var backupCmd = &cobra.Command{
+	Use:   "backup <file>",
+	Short: "Create archive of datastore",
+	Long: `Create archive of datastore with positions of all collection partitions.
+Datastore will be opened directly, so the service should not be running with the same datastore.
+Use BACKUP RPC to create archive while service is running`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBackup(args[0])
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Rebuild datastore from archive",
+	Long: `Rebuild datastore from archive which was created by backup.
+Consumers of all partitions will resume from positions of backup when service starts`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRestore(args[0])
+	},
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreForce, "force", false, "Replace existing datastore")
+
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func runBackup(filename string) error {
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	manifest, err := snapshot.BackupDatastore(w, config.Datastore.Path, config.Gravity.Domain)
+	if err != nil {
+		os.Remove(filename)
+		return err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Created backup of %d collections to %s\n", len(manifest.Collections), filename)
+
+	return nil
+}
+
+func runRestore(filename string) error {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, err := snapshot.RestoreDatastore(bufio.NewReader(f), config.Datastore.Path, restoreForce)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Restored %d collections from backup which was created at %s\n", len(manifest.Collections), manifest.CreatedAt)
+
+	for name, state := range manifest.Collections {
+		for partition, seq := range state.Partitions {
+			fmt.Fprintf(os.Stderr, "    %s partition %d resumes after sequence %d\n", name, partition, seq)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/bench"
+	"github.com/spf13/cobra"
+)
+
+var benchWorkload = bench.NewWorkload()
+var benchCollection string
+var benchEmbedded bool
+var benchRate int
+var benchTimeout int
+var benchFormat string
+var benchLogLevel string
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure throughput of applying synthetic events",
+	Long: `Generate synthetic events against embedded JetStream server or Gravity Network of config, then report
+end-to-end apply throughput, latency percentiles and growth of datastore with the current snapshot.workerCount and snapshot.workerBufferSize.
+Snapshot is written to temporary datastore, stream of collection is created by benchmark and deleted afterwards`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		// Use all partitions by default
+		if !cmd.Flags().Changed("partitions") {
+			benchWorkload.Partitions = config.Snapshot.PartitionCount
+		}
+
+		return runBench()
+	},
+}
+
+func init() {
+	benchCmd.Flags().StringVar(&benchCollection, "collection", bench.DefaultCollection, "Specify collection which events are published to, its stream should not exist")
+	benchCmd.Flags().BoolVar(&benchEmbedded, "embedded", true, "Run embedded JetStream server, otherwise Gravity Network of config is used")
+	benchCmd.Flags().IntVar(&benchRate, "rate", 0, "Specify the maximum number of events published per second, zero for no limit")
+	benchCmd.Flags().IntVar(&benchTimeout, "timeout", bench.DefaultTimeout, "Specify seconds to wait for events to be applied")
+	benchCmd.Flags().StringVar(&benchFormat, "format", "text", "Specify output format (text, json)")
+	benchCmd.Flags().StringVar(&benchLogLevel, "log-level", "error", "Specify log level of service while benchmark is running")
+
+	benchCmd.Flags().IntVar(&benchWorkload.Events, "events", bench.DefaultEvents, "Specify number of events")
+	benchCmd.Flags().IntVar(&benchWorkload.Keys, "keys", bench.DefaultKeys, "Specify cardinality of primary keys")
+	benchCmd.Flags().IntVar(&benchWorkload.Inserts, "inserts", bench.DefaultInserts, "Specify weight of insert events")
+	benchCmd.Flags().IntVar(&benchWorkload.Updates, "updates", bench.DefaultUpdates, "Specify weight of update events")
+	benchCmd.Flags().IntVar(&benchWorkload.Deletes, "deletes", bench.DefaultDeletes, "Specify weight of delete events")
+	benchCmd.Flags().IntVar(&benchWorkload.Fields, "fields", bench.DefaultFields, "Specify number of string fields of payload")
+	benchCmd.Flags().IntVar(&benchWorkload.FieldSize, "field-size", bench.DefaultFieldSize, "Specify length of string fields and array elements")
+	benchCmd.Flags().IntVar(&benchWorkload.Depth, "depth", bench.DefaultDepth, "Specify depth of nested maps in payload")
+	benchCmd.Flags().IntVar(&benchWorkload.ArrayLength, "array-length", bench.DefaultArrayLength, "Specify length of array in payload and nested maps")
+	benchCmd.Flags().IntVar(&benchWorkload.Partitions, "partitions", 0, "Specify number of partitions which events are distributed to (default to snapshot.partitionCount)")
+	benchCmd.Flags().Int64Var(&benchWorkload.Seed, "seed", 1, "Specify seed of random generator")
+
+	rootCmd.AddCommand(benchCmd)
+}
+
+func runBench() error {
+
+	if benchFormat != "text" && benchFormat != "json" {
+		return fmt.Errorf("Unsupported format: %s", benchFormat)
+	}
+
+	benchWorkload.Table = benchCollection
+
+	// Logs are written to stdout as well as report, and logging too much affects results
+	config.Log.Level = benchLogLevel
+	err := config.Validate()
+	if err != nil {
+		return err
+	}
+
+	b, err := bench.NewBench(config, benchWorkload,
+		bench.WithCollection(benchCollection),
+		bench.WithEmbedded(benchEmbedded),
+		bench.WithRate(benchRate),
+		bench.WithTimeout(time.Duration(benchTimeout)*time.Second),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Benchmark can be interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Running benchmark with %d events of %d keys...\n", benchWorkload.Events, benchWorkload.Keys)
+
+	report, err := b.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	if benchFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	printBenchReport(report)
+
+	return nil
+}
+
+func printBenchReport(report *bench.Report) {
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	methods := make([]string, 0, len(report.Methods))
+	for method, count := range report.Methods {
+		methods = append(methods, fmt.Sprintf("%s=%d", method, count))
+	}
+	sort.Strings(methods)
+
+	wl := report.Workload
+	fmt.Fprintf(w, "Collection\t%s (partitions %d, embedded %t)\n", report.Collection, wl.Partitions, report.Embedded)
+	fmt.Fprintf(w, "Workers\tcount %d, buffer size %d\n", report.WorkerCount, report.WorkerBufferSize)
+	fmt.Fprintf(w, "Workload\t%d events, %d keys, %v\n", wl.Events, wl.Keys, methods)
+	fmt.Fprintf(w, "Payload\t%d fields of %d bytes, depth %d, array length %d\n", wl.Fields, wl.FieldSize, wl.Depth, wl.ArrayLength)
+	fmt.Fprintf(w, "Published\t%.2fs (%.0f events/s)\n", report.PublishDuration, report.PublishRate)
+	fmt.Fprintf(w, "Applied\t%d events in %.2fs (%.0f events/s), %d skipped, %d errors\n", report.Applied, report.Duration, report.Throughput, report.Skipped, report.Errors)
+	fmt.Fprintf(w, "Latency\tp50 %.2fms, p90 %.2fms, p99 %.2fms, max %.2fms\n", report.Latency.P50, report.Latency.P90, report.Latency.P99, report.Latency.Max)
+	fmt.Fprintf(w, "Datastore\t%d -> %d bytes (+%d)\n", report.StoreSizeBefore, report.StoreSizeAfter, report.StoreGrowth)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print effective configuration",
+	Long: `Print effective configuration which is merged from defaults, config file and environment variables.
+Secrets are redacted. Configuration is printed even if it is invalid, problems are reported afterward`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+
+	// Config is loaded by command itself, so it can be printed even if it is invalid
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runConfigPrint()
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigPrint() error {
+
+	err := config.Load()
+
+	data, merr := yaml.Marshal(config.Redacted())
+	if merr != nil {
+		return merr
+	}
+
+	fmt.Fprint(os.Stdout, string(data))
+
+	return err
+}
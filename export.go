@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/exporter"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/inspector"
+	"github.com/spf13/cobra"
+)
+
+var exportTable string
+var exportFormat string
+var exportOutput string
+
+var exportCmd = &cobra.Command{
+	Use:   "export <collection>",
+	Short: "Export snapshot of collection to file",
+	Long: `Export snapshot of collection to JSON Lines, CSV or Parquet file.
+Datastore will be opened read-only, so the service should not be running with the same datastore`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExport(args[0])
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportTable, "table", "", "Specify table in collection (default to collection name)")
+	exportCmd.Flags().StringVar(&exportFormat, "format", exporter.FormatJSONL, "Specify output format (jsonl, csv, parquet)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Specify output file, \"-\" for stdout (default to <collection>.<format>)")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(collection string) error {
+
+	if len(exportTable) == 0 {
+		exportTable = collection
+	}
+
+	if len(exportOutput) == 0 {
+		exportOutput = fmt.Sprintf("%s.%s", collection, exportFormat)
+	}
+
+	ex, err := exporter.NewExporter(exportFormat)
+	if err != nil {
+		return err
+	}
+
+	// Datastore is opened read-only, so collection which does not exist will not be created
+	ins, err := inspector.Open(config.Datastore.Path)
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+
+	store, err := ins.GetStore(collection)
+	if err != nil {
+		return err
+	}
+
+	source := func(fn func(*gravity_sdk_types_record.Value) error) error {
+		return store.ScanPayloads(exportTable, fn)
+	}
+
+	// Write to stdout
+	if exportOutput == "-" {
+		w := bufio.NewWriter(os.Stdout)
+		_, err := ex.Export(source, w)
+		if err != nil {
+			return err
+		}
+
+		return w.Flush()
+	}
+
+	count, err := ex.ExportToFile(source, exportOutput)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d records to %s\n", count, exportOutput)
+
+	return nil
+}
@@ -5,17 +5,28 @@ go 1.15
 require (
 	github.com/BrobridgeOrg/EventStore v0.0.22
 	github.com/BrobridgeOrg/gravity-sdk v0.0.50
+	github.com/cockroachdb/pebble v0.0.0-20210831135706-8731fd6ed157
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/golang/protobuf v1.5.2
 	github.com/google/uuid v1.1.2
-	github.com/nats-io/nats-server v1.4.1
-	github.com/nats-io/nats-streaming-server v0.24.1
+	github.com/nats-io/nats-server/v2 v2.7.2
 	github.com/nats-io/nats.go v1.13.1-0.20220121202836-972a071d373d
-	github.com/sirupsen/logrus v1.8.1
+	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/procfs v0.7.3 // indirect
 	github.com/spf13/cobra v1.3.0
 	github.com/spf13/viper v1.10.1
+	github.com/xitongsys/parquet-go v1.6.2
+	go.opentelemetry.io/otel v1.3.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.3.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.3.0
+	go.opentelemetry.io/otel/sdk v1.3.0
+	go.opentelemetry.io/otel/trace v1.3.0
 	go.uber.org/fx v1.16.0
 	go.uber.org/zap v1.17.0
+	golang.org/x/sys v0.0.0-20220114195835-da31bd327af9 // indirect
 	google.golang.org/protobuf v1.27.1
+	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 replace github.com/BrobridgeOrg/gravity-sdk => ../gravity-sdk
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/importer"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var importTable string
+var importFormat string
+var importPrimaryKey string
+var importRevision uint64
+var importInferTypes bool
+
+var importCmd = &cobra.Command{
+	Use:   "import <collection> <file>",
+	Short: "Seed snapshot of collection from file",
+	Long: `Seed snapshot of collection from JSON Lines or CSV file.
+Records will be written at the specific revision, then consumers of collection will resume from the next stream sequence.
+Datastore will be opened directly, so the service should not be running with the same datastore`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runImport(args[0], args[1])
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importTable, "table", "", "Specify table in collection (default to collection name)")
+	importCmd.Flags().StringVar(&importFormat, "format", "", "Specify input format (jsonl, csv), default to extension of file")
+	importCmd.Flags().StringVar(&importPrimaryKey, "primary-key", "", "Specify primary key column")
+	importCmd.Flags().Uint64Var(&importRevision, "revision", 0, "Specify stream sequence which the seeded snapshot is matching")
+	importCmd.Flags().BoolVar(&importInferTypes, "infer-types", false, "Convert numbers and booleans in CSV file from strings")
+	importCmd.MarkFlagRequired("primary-key")
+
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImport(collection string, filename string) error {
+
+	if len(importTable) == 0 {
+		importTable = collection
+	}
+
+	if len(importFormat) == 0 {
+		importFormat = strings.TrimPrefix(filepath.Ext(filename), ".")
+	}
+
+	im, err := importer.NewImporter(importFormat,
+		importer.WithTable(importTable),
+		importer.WithPrimaryKey(importPrimaryKey),
+		importer.WithRevision(importRevision),
+		importer.WithTypeInference(importInferTypes),
+	)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	es, err := snapshot.OpenDatastore(config.Datastore.Path)
+	if err != nil {
+		return err
+	}
+	defer es.Close()
+
+	store, err := es.GetStore(collection)
+	if err != nil {
+		return err
+	}
+
+	result, err := im.Import(store, r)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %d records (skipped %d) at revision %d\n", result.Count, result.Skipped, importRevision)
+
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/inspector"
+	"github.com/spf13/cobra"
+)
+
+var inspectCollection string
+var inspectKeyType string
+var inspectPrefix string
+var inspectLimit int
+
+var collectionsCmd = &cobra.Command{
+	Use:   "collections",
+	Short: "List collections and tables in datastore",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runInspect(listCollections)
+	},
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <table> <primary key>",
+	Short: "Get record from datastore by primary key",
+	Long: `Get record from datastore by primary key.
+Primary key will be converted to integer automatically if no such record with string key, use --key-type to specify type explicitly`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runInspect(func(ins *inspector.Inspector) error {
+			return getRecord(ins, args[0], args[1])
+		})
+	},
+}
+
+var scanCmd = &cobra.Command{
+	Use:   "scan <table>",
+	Short: "Scan records of table in datastore",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runInspect(func(ins *inspector.Inspector) error {
+			return scanRecords(ins, args[0])
+		})
+	},
+}
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Dump all records in datastore as JSON Lines",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runInspect(dumpRecords)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{getCmd, scanCmd, dumpCmd} {
+		cmd.Flags().StringVar(&inspectCollection, "collection", "", "Specify collection (default to table name, or all collections for dump)")
+	}
+
+	getCmd.Flags().StringVar(&inspectKeyType, "key-type", "auto", "Specify type of primary key (auto, string, int64, uint64, hex)")
+	scanCmd.Flags().StringVar(&inspectPrefix, "prefix", "", "Specify prefix of primary key")
+	scanCmd.Flags().IntVar(&inspectLimit, "limit", 0, "Specify the maximum number of records, zero for no limit")
+
+	rootCmd.AddCommand(collectionsCmd, getCmd, scanCmd, dumpCmd)
+}
+
+func runInspect(fn func(*inspector.Inspector) error) error {
+
+	ins, err := inspector.Open(config.Datastore.Path)
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+
+	return fn(ins)
+}
+
+func listCollections(ins *inspector.Inspector) error {
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "COLLECTION\tTABLE\tCOUNT\tREVISION")
+
+	for _, name := range ins.Collections() {
+
+		store, err := ins.GetStore(name)
+		if err != nil {
+			return err
+		}
+
+		tables, err := store.Tables()
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", name, table.Name, table.Count, table.Revision)
+		}
+	}
+
+	return w.Flush()
+}
+
+func parseKey(key string, keyType string) ([][]byte, error) {
+
+	intKey := func(n uint64) []byte {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, n)
+		return buf
+	}
+
+	switch keyType {
+	case "string":
+		return [][]byte{[]byte(key)}, nil
+	case "int64":
+		n, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{intKey(uint64(n))}, nil
+	case "uint64":
+		n, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{intKey(n)}, nil
+	case "hex":
+		data, err := hex.DecodeString(strings.TrimPrefix(key, "0x"))
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{data}, nil
+	case "auto":
+		candidates := [][]byte{[]byte(key)}
+		if n, err := strconv.ParseInt(key, 10, 64); err == nil {
+			candidates = append(candidates, intKey(uint64(n)))
+		}
+		return candidates, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported key type: %s", keyType)
+}
+
+func getRecord(ins *inspector.Inspector, table string, key string) error {
+
+	collection := inspectCollection
+	if len(collection) == 0 {
+		collection = table
+	}
+
+	store, err := ins.GetStore(collection)
+	if err != nil {
+		return err
+	}
+
+	candidates, err := parseKey(key, inspectKeyType)
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range candidates {
+
+		data, err := store.Get(table, candidate)
+		if err != nil {
+			return err
+		}
+
+		if data == nil {
+			continue
+		}
+
+		record, err := inspector.RecordToMap(collection, table, candidate, data)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(record)
+	}
+
+	return fmt.Errorf("Not found record: %s", key)
+}
+
+func scanRecords(ins *inspector.Inspector, table string) error {
+
+	collection := inspectCollection
+	if len(collection) == 0 {
+		collection = table
+	}
+
+	store, err := ins.GetStore(collection)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	return writeRecords(w, store, table, []byte(inspectPrefix), inspectLimit)
+}
+
+func dumpRecords(ins *inspector.Inspector) error {
+
+	collections := ins.Collections()
+	if len(inspectCollection) > 0 {
+		collections = []string{inspectCollection}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	for _, name := range collections {
+
+		store, err := ins.GetStore(name)
+		if err != nil {
+			return err
+		}
+
+		tables, err := store.Tables()
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			err := writeRecords(w, store, table.Name, nil, 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeRecords(w io.Writer, store *inspector.Store, table string, prefix []byte, limit int) error {
+
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	return store.Scan(table, prefix, func(key []byte, data []byte) error {
+
+		if limit > 0 && count >= limit {
+			return io.EOF
+		}
+
+		count++
+
+		record, err := inspector.RecordToMap(store.GetName(), table, key, data)
+		if err != nil {
+			return err
+		}
+
+		return encoder.Encode(record)
+	})
+}
@@ -3,12 +3,8 @@ package main
 import (
 	"os"
 
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/app"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
-	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
-	"github.com/BrobridgeOrg/gravity-snapshot/pkg/logger"
-	"github.com/BrobridgeOrg/gravity-snapshot/pkg/rpc"
-	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
-	"github.com/BrobridgeOrg/gravity-snapshot/pkg/view_manager"
 	"github.com/spf13/cobra"
 
 	"go.uber.org/fx"
@@ -22,6 +18,13 @@ var rootCmd = &cobra.Command{
 	Short: "Gravity Component to store data snapshot of collection",
 	Long: `gravity-snapshot a component to manage collection snapshot.
 This application can be used to merge incoming events and store the latest data state`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+
+		// Arguments were accepted, usage is not helpful for errors of config
+		cmd.SilenceUsage = true
+
+		return config.Load()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		if err := run(); err != nil {
@@ -34,7 +37,7 @@ This application can be used to merge incoming events and store the latest data
 func init() {
 	config = configs.GetConfig()
 
-	rootCmd.Flags().StringSliceVar(&collections, "collections", []string{}, "Specify collections for watching")
+	rootCmd.Flags().StringSliceVar(&collections, "collections", []string{}, "Specify collections for watching (supports glob \"erp_*\" and regular expression \"/^erp_.+$/\")")
 }
 
 func main() {
@@ -49,17 +52,7 @@ func run() error {
 
 	config.AddCollections(collections)
 
-	fx.New(
-		fx.Supply(config),
-		fx.Provide(
-			logger.GetLogger,
-			connector.New,
-			snapshot.New,
-			view_manager.New,
-		),
-		fx.Invoke(rpc.New),
-		fx.NopLogger,
-	).Run()
+	fx.New(app.Options(config)).Run()
 
 	return nil
 }
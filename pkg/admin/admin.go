@@ -0,0 +1,189 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/http_server"
+	gravity_logger "github.com/BrobridgeOrg/gravity-snapshot/pkg/logger"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+const maxBodySize = 64 * 1024
+
+type LogLevelRequest struct {
+	Logger   string `json:"logger"`
+	Level    string `json:"level"`
+	Duration int    `json:"duration"`
+}
+
+type LogLevelReply struct {
+	Levels map[string]string           `json:"levels"`
+	Change *gravity_logger.LevelChange `json:"change,omitempty"`
+}
+
+type MergeDebugRequest struct {
+	Enabled    *bool  `json:"enabled"`
+	Collection string `json:"collection"`
+	PrimaryKey string `json:"primaryKey"`
+	Duration   int    `json:"duration"`
+}
+
+type MergeDebugReply struct {
+	MergeDebug *snapshot.MergeDebug `json:"mergeDebug"`
+}
+
+// Admin provides debug controls which can be changed at runtime, durations of requests are in seconds
+type Admin struct {
+	snapshot *snapshot.Snapshot
+}
+
+func New(config *configs.Config, l *zap.Logger, s *snapshot.Snapshot, server *http_server.HTTPServer) *Admin {
+
+	logger = l.Named("Admin")
+
+	a := &Admin{
+		snapshot: s,
+	}
+
+	if config.HTTP.Admin {
+		server.HandleFunc("/admin/log/level", serve(
+			func() interface{} {
+				return a.GetLogLevels()
+			},
+			func(data []byte) (interface{}, error) {
+				var req LogLevelRequest
+				err := json.Unmarshal(data, &req)
+				if err != nil {
+					return nil, err
+				}
+				return a.SetLogLevel(&req)
+			},
+		))
+
+		server.HandleFunc("/admin/debug/merge", serve(
+			func() interface{} {
+				return a.GetMergeDebug()
+			},
+			func(data []byte) (interface{}, error) {
+				var req MergeDebugRequest
+				err := json.Unmarshal(data, &req)
+				if err != nil {
+					return nil, err
+				}
+				return a.SetMergeDebug(&req)
+			},
+		))
+	}
+
+	return a
+}
+
+// GetLogLevels returns levels of all loggers, level with empty name is taken by loggers which have no level of their own
+func (a *Admin) GetLogLevels() *LogLevelReply {
+	return &LogLevelReply{
+		Levels: gravity_logger.GetLevels(),
+	}
+}
+
+// SetLogLevel changes level of logger, it will be reverted after duration unless duration is zero.
+// Levels are returned without changes if no level was specified.
+func (a *Admin) SetLogLevel(req *LogLevelRequest) (*LogLevelReply, error) {
+
+	if len(req.Level) == 0 {
+		return a.GetLogLevels(), nil
+	}
+
+	if req.Duration < 0 {
+		return nil, errors.New("Duration should not be negative")
+	}
+
+	change, err := gravity_logger.ChangeLevel(req.Logger, req.Level, time.Duration(req.Duration)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogLevelReply{
+		Levels: gravity_logger.GetLevels(),
+		Change: change,
+	}, nil
+}
+
+// GetMergeDebug returns record which is being debugged
+func (a *Admin) GetMergeDebug() *MergeDebugReply {
+	return &MergeDebugReply{
+		MergeDebug: a.snapshot.GetMergeDebug(),
+	}
+}
+
+// SetMergeDebug turns on or off logging of merge inputs and outputs for record of collection.
+// Record which is being debugged is returned without changes if enabled was not specified.
+func (a *Admin) SetMergeDebug(req *MergeDebugRequest) (*MergeDebugReply, error) {
+
+	if req.Enabled == nil {
+		return a.GetMergeDebug(), nil
+	}
+
+	if !*req.Enabled {
+		a.snapshot.ClearMergeDebug()
+		return a.GetMergeDebug(), nil
+	}
+
+	if len(req.Collection) == 0 || len(req.PrimaryKey) == 0 {
+		return nil, errors.New("Collection and primary key are required")
+	}
+
+	if req.Duration < 0 {
+		return nil, errors.New("Duration should not be negative")
+	}
+
+	md := a.snapshot.SetMergeDebug(req.Collection, req.PrimaryKey, time.Duration(req.Duration)*time.Second)
+
+	return &MergeDebugReply{
+		MergeDebug: md,
+	}, nil
+}
+
+// serve returns state for GET requests, and applies changes of body for POST and PUT requests
+func serve(get func() interface{}, set func([]byte) (interface{}, error)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var resp interface{}
+		status := http.StatusOK
+
+		switch r.Method {
+		case http.MethodGet:
+			resp = get()
+		case http.MethodPost, http.MethodPut:
+			data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize))
+			if err == nil {
+				resp, err = set(data)
+			}
+
+			if err != nil {
+				status = http.StatusBadRequest
+				resp = map[string]string{"error": err.Error()}
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST, PUT")
+			status = http.StatusMethodNotAllowed
+			resp = map[string]string{"error": "Method not allowed"}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+
+		err := json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
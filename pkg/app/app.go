@@ -0,0 +1,40 @@
+package app
+
+import (
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/admin"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/cluster"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/health"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/http_server"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/logger"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/metrics"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/reloader"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/rpc"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/tracing"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/view_manager"
+	"go.uber.org/fx"
+)
+
+// Options returns all modules of service, so the same application can be run by command and tests
+func Options(config *configs.Config) fx.Option {
+	return fx.Options(
+		fx.Supply(config),
+		fx.Provide(
+			logger.GetLogger,
+			metrics.NewRegistry,
+			reloader.New,
+			connector.New,
+			http_server.New,
+			snapshot.New,
+			view_manager.New,
+			health.New,
+			admin.New,
+			cluster.New,
+			cluster.NewElection,
+		),
+		fx.Invoke(tracing.New, rpc.New, metrics.New, logger.WatchConfig),
+		fx.NopLogger,
+	)
+}
@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteArchive writes manifest and all files in directory of datastore to gzipped tarball
+func WriteArchive(w io.Writer, manifest *Manifest, datastoreDir string) error {
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	// Manifest
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    ManifestFilename,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: manifest.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	if err != nil {
+		return err
+	}
+
+	// Datastore
+	err = filepath.Walk(datastoreDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(datastoreDir, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		header.Name = filepath.ToSlash(filepath.Join(DatastoreDirectory, rel))
+
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// ExtractArchive restores datastore from archive to specific directory and returns manifest
+func ExtractArchive(r io.Reader, datastoreDir string) (*Manifest, error) {
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	var manifest *Manifest
+
+	tr := tar.NewReader(gr)
+	for {
+
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Name == ManifestFilename {
+			manifest = &Manifest{}
+			err := json.NewDecoder(tr).Decode(manifest)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		prefix := DatastoreDirectory + "/"
+		if !strings.HasPrefix(header.Name, prefix) {
+			continue
+		}
+
+		// Prevent from writing files outside of datastore
+		target := filepath.Join(datastoreDir, filepath.Clean("/"+strings.TrimPrefix(header.Name, prefix)))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err := os.MkdirAll(target, os.ModePerm)
+			if err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			err := extractFile(tr, target, os.FileMode(header.Mode))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("Not found %s in archive", ManifestFilename)
+	}
+
+	return manifest, nil
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+
+	err := os.MkdirAll(filepath.Dir(target), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+
+	return err
+}
@@ -0,0 +1,39 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+)
+
+var ColumnFamilies = []string{
+	"events",
+	"states",
+	"snapshot",
+	"snapshot_states",
+}
+
+// CheckpointStore creates a consistent copy of all column families of store in specific directory
+func CheckpointStore(store *eventstore.Store, destDir string) error {
+
+	err := os.MkdirAll(destDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range ColumnFamilies {
+
+		cf, err := store.GetColumnFamailyHandle(name)
+		if err != nil {
+			return err
+		}
+
+		err = cf.Db.Checkpoint(filepath.Join(destDir, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package backup
+
+import (
+	"time"
+)
+
+const ManifestFilename = "manifest.json"
+const DatastoreDirectory = "datastore"
+
+type CollectionState struct {
+	Partitions map[uint64]uint64 `json:"partitions"`
+}
+
+type Manifest struct {
+	Domain      string                      `json:"domain"`
+	CreatedAt   time.Time                   `json:"createdAt"`
+	Collections map[string]*CollectionState `json:"collections"`
+}
+
+func NewManifest(domain string) *Manifest {
+	return &Manifest{
+		Domain:      domain,
+		CreatedAt:   time.Now(),
+		Collections: make(map[string]*CollectionState),
+	}
+}
+
+// SetPositions records the last applied stream sequence of each partition
+func (m *Manifest) SetPositions(collection string, positions map[uint64]uint64) {
+	m.Collections[collection] = &CollectionState{
+		Partitions: positions,
+	}
+}
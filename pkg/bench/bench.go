@@ -0,0 +1,485 @@
+// Package bench measures how fast the whole service applies synthetic events of collection to snapshot
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/app"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/fx"
+)
+
+const (
+	DefaultCollection = "bench"
+	DefaultTimeout    = 300
+)
+
+var ErrStreamExists = errors.New("Stream of collection already exists")
+
+type Bench struct {
+	config     *configs.Config
+	workload   *Workload
+	collection string
+	embedded   bool
+	rate       int
+	timeout    time.Duration
+	dir        string
+
+	server    *server.Server
+	app       *fx.App
+	connector *connector.Connector
+	snapshot  *snapshot.Snapshot
+	recorder  *recorder
+}
+
+// NewBench prepares benchmark with settings of config, datastore and collections are replaced
+// so benchmark never touches datastore of service
+func NewBench(config *configs.Config, workload *Workload, opts ...func(*Bench)) (*Bench, error) {
+
+	b := &Bench{
+		config:     config,
+		workload:   workload,
+		collection: DefaultCollection,
+		embedded:   true,
+		timeout:    DefaultTimeout * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	err := workload.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if workload.Partitions > config.Snapshot.PartitionCount {
+		return nil, fmt.Errorf("Number of partitions of workload is over snapshot.partitionCount: %d", config.Snapshot.PartitionCount)
+	}
+
+	return b, nil
+}
+
+// Run generates events of workload and waits until all of them were applied
+func (b *Bench) Run(ctx context.Context) (*Report, error) {
+
+	dir, err := ioutil.TempDir("", "gravity-snapshot-bench-")
+	if err != nil {
+		return nil, err
+	}
+	b.dir = dir
+	defer os.RemoveAll(dir)
+
+	if b.embedded {
+		err = b.startServer()
+		if err != nil {
+			return nil, err
+		}
+		defer b.stopServer()
+	}
+
+	b.prepareConfig()
+
+	err = b.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer b.stop()
+
+	js, err := b.connector.GetJetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	// Stream was created by benchmark, so it only contains events of benchmark
+	defer js.DeleteStream(snapshot.GetStreamName(b.config.Gravity.Domain, b.collection))
+
+	sizeBefore, err := getDirSize(b.config.Datastore.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	generator := NewGenerator(b.workload)
+	published, err := b.publish(ctx, js, generator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Waiting for events to be applied
+	select {
+	case <-b.recorder.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(b.timeout):
+		return nil, fmt.Errorf("Timeout waiting for events to be applied: %d/%d", b.recorder.getCount(), b.workload.Events)
+	}
+
+	sizeAfter, err := getDirSize(b.config.Datastore.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := b.recorder.report()
+	report.Collection = b.collection
+	report.Embedded = b.embedded
+	report.Workload = b.workload
+	report.Methods = generator.GetCounts()
+	report.WorkerCount = b.config.Snapshot.WorkerCount
+	report.WorkerBufferSize = b.config.Snapshot.WorkerBufferSize
+	report.PublishDuration = published.Seconds()
+	report.PublishRate = float64(b.workload.Events) / published.Seconds()
+	report.StoreSizeBefore = sizeBefore
+	report.StoreSizeAfter = sizeAfter
+	report.StoreGrowth = sizeAfter - sizeBefore
+
+	return report, nil
+}
+
+func (b *Bench) startServer() error {
+
+	opts := &server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  filepath.Join(b.dir, "jetstream"),
+		NoLog:     true,
+		NoSigs:    true,
+	}
+
+	s, err := server.NewServer(opts)
+	if err != nil {
+		return err
+	}
+
+	go s.Start()
+
+	if !s.ReadyForConnections(10 * time.Second) {
+		s.Shutdown()
+		return errors.New("Embedded JetStream server is not ready")
+	}
+
+	b.server = s
+
+	return nil
+}
+
+func (b *Bench) stopServer() {
+	b.server.Shutdown()
+	b.server.WaitForShutdown()
+}
+
+func (b *Bench) prepareConfig() {
+
+	config := b.config
+
+	if b.embedded {
+		config.Gravity.Host = "127.0.0.1"
+		config.Gravity.Port = b.server.Addr().(*net.TCPAddr).Port
+		config.Gravity.Servers = []string{}
+		config.Gravity.TLS = configs.TLSConfig{}
+		config.Gravity.Auth = configs.AuthConfig{}
+	}
+
+	// Only collection of benchmark is consumed by standalone instance
+	config.Datastore.Path = filepath.Join(b.dir, "data")
+	config.Collections = []string{b.collection}
+	config.Collection = map[string]*configs.CollectionConfig{
+		b.collection: {
+			StartPosition: configs.StartFirst,
+		},
+	}
+	config.Cluster.Enabled = false
+	config.HA.Enabled = false
+	config.HTTP.Enabled = false
+	config.Reload.Watch = false
+	config.Tracing.Enabled = false
+}
+
+func (b *Bench) start(ctx context.Context) error {
+
+	b.recorder = newRecorder(b.collection, b.workload.Events)
+
+	// Stream is checked before collection is registered by snapshot, because hook of checking is appended right
+	// after hook of connector
+	b.app = fx.New(
+		fx.Invoke(b.assertNoStream),
+		app.Options(b.config),
+		fx.Populate(&b.connector, &b.snapshot),
+	)
+
+	err := b.app.Err()
+	if err != nil {
+		return err
+	}
+
+	b.snapshot.WatchApplied(b.recorder.record)
+
+	return b.app.Start(ctx)
+}
+
+// assertNoStream makes sure stream of collection is created by benchmark, so events of benchmark are not mixed up with
+// events of existing collection and only stream of benchmark is deleted afterwards
+func (b *Bench) assertNoStream(lifecycle fx.Lifecycle, c *connector.Connector) {
+	lifecycle.Append(
+		fx.Hook{
+			OnStart: func(context.Context) error {
+
+				js, err := c.GetJetStream()
+				if err != nil {
+					return err
+				}
+
+				streamName := snapshot.GetStreamName(b.config.Gravity.Domain, b.collection)
+				_, err = js.StreamInfo(streamName)
+				if err == nil {
+					return fmt.Errorf("%w: %s", ErrStreamExists, streamName)
+				}
+
+				if err != nats.ErrStreamNotFound {
+					return err
+				}
+
+				return nil
+			},
+		},
+	)
+}
+
+func (b *Bench) stop() {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	b.app.Stop(ctx)
+}
+
+// publish sends events of workload asynchronously, it returns time taken to publish all events
+func (b *Bench) publish(ctx context.Context, js nats.JetStreamContext, generator *Generator) (time.Duration, error) {
+
+	prefix := snapshot.GetSubjectPrefix(b.config.Gravity.Domain, b.collection)
+
+	var interval time.Duration
+	if b.rate > 0 {
+		interval = time.Second / time.Duration(b.rate)
+	}
+
+	start := time.Now()
+	b.recorder.start(start)
+
+	for i := 0; i < b.workload.Events; i++ {
+
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+
+		// Limiting rate of events
+		if interval > 0 {
+			if wait := time.Until(start.Add(time.Duration(i) * interval)); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		event, err := generator.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		data, err := gravity_sdk_types_record.Marshal(event.Record)
+		if err != nil {
+			return 0, err
+		}
+
+		subject := fmt.Sprintf("%s.%d.EVENT.%s", prefix, event.Partition, event.Record.EventName)
+		_, err = js.PublishAsync(subject, data)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	select {
+	case <-js.PublishAsyncComplete():
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	return time.Since(start), nil
+}
+
+// getDirSize returns total size of files in directory
+func getDirSize(dir string) (int64, error) {
+
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	return size, err
+}
+
+// recorder collects latencies of events which were applied to snapshot
+type recorder struct {
+	collection string
+	expected   int
+	latencies  []time.Duration
+	skipped    int
+	errors     int
+	startedAt  time.Time
+	lastAt     time.Time
+	done       chan struct{}
+	mutex      sync.Mutex
+}
+
+func newRecorder(collection string, expected int) *recorder {
+	return &recorder{
+		collection: collection,
+		expected:   expected,
+		latencies:  make([]time.Duration, 0, expected),
+		done:       make(chan struct{}),
+	}
+}
+
+func (r *recorder) start(t time.Time) {
+	r.mutex.Lock()
+	r.startedAt = t
+	r.mutex.Unlock()
+}
+
+func (r *recorder) record(event *snapshot.AppliedEvent) {
+
+	if event.Collection != r.collection {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.latencies) >= r.expected {
+		return
+	}
+
+	r.latencies = append(r.latencies, event.AppliedAt.Sub(event.EventTime))
+	r.lastAt = event.AppliedAt
+
+	if _, ok := event.Err.(*snapshot.SkipError); ok {
+		r.skipped++
+	} else if event.Err != nil {
+		r.errors++
+	}
+
+	if len(r.latencies) == r.expected {
+		close(r.done)
+	}
+}
+
+func (r *recorder) getCount() int {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.latencies)
+}
+
+func (r *recorder) report() *Report {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	latencies := make([]time.Duration, len(r.latencies))
+	copy(latencies, r.latencies)
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	duration := r.lastAt.Sub(r.startedAt).Seconds()
+
+	report := &Report{
+		Applied:    len(latencies),
+		Skipped:    r.skipped,
+		Errors:     r.errors,
+		Duration:   duration,
+		Throughput: float64(len(latencies)) / duration,
+		Latency: &LatencyReport{
+			P50: percentile(latencies, 0.50),
+			P90: percentile(latencies, 0.90),
+			P99: percentile(latencies, 0.99),
+			Max: percentile(latencies, 1),
+		},
+	}
+
+	return report
+}
+
+// percentile returns latency in milliseconds by nearest rank
+func percentile(sorted []time.Duration, p float64) float64 {
+
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	// Rank is the smallest one which covers p of samples, error of floating point such as 0.07*100 is ignored
+	idx := int(math.Ceil(p*float64(len(sorted))-1e-9)) - 1
+	if idx < 0 {
+		idx = 0
+	}
+
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+
+	return float64(sorted[idx]) / float64(time.Millisecond)
+}
+
+func WithCollection(collection string) func(*Bench) {
+	return func(b *Bench) {
+		b.collection = collection
+	}
+}
+
+// WithEmbedded runs benchmark against embedded JetStream server instead of Gravity Network of config
+func WithEmbedded(embedded bool) func(*Bench) {
+	return func(b *Bench) {
+		b.embedded = embedded
+	}
+}
+
+// WithRate limits number of events published per second, zero for no limit
+func WithRate(rate int) func(*Bench) {
+	return func(b *Bench) {
+		b.rate = rate
+	}
+}
+
+// WithTimeout specifies how long to wait for events to be applied after published
+func WithTimeout(timeout time.Duration) func(*Bench) {
+	return func(b *Bench) {
+		b.timeout = timeout
+	}
+}
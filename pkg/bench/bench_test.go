@@ -0,0 +1,169 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestPercentile(t *testing.T) {
+
+	if v := percentile(nil, 0.5); v != 0 {
+		t.Fatalf("Percentile of no samples should be 0, got %v", v)
+	}
+
+	samples := make([]time.Duration, 100)
+	for i := range samples {
+		samples[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	// Nearest rank is ceil(p * n)
+	cases := map[float64]float64{
+		0:     1,
+		0.01:  1,
+		0.07:  7,
+		0.5:   50,
+		0.505: 51,
+		0.9:   90,
+		0.99:  99,
+		1:     100,
+	}
+
+	for p, expected := range cases {
+		if v := percentile(samples, p); v != expected {
+			t.Errorf("Percentile %v should be %v, got %v", p, expected, v)
+		}
+	}
+
+	few := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}
+	for p, expected := range map[float64]float64{0.33: 10, 0.34: 20, 0.5: 20, 0.67: 30, 1: 30} {
+		if v := percentile(few, p); v != expected {
+			t.Errorf("Percentile %v of 3 samples should be %v, got %v", p, expected, v)
+		}
+	}
+}
+
+// startServer runs JetStream server which is not owned by benchmark
+func startServer(t *testing.T) (*server.Server, nats.JetStreamContext) {
+
+	t.Helper()
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatal("JetStream server is not ready")
+	}
+
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.Shutdown()
+		s.WaitForShutdown()
+	})
+
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, js
+}
+
+func newTestBench(t *testing.T, s *server.Server, collection string) *Bench {
+
+	t.Helper()
+
+	config := configs.NewDefaultConfig()
+	config.Gravity.Host = "127.0.0.1"
+	config.Gravity.Port = s.Addr().(*net.TCPAddr).Port
+	config.Snapshot.PartitionCount = 4
+	config.Log.Level = "error"
+
+	w := NewWorkload()
+	w.Table = collection
+	w.Events = 100
+	w.Keys = 10
+	w.Partitions = 4
+
+	b, err := NewBench(config, w, WithCollection(collection), WithEmbedded(false), WithTimeout(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestBenchKeepsExistingStream(t *testing.T) {
+
+	s, js := startServer(t)
+
+	// Stream of collection which is not owned by benchmark, even empty one is kept
+	streamName := snapshot.GetStreamName(configs.NewDefaultConfig().Gravity.Domain, "orders")
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     streamName,
+		Subjects: []string{streamName + ".>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = newTestBench(t, s, "orders").Run(context.Background())
+	if !errors.Is(err, ErrStreamExists) {
+		t.Fatalf("Benchmark should fail with existing stream, got %v", err)
+	}
+
+	if _, err := js.StreamInfo(streamName); err != nil {
+		t.Fatalf("Existing stream was touched by benchmark: %v", err)
+	}
+
+	names := make([]string, 0)
+	for info := range js.ConsumersInfo(streamName) {
+		names = append(names, info.Name)
+	}
+
+	if len(names) > 0 {
+		t.Fatalf("Consumers were created for existing stream: %v", names)
+	}
+}
+
+func TestBenchDeletesOwnStream(t *testing.T) {
+
+	s, js := startServer(t)
+
+	report, err := newTestBench(t, s, "bench").Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Workload.Events != 100 {
+		t.Fatalf("Unexpected workload of report: %+v", report.Workload)
+	}
+
+	streamName := snapshot.GetStreamName(configs.NewDefaultConfig().Gravity.Domain, "bench")
+	if _, err := js.StreamInfo(streamName); err != nats.ErrStreamNotFound {
+		t.Fatalf("Stream of benchmark should be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package bench
+
+// LatencyReport contains percentiles of time taken from event stored in stream to applied to snapshot, in milliseconds
+type LatencyReport struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+type Report struct {
+	Collection       string         `json:"collection"`
+	Embedded         bool           `json:"embedded"`
+	Workload         *Workload      `json:"workload"`
+	Methods          map[string]int `json:"methods"`
+	WorkerCount      int            `json:"workerCount"`
+	WorkerBufferSize int            `json:"workerBufferSize"`
+
+	// Publishing events of workload
+	PublishDuration float64 `json:"publishSeconds"`
+	PublishRate     float64 `json:"publishRate"`
+
+	// From the first event was published to the last event was applied, skipped events are counted as applied
+	Applied    int            `json:"applied"`
+	Skipped    int            `json:"skipped"`
+	Errors     int            `json:"errors"`
+	Duration   float64        `json:"durationSeconds"`
+	Throughput float64        `json:"throughput"`
+	Latency    *LatencyReport `json:"latencyMilliseconds"`
+
+	// Size of datastore, including write-ahead log
+	StoreSizeBefore int64 `json:"storeSizeBefore"`
+	StoreSizeAfter  int64 `json:"storeSizeAfter"`
+	StoreGrowth     int64 `json:"storeGrowth"`
+}
@@ -0,0 +1,213 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+)
+
+const (
+	DefaultEvents      = 100000
+	DefaultKeys        = 10000
+	DefaultInserts     = 20
+	DefaultUpdates     = 70
+	DefaultDeletes     = 10
+	DefaultFields      = 10
+	DefaultFieldSize   = 16
+	DefaultDepth       = 0
+	DefaultArrayLength = 0
+)
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Workload describes synthetic events which are generated for benchmark, weights of methods are relative
+type Workload struct {
+	Table       string `json:"table"`
+	Events      int    `json:"events"`
+	Keys        int    `json:"keys"`
+	Inserts     int    `json:"inserts"`
+	Updates     int    `json:"updates"`
+	Deletes     int    `json:"deletes"`
+	Fields      int    `json:"fields"`
+	FieldSize   int    `json:"fieldSize"`
+	Depth       int    `json:"depth"`
+	ArrayLength int    `json:"arrayLength"`
+	Partitions  int    `json:"partitions"`
+	Seed        int64  `json:"seed"`
+}
+
+func NewWorkload() *Workload {
+	return &Workload{
+		Table:       "bench",
+		Events:      DefaultEvents,
+		Keys:        DefaultKeys,
+		Inserts:     DefaultInserts,
+		Updates:     DefaultUpdates,
+		Deletes:     DefaultDeletes,
+		Fields:      DefaultFields,
+		FieldSize:   DefaultFieldSize,
+		Depth:       DefaultDepth,
+		ArrayLength: DefaultArrayLength,
+		Partitions:  1,
+		Seed:        1,
+	}
+}
+
+func (w *Workload) Validate() error {
+
+	if len(w.Table) == 0 {
+		return errors.New("Table is required")
+	}
+
+	if w.Events <= 0 {
+		return fmt.Errorf("Number of events should be greater than 0: %d", w.Events)
+	}
+
+	if w.Keys <= 0 {
+		return fmt.Errorf("Number of keys should be greater than 0: %d", w.Keys)
+	}
+
+	if w.Inserts < 0 || w.Updates < 0 || w.Deletes < 0 || w.Inserts+w.Updates+w.Deletes == 0 {
+		return fmt.Errorf("Invalid mix of methods: %d:%d:%d", w.Inserts, w.Updates, w.Deletes)
+	}
+
+	if w.Fields < 0 || w.FieldSize < 0 || w.Depth < 0 || w.ArrayLength < 0 {
+		return errors.New("Shape of payload cannot be negative")
+	}
+
+	if w.Partitions <= 0 {
+		return fmt.Errorf("Number of partitions should be greater than 0: %d", w.Partitions)
+	}
+
+	return nil
+}
+
+// Event is record which was generated with partition it belongs to
+type Event struct {
+	Partition uint64
+	Record    *gravity_sdk_types_record.Record
+}
+
+// Generator creates events of workload, the same seed always generates the same sequence of events
+type Generator struct {
+	workload *Workload
+	random   *rand.Rand
+	counts   map[gravity_sdk_types_record.Method]int
+}
+
+func NewGenerator(workload *Workload) *Generator {
+	return &Generator{
+		workload: workload,
+		random:   rand.New(rand.NewSource(workload.Seed)),
+		counts:   make(map[gravity_sdk_types_record.Method]int),
+	}
+}
+
+// GetCounts returns number of events which were generated for each method
+func (g *Generator) GetCounts() map[string]int {
+
+	counts := make(map[string]int, len(g.counts))
+	for method, count := range g.counts {
+		counts[method.String()] = count
+	}
+
+	return counts
+}
+
+// Next generates event for random key of workload
+func (g *Generator) Next() (*Event, error) {
+
+	w := g.workload
+	key := int64(g.random.Intn(w.Keys))
+	method := g.nextMethod()
+
+	data := map[string]interface{}{
+		"id": key,
+	}
+
+	// Only primary key is required for delete events
+	if method != gravity_sdk_types_record.Method_DELETE {
+		g.fill(data, w.Depth)
+	}
+
+	record := &gravity_sdk_types_record.Record{
+		EventName:  w.Table,
+		Table:      w.Table,
+		Method:     method,
+		PrimaryKey: "id",
+	}
+
+	err := gravity_sdk_types_record.UnmarshalMapData(data, record)
+	if err != nil {
+		return nil, err
+	}
+
+	g.counts[method]++
+
+	return &Event{
+		Partition: getPartition(key, w.Partitions),
+		Record:    record,
+	}, nil
+}
+
+func (g *Generator) nextMethod() gravity_sdk_types_record.Method {
+
+	w := g.workload
+	n := g.random.Intn(w.Inserts + w.Updates + w.Deletes)
+
+	switch {
+	case n < w.Inserts:
+		return gravity_sdk_types_record.Method_INSERT
+	case n < w.Inserts+w.Updates:
+		return gravity_sdk_types_record.Method_UPDATE
+	}
+
+	return gravity_sdk_types_record.Method_DELETE
+}
+
+// fill adds fields of payload, nested maps are added until depth was reached
+func (g *Generator) fill(data map[string]interface{}, depth int) {
+
+	w := g.workload
+
+	for i := 0; i < w.Fields; i++ {
+		data[fmt.Sprintf("field%d", i)] = g.randomString(w.FieldSize)
+	}
+
+	if w.ArrayLength > 0 {
+		items := make([]interface{}, w.ArrayLength)
+		for i := range items {
+			items[i] = g.randomString(w.FieldSize)
+		}
+
+		data["items"] = items
+	}
+
+	if depth > 0 {
+		nested := make(map[string]interface{})
+		g.fill(nested, depth-1)
+		data["nested"] = nested
+	}
+}
+
+func (g *Generator) randomString(size int) string {
+
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = letters[g.random.Intn(len(letters))]
+	}
+
+	return string(b)
+}
+
+// getPartition distributes keys to partitions, events of the same key always go to the same partition
+func getPartition(key int64, partitions int) uint64 {
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d", key)
+
+	return uint64(h.Sum32()) % uint64(partitions)
+}
@@ -0,0 +1,198 @@
+package bench
+
+import (
+	"reflect"
+	"testing"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+)
+
+// equalEvents compares events by content, because fields of payload are kept in order of map iteration
+func equalEvents(a *Event, b *Event) bool {
+	return a.Partition == b.Partition &&
+		a.Record.Method == b.Record.Method &&
+		reflect.DeepEqual(snapshot.GetValue(a.Record.GetPayload()), snapshot.GetValue(b.Record.GetPayload()))
+}
+
+func TestWorkloadValidate(t *testing.T) {
+
+	if err := NewWorkload().Validate(); err != nil {
+		t.Fatalf("Default workload should be valid: %v", err)
+	}
+
+	cases := map[string]func(*Workload){
+		"table":      func(w *Workload) { w.Table = "" },
+		"events":     func(w *Workload) { w.Events = 0 },
+		"keys":       func(w *Workload) { w.Keys = -1 },
+		"no methods": func(w *Workload) { w.Inserts, w.Updates, w.Deletes = 0, 0, 0 },
+		"method":     func(w *Workload) { w.Deletes = -1 },
+		"fields":     func(w *Workload) { w.Fields = -1 },
+		"depth":      func(w *Workload) { w.Depth = -1 },
+		"partitions": func(w *Workload) { w.Partitions = 0 },
+	}
+
+	for name, fn := range cases {
+		w := NewWorkload()
+		fn(w)
+		if err := w.Validate(); err == nil {
+			t.Errorf("Workload with invalid %s should be rejected", name)
+		}
+	}
+}
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+
+	w := NewWorkload()
+	w.Partitions = 8
+	w.Depth = 1
+	w.ArrayLength = 2
+
+	a := NewGenerator(w)
+	b := NewGenerator(w)
+	for i := 0; i < 100; i++ {
+
+		ea, err := a.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		eb, err := b.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !equalEvents(ea, eb) {
+			t.Fatalf("Event %d differs with the same seed", i)
+		}
+	}
+
+	// Another seed generates another sequence
+	w2 := *w
+	w2.Seed = 2
+	c := NewGenerator(&w2)
+	d := NewGenerator(w)
+	same := 0
+	for i := 0; i < 100; i++ {
+		ec, _ := c.Next()
+		ed, _ := d.Next()
+		if equalEvents(ec, ed) {
+			same++
+		}
+	}
+
+	if same == 100 {
+		t.Fatal("Events are the same with different seeds")
+	}
+}
+
+func TestGeneratorMix(t *testing.T) {
+
+	w := NewWorkload()
+	w.Events = 10000
+	w.Keys = 100
+	w.Inserts, w.Updates, w.Deletes = 1, 2, 1
+	w.Partitions = 4
+
+	g := NewGenerator(w)
+	partitions := make(map[int64]uint64)
+	for i := 0; i < w.Events; i++ {
+
+		e, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		payload := snapshot.GetValue(e.Record.GetPayload()).(map[string]interface{})
+		key := payload["id"].(int64)
+		if key < 0 || key >= int64(w.Keys) {
+			t.Fatalf("Key %d is out of range", key)
+		}
+
+		if e.Partition >= uint64(w.Partitions) {
+			t.Fatalf("Partition %d is out of range", e.Partition)
+		}
+
+		// Events of the same key go to the same partition
+		if p, ok := partitions[key]; ok && p != e.Partition {
+			t.Fatalf("Key %d was sent to partitions %d and %d", key, p, e.Partition)
+		}
+		partitions[key] = e.Partition
+
+		// Delete events only carry primary key
+		if e.Record.Method == gravity_sdk_types_record.Method_DELETE && len(payload) != 1 {
+			t.Fatalf("Delete event has payload: %v", payload)
+		}
+	}
+
+	counts := g.GetCounts()
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	if total != w.Events {
+		t.Fatalf("Expected %d events counted, got %d", w.Events, total)
+	}
+
+	// Methods follow their weights
+	expected := map[string]float64{
+		gravity_sdk_types_record.Method_INSERT.String(): 0.25,
+		gravity_sdk_types_record.Method_UPDATE.String(): 0.5,
+		gravity_sdk_types_record.Method_DELETE.String(): 0.25,
+	}
+
+	for method, ratio := range expected {
+		actual := float64(counts[method]) / float64(total)
+		if actual < ratio-0.03 || actual > ratio+0.03 {
+			t.Errorf("Ratio of %s should be about %.2f, got %.3f", method, ratio, actual)
+		}
+	}
+}
+
+func TestGeneratorShape(t *testing.T) {
+
+	w := NewWorkload()
+	w.Deletes = 0
+	w.Fields = 3
+	w.FieldSize = 5
+	w.Depth = 2
+	w.ArrayLength = 4
+
+	e, err := NewGenerator(w).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Record.Table != w.Table || e.Record.PrimaryKey != "id" {
+		t.Fatalf("Unexpected record: %s, %s", e.Record.Table, e.Record.PrimaryKey)
+	}
+
+	payload := snapshot.GetValue(e.Record.GetPayload()).(map[string]interface{})
+	for depth := 0; depth <= w.Depth; depth++ {
+
+		for _, field := range []string{"field0", "field1", "field2"} {
+			if s, ok := payload[field].(string); !ok || len(s) != w.FieldSize {
+				t.Fatalf("Field %s at depth %d is invalid: %v", field, depth, payload[field])
+			}
+		}
+
+		if items, ok := payload["items"].([]interface{}); !ok || len(items) != w.ArrayLength {
+			t.Fatalf("Items at depth %d are invalid: %v", depth, payload["items"])
+		}
+
+		nested, ok := payload["nested"].(map[string]interface{})
+		if depth == w.Depth {
+			if ok {
+				t.Fatalf("Payload is nested deeper than %d", w.Depth)
+			}
+			break
+		}
+
+		if !ok {
+			t.Fatalf("Nested map at depth %d is missing", depth)
+		}
+
+		payload = nested
+	}
+}
@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+)
+
+var invalidKeyChars = regexp.MustCompile(`[^-/_=.a-zA-Z0-9]`)
+
+func sanitizeKey(key string) string {
+	return invalidKeyChars.ReplaceAllString(key, "_")
+}
+
+func getLeaseKey(collection string, partition uint64) string {
+	return fmt.Sprintf("%s.%d", sanitizeKey(collection), partition)
+}
+
+// getOwner returns node which partition should be assigned to by rendezvous hashing, so only a few partitions are moved when members were changed
+func getOwner(members []string, collection string, partition uint64) string {
+
+	owner := ""
+	max := uint64(0)
+	for _, member := range members {
+
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", member, collection, partition)))
+		score := binary.BigEndian.Uint64(sum[:8])
+
+		if len(owner) == 0 || score > max {
+			owner = member
+			max = score
+		}
+	}
+
+	return owner
+}
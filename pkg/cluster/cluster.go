@@ -0,0 +1,331 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+type NodeInfo struct {
+	ID       string    `json:"id"`
+	Hostname string    `json:"hostname"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+type Cluster struct {
+	connector *connector.Connector
+	snapshot  *snapshot.Snapshot
+	enabled   bool
+	node      *NodeInfo
+	leaseTTL  time.Duration
+	nodes     nats.KeyValue
+	leases    nats.KeyValue
+	members   []string
+	js        nats.JetStreamContext
+	owned     map[string]*lease
+	trigger   chan struct{}
+	closed    chan struct{}
+	done      chan struct{}
+	left      chan struct{}
+	mutex     sync.RWMutex
+	refreshMu sync.Mutex
+}
+
+func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *connector.Connector, s *snapshot.Snapshot) (*Cluster, error) {
+
+	logger = l.Named("Cluster")
+
+	cl := &Cluster{
+		connector: c,
+		snapshot:  s,
+		enabled:   config.Cluster.Enabled,
+		leaseTTL:  time.Duration(config.Cluster.LeaseTTL) * time.Second,
+		members:   make([]string, 0),
+		owned:     make(map[string]*lease),
+		trigger:   make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+		left:      make(chan struct{}),
+	}
+
+	if !cl.enabled {
+		return cl, nil
+	}
+
+	hostname, _ := os.Hostname()
+	nodeID, err := getNodeID(config.Cluster.NodeID, hostname, config.Datastore.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	cl.node = &NodeInfo{
+		ID:       nodeID,
+		Hostname: hostname,
+		JoinedAt: time.Now(),
+	}
+
+	// Partitions will not be consumed until leases were acquired
+	s.SetPartitionFilter(cl.IsOwned)
+
+	c.RequireKeyValue(getNodesBucket(c.GetDomain()))
+	c.RequireKeyValue(getLeasesBucket(c.GetDomain()))
+
+	lifecycle.Append(
+		fx.Hook{
+			OnStart: func(context.Context) error {
+				return cl.join()
+			},
+			OnStop: func(ctx context.Context) error {
+				return cl.leave()
+			},
+		},
+	)
+
+	return cl, nil
+}
+
+func getNodesBucket(domain string) string {
+	return fmt.Sprintf("GRAVITY_%s_SNAPSHOT_NODES", domain)
+}
+
+func getLeasesBucket(domain string) string {
+	return fmt.Sprintf("GRAVITY_%s_SNAPSHOT_LEASES", domain)
+}
+
+func (cl *Cluster) IsEnabled() bool {
+	return cl.enabled
+}
+
+func (cl *Cluster) GetNodeID() string {
+
+	if cl.node == nil {
+		return ""
+	}
+
+	return cl.node.ID
+}
+
+// GetMembers returns ID of all nodes which are alive
+func (cl *Cluster) GetMembers() []string {
+
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+
+	members := make([]string, len(cl.members))
+	copy(members, cl.members)
+
+	return members
+}
+
+// IsOwned returns true if lease of partition is held by this node
+func (cl *Cluster) IsOwned(collection string, partition uint64) bool {
+
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+
+	l, ok := cl.owned[getLeaseKey(collection, partition)]
+
+	return ok && !l.releasing
+}
+
+// GetOwnedPartitions returns partitions of collections which are owned by this node
+func (cl *Cluster) GetOwnedPartitions() map[string][]uint64 {
+
+	owned := make(map[string][]uint64)
+	for _, c := range cl.snapshot.GetCollections() {
+
+		partitions := make([]uint64, 0)
+		for _, partition := range c.GetPartitions() {
+			if cl.IsOwned(c.GetName(), partition) {
+				partitions = append(partitions, partition)
+			}
+		}
+
+		owned[c.GetName()] = partitions
+	}
+
+	return owned
+}
+
+func (cl *Cluster) join() error {
+
+	js, err := cl.connector.GetJetStream()
+	if err != nil {
+		return err
+	}
+
+	cl.js = js
+	domain := cl.connector.GetDomain()
+
+	cl.nodes, err = assertBucket(js, getNodesBucket(domain), cl.leaseTTL)
+	if err != nil {
+		return err
+	}
+
+	cl.leases, err = assertBucket(js, getLeasesBucket(domain), cl.leaseTTL)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Joining cluster",
+		zap.String("node", cl.node.ID),
+		zap.Duration("leaseTTL", cl.leaseTTL),
+	)
+
+	err = cl.heartbeat()
+	if err != nil {
+		return err
+	}
+
+	// Keep node and leases alive even if rebalancing takes a long time
+	go cl.keepAlive()
+	go cl.run()
+
+	return nil
+}
+
+func assertBucket(js nats.JetStreamContext, bucket string, ttl time.Duration) (nats.KeyValue, error) {
+
+	kv, err := js.KeyValue(bucket)
+	if err == nil {
+		return kv, nil
+	}
+
+	if err != nats.ErrBucketNotFound {
+		return nil, err
+	}
+
+	logger.Info("Creating bucket...", zap.String("bucket", bucket))
+
+	return js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket:  bucket,
+		History: 1,
+		TTL:     ttl,
+	})
+}
+
+func (cl *Cluster) heartbeat() error {
+
+	data, _ := json.Marshal(cl.node)
+	_, err := cl.nodes.Put(cl.node.ID, data)
+
+	return err
+}
+
+func (cl *Cluster) run() {
+
+	defer close(cl.done)
+
+	// Rebalance immediately when node joined or left
+	watcher, err := cl.nodes.WatchAll(nats.IgnoreDeletes())
+	if err != nil {
+		logger.Warn(err.Error())
+	} else {
+		defer watcher.Stop()
+		go func() {
+			for range watcher.Updates() {
+				cl.triggerRebalance()
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(cl.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+
+		cl.rebalance()
+
+		select {
+		case <-ticker.C:
+		case <-cl.trigger:
+		case <-cl.closed:
+			return
+		}
+	}
+}
+
+func (cl *Cluster) triggerRebalance() {
+	select {
+	case cl.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (cl *Cluster) keepAlive() {
+
+	ticker := time.NewTicker(cl.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-cl.left:
+			return
+		}
+
+		err := cl.heartbeat()
+		if err != nil {
+			logger.Error(err.Error())
+		}
+
+		cl.renewLeases()
+	}
+}
+
+func (cl *Cluster) updateMembers() ([]string, error) {
+
+	members, err := cl.nodes.Keys()
+	if err != nil && err != nats.ErrNoKeysFound {
+		return nil, err
+	}
+
+	sort.Strings(members)
+
+	cl.mutex.Lock()
+	changed := fmt.Sprint(cl.members) != fmt.Sprint(members)
+	cl.members = members
+	cl.mutex.Unlock()
+
+	if changed {
+		logger.Info("Members of cluster were changed", zap.Strings("members", members))
+	}
+
+	return members, nil
+}
+
+func (cl *Cluster) leave() error {
+
+	close(cl.closed)
+	<-cl.done
+
+	logger.Info("Leaving cluster", zap.String("node", cl.node.ID))
+
+	// Stop consuming all partitions before other nodes take them over
+	cl.mutex.Lock()
+	for _, l := range cl.owned {
+		l.releasing = true
+	}
+	cl.mutex.Unlock()
+
+	// Records are kept, so partitions continue from where they left off if they come back after restarting
+	cl.refresh(false)
+	cl.releaseLeases()
+
+	close(cl.left)
+
+	return cl.nodes.Delete(cl.node.ID)
+}
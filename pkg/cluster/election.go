@@ -0,0 +1,303 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/metrics"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const leaderKey = "leader"
+
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+// Election elects one of instances as leader, others stay in standby and take over when leader failed
+type Election struct {
+	connector *connector.Connector
+	enabled   bool
+	nodeID    string
+	leaseTTL  time.Duration
+	js        nats.JetStreamContext
+	kv        nats.KeyValue
+	revision  uint64
+	leader    string
+	handlers  []func(bool)
+	closed    chan struct{}
+	done      chan struct{}
+	mutex     sync.RWMutex
+}
+
+func NewElection(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *connector.Connector, m *metrics.Registry) (*Election, error) {
+
+	logger = l.Named("Cluster")
+
+	e := &Election{
+		connector: c,
+		enabled:   config.HA.Enabled,
+		leaseTTL:  time.Duration(config.HA.LeaseTTL) * time.Second,
+		handlers:  make([]func(bool), 0),
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if !e.enabled {
+		return e, nil
+	}
+
+	hostname, _ := os.Hostname()
+	nodeID, err := getNodeID(config.Cluster.NodeID, hostname, config.Datastore.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	e.nodeID = nodeID
+	c.RequireKeyValue(getElectionBucket(c.GetDomain()))
+
+	err = m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "leader",
+		Help:      "Whether this instance is the elected leader",
+	}, func() float64 {
+		if e.IsLeader() {
+			return 1
+		}
+		return 0
+	}))
+	if err != nil {
+		logger.Warn(err.Error())
+	}
+
+	lifecycle.Append(
+		fx.Hook{
+			OnStart: func(context.Context) error {
+				return e.join()
+			},
+			OnStop: func(ctx context.Context) error {
+				return e.resign()
+			},
+		},
+	)
+
+	return e, nil
+}
+
+func getElectionBucket(domain string) string {
+	return fmt.Sprintf("GRAVITY_%s_SNAPSHOT_ELECTION", domain)
+}
+
+func (e *Election) IsEnabled() bool {
+	return e.enabled
+}
+
+func (e *Election) GetNodeID() string {
+	return e.nodeID
+}
+
+// IsLeader returns true if this instance was elected, it is always true if election is disabled
+func (e *Election) IsLeader() bool {
+
+	if !e.enabled {
+		return true
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.leader == e.nodeID
+}
+
+// GetLeader returns ID of instance which is leader now
+func (e *Election) GetLeader() string {
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.leader
+}
+
+func (e *Election) GetRole() string {
+
+	if e.IsLeader() {
+		return RoleLeader
+	}
+
+	return RoleStandby
+}
+
+// Watch registers handler to be called when this instance became leader or standby
+func (e *Election) Watch(fn func(bool)) {
+
+	e.mutex.Lock()
+	e.handlers = append(e.handlers, fn)
+	isLeader := e.leader == e.nodeID
+	e.mutex.Unlock()
+
+	if isLeader {
+		fn(true)
+	}
+}
+
+func (e *Election) join() error {
+
+	js, err := e.connector.GetJetStream()
+	if err != nil {
+		return err
+	}
+
+	e.js = js
+	e.kv, err = assertBucket(js, getElectionBucket(e.connector.GetDomain()), e.leaseTTL)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Joining election",
+		zap.String("node", e.nodeID),
+		zap.Duration("leaseTTL", e.leaseTTL),
+	)
+
+	go e.run()
+
+	return nil
+}
+
+func (e *Election) run() {
+
+	defer close(e.done)
+
+	trigger := make(chan struct{}, 1)
+
+	// Campaign immediately when leader resigned
+	watcher, err := e.kv.Watch(leaderKey)
+	if err != nil {
+		logger.Warn(err.Error())
+	} else {
+		defer watcher.Stop()
+		go func() {
+			for entry := range watcher.Updates() {
+				if entry == nil || entry.Operation() == nats.KeyValuePut {
+					continue
+				}
+
+				select {
+				case trigger <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(e.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+
+		e.campaign()
+
+		select {
+		case <-ticker.C:
+		case <-trigger:
+		case <-e.closed:
+			return
+		}
+	}
+}
+
+func (e *Election) campaign() {
+
+	if e.IsLeader() {
+
+		revision, err := e.kv.Update(leaderKey, []byte(e.nodeID), e.revision)
+		if err == nil {
+			e.revision = revision
+			return
+		}
+
+		logger.Warn("Lost leadership", zap.Error(err))
+		e.setLeader("")
+	}
+
+	revision, err := e.kv.Create(leaderKey, []byte(e.nodeID))
+	if err == nil {
+		e.revision = revision
+		e.setLeader(e.nodeID)
+		return
+	}
+
+	// Getting current leader
+	entry, err := e.kv.Get(leaderKey)
+	if err != nil {
+		if err != nats.ErrKeyNotFound {
+			logger.Warn(err.Error())
+		}
+
+		e.setLeader("")
+		return
+	}
+
+	// Lease is still held by this node after restarting with the same ID, it is renewed with the current revision
+	leader := string(entry.Value())
+	if leader == e.nodeID {
+		e.revision = entry.Revision()
+	}
+
+	e.setLeader(leader)
+}
+
+func (e *Election) setLeader(leader string) {
+
+	e.mutex.Lock()
+	wasLeader := e.leader == e.nodeID
+	changed := e.leader != leader
+	e.leader = leader
+	handlers := make([]func(bool), len(e.handlers))
+	copy(handlers, e.handlers)
+	e.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	isLeader := leader == e.nodeID
+	if isLeader {
+		logger.Info("Elected as leader", zap.String("node", e.nodeID))
+	} else if len(leader) > 0 {
+		logger.Info("Running as standby", zap.String("leader", leader))
+	}
+
+	if wasLeader == isLeader {
+		return
+	}
+
+	for _, fn := range handlers {
+		fn(isLeader)
+	}
+}
+
+// resign steps down so standby instances take over immediately
+func (e *Election) resign() error {
+
+	close(e.closed)
+	<-e.done
+
+	if !e.IsLeader() {
+		return nil
+	}
+
+	e.setLeader("")
+
+	logger.Info("Resigning leadership", zap.String("node", e.nodeID))
+
+	return deleteKey(e.js, e.kv, leaderKey, e.revision)
+}
@@ -0,0 +1,193 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+type lease struct {
+	revision  uint64
+	releasing bool
+}
+
+// rebalance acquires leases of partitions which should be owned by this node, then releases others
+func (cl *Cluster) rebalance() {
+
+	members, err := cl.updateMembers()
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	changed := false
+	for _, c := range cl.snapshot.GetCollections() {
+		for _, partition := range c.GetPartitions() {
+
+			key := getLeaseKey(c.GetName(), partition)
+			desired := getOwner(members, c.GetName(), partition) == cl.node.ID
+
+			cl.mutex.Lock()
+			l, owned := cl.owned[key]
+			if !desired && owned && !l.releasing {
+				// Lease is still renewed until partition was no longer consumed
+				l.releasing = true
+				changed = true
+			}
+			cl.mutex.Unlock()
+
+			if desired && !owned {
+				changed = cl.acquireLease(key) || changed
+			}
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	cl.refreshMu.Lock()
+	defer cl.refreshMu.Unlock()
+
+	// Stop consuming partitions which were released before other nodes take them over
+	released := cl.snapshot.RefreshPartitions()
+	cl.releaseLeases()
+	cl.purge(released)
+}
+
+// refresh makes snapshot consume partitions which are owned by this node only, records of partitions which were
+// released are kept if purge is false
+func (cl *Cluster) refresh(purge bool) {
+
+	cl.refreshMu.Lock()
+	defer cl.refreshMu.Unlock()
+
+	released := cl.snapshot.RefreshPartitions()
+	if purge {
+		cl.purge(released)
+	}
+}
+
+// purge deletes records of partitions which were taken over by other nodes, partitions are not acquired again until
+// records were deleted because refreshing is locked by caller
+func (cl *Cluster) purge(released map[string][]uint64) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshot.DrainTimeout)
+	defer cancel()
+
+	for collection, partitions := range released {
+		for _, partition := range partitions {
+			_, err := cl.snapshot.PurgePartition(ctx, collection, partition)
+			if err != nil {
+				logger.Error(err.Error(),
+					zap.String("collection", collection),
+					zap.Uint64("partition", partition),
+				)
+			}
+		}
+	}
+}
+
+func (cl *Cluster) acquireLease(key string) bool {
+
+	revision, err := cl.leases.Create(key, []byte(cl.node.ID))
+	if err != nil {
+		// Lease is still held by another node
+		return false
+	}
+
+	logger.Info("Acquired partition", zap.String("lease", key))
+
+	cl.mutex.Lock()
+	cl.owned[key] = &lease{
+		revision: revision,
+	}
+	cl.mutex.Unlock()
+
+	return true
+}
+
+func (cl *Cluster) renewLeases() {
+
+	cl.mutex.Lock()
+	leases := make(map[string]uint64, len(cl.owned))
+	for key, l := range cl.owned {
+		leases[key] = l.revision
+	}
+	cl.mutex.Unlock()
+
+	lost := false
+	for key, revision := range leases {
+
+		newRevision, err := cl.leases.Update(key, []byte(cl.node.ID), revision)
+
+		cl.mutex.Lock()
+		l, owned := cl.owned[key]
+		if !owned || l.revision != revision {
+			// Lease was released in the meantime
+			cl.mutex.Unlock()
+			continue
+		}
+
+		if err == nil {
+			l.revision = newRevision
+			cl.mutex.Unlock()
+			continue
+		}
+
+		// Lease was expired and taken by another node
+		delete(cl.owned, key)
+		cl.mutex.Unlock()
+
+		logger.Warn("Lost partition",
+			zap.String("lease", key),
+			zap.Error(err),
+		)
+
+		lost = true
+	}
+
+	if lost {
+		cl.refresh(true)
+	}
+}
+
+// releaseLeases deletes leases which were marked as releasing
+func (cl *Cluster) releaseLeases() {
+
+	cl.mutex.Lock()
+	released := make(map[string]uint64)
+	for key, l := range cl.owned {
+		if l.releasing {
+			released[key] = l.revision
+			delete(cl.owned, key)
+		}
+	}
+	cl.mutex.Unlock()
+
+	for key, revision := range released {
+
+		logger.Info("Released partition", zap.String("lease", key))
+
+		err := deleteKey(cl.js, cl.leases, key, revision)
+		if err != nil {
+			logger.Warn(err.Error(), zap.String("lease", key))
+		}
+	}
+}
+
+// deleteKey deletes key only if it was not updated by another node since the given revision
+func deleteKey(js nats.JetStreamContext, kv nats.KeyValue, key string, revision uint64) error {
+
+	m := nats.NewMsg(fmt.Sprintf("$KV.%s.%s", kv.Bucket(), key))
+	m.Header.Set("KV-Operation", "DEL")
+	m.Header.Set(nats.ExpectedLastSubjSeqHdr, strconv.FormatUint(revision, 10))
+
+	_, err := js.PublishMsg(m)
+
+	return err
+}
@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// NodeIDFile is the file in datastore directory which keeps generated ID of node
+const NodeIDFile = "NODE_ID"
+
+var nodeIDMutex sync.Mutex
+
+// getNodeID returns ID which is specified by settings, otherwise ID is generated once and kept in datastore, so node has
+// the same ID after restarting
+func getNodeID(nodeID string, hostname string, datastorePath string) (string, error) {
+
+	if len(nodeID) > 0 {
+		return nodeID, nil
+	}
+
+	// Cluster and election of the same instance share the ID
+	nodeIDMutex.Lock()
+	defer nodeIDMutex.Unlock()
+
+	filename := filepath.Join(datastorePath, NodeIDFile)
+	data, err := ioutil.ReadFile(filename)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	id, _ := uuid.NewUUID()
+	nodeID = sanitizeKey(fmt.Sprintf("%s-%s", hostname, id.String()[:8]))
+
+	err = os.MkdirAll(datastorePath, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	err = ioutil.WriteFile(filename, []byte(nodeID+"\n"), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return nodeID, nil
+}
@@ -0,0 +1,125 @@
+package configs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Start positions of consumers of collection
+const (
+	StartNew      = "new"
+	StartFirst    = "first"
+	StartLast     = "last"
+	StartSequence = "sequence"
+	StartTime     = "time"
+)
+
+// Strategies of merging event into existing record
+const (
+	MergeDeep    = "merge"
+	MergeShallow = "shallow"
+	MergeReplace = "replace"
+)
+
+// Modes of handling delete events
+const (
+	TombstoneDelete = "delete"
+	TombstoneKeep   = "keep"
+)
+
+// CollectionConfig overrides how specific collection is consumed and applied to snapshot, durations are in seconds
+type CollectionConfig struct {
+	StartPosition string   `mapstructure:"startPosition" yaml:"startPosition,omitempty"`
+	Partitions    []uint64 `mapstructure:"partitions" yaml:"partitions,omitempty"`
+	MergeStrategy string   `mapstructure:"mergeStrategy" yaml:"mergeStrategy,omitempty"`
+	PrimaryKey    string   `mapstructure:"primaryKey" yaml:"primaryKey,omitempty"`
+	IncludeFields []string `mapstructure:"includeFields" yaml:"includeFields,omitempty"`
+	ExcludeFields []string `mapstructure:"excludeFields" yaml:"excludeFields,omitempty"`
+	Retention     int64    `mapstructure:"retention" yaml:"retention,omitempty"`
+	Tombstone     string   `mapstructure:"tombstone" yaml:"tombstone,omitempty"`
+	Workers       int      `mapstructure:"workers" yaml:"workers,omitempty"`
+}
+
+type StartPosition struct {
+	Mode     string
+	Sequence uint64
+	Time     time.Time
+}
+
+// ParseStartPosition parses "new", "first", "last", stream sequence or RFC3339 time
+func ParseStartPosition(position string) (*StartPosition, error) {
+
+	switch strings.ToLower(position) {
+	case "", StartNew:
+		return &StartPosition{Mode: StartNew}, nil
+	case StartFirst:
+		return &StartPosition{Mode: StartFirst}, nil
+	case StartLast:
+		return &StartPosition{Mode: StartLast}, nil
+	}
+
+	if seq, err := strconv.ParseUint(position, 10, 64); err == nil && seq > 0 {
+		return &StartPosition{Mode: StartSequence, Sequence: seq}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, position); err == nil {
+		return &StartPosition{Mode: StartTime, Time: t}, nil
+	}
+
+	return nil, fmt.Errorf("%q should be new, first, last, stream sequence or RFC3339 time", position)
+}
+
+// GetMergeStrategy returns strategy of merging, it is deep merging by default
+func (c *CollectionConfig) GetMergeStrategy() string {
+
+	if len(c.MergeStrategy) == 0 {
+		return MergeDeep
+	}
+
+	return strings.ToLower(c.MergeStrategy)
+}
+
+// GetTombstone returns mode of handling delete events, records are deleted by default
+func (c *CollectionConfig) GetTombstone() string {
+
+	if len(c.Tombstone) == 0 {
+		return TombstoneDelete
+	}
+
+	return strings.ToLower(c.Tombstone)
+}
+
+func (c *CollectionConfig) validate(name string, partitionCount int, check func(bool, string, ...interface{})) {
+
+	prefix := "collection." + name
+
+	_, err := ParseStartPosition(c.StartPosition)
+	check(err == nil, "%s.startPosition: %v", prefix, err)
+
+	seen := make(map[uint64]struct{}, len(c.Partitions))
+	for _, p := range c.Partitions {
+		_, dup := seen[p]
+		check(!dup, "%s.partitions: partition %d is duplicated", prefix, p)
+		check(p < uint64(partitionCount), "%s.partitions: partition %d is out of range, snapshot.partitionCount is %d", prefix, p, partitionCount)
+		seen[p] = struct{}{}
+	}
+
+	switch c.GetMergeStrategy() {
+	case MergeDeep, MergeShallow, MergeReplace:
+	default:
+		check(false, "%s.mergeStrategy: %q should be merge, shallow or replace", prefix, c.MergeStrategy)
+	}
+
+	check(len(c.IncludeFields) == 0 || len(c.ExcludeFields) == 0, "%s: only one of includeFields and excludeFields can be specified", prefix)
+	check(c.Retention >= 0, "%s.retention: should not be negative", prefix)
+
+	switch c.GetTombstone() {
+	case TombstoneDelete, TombstoneKeep:
+	default:
+		check(false, "%s.tombstone: %q should be delete or keep", prefix, c.Tombstone)
+	}
+
+	check(c.Workers >= 0, "%s.workers: should not be negative", prefix)
+}
@@ -2,14 +2,185 @@ package configs
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Collections []string
+	Collections []string `mapstructure:"-" yaml:"collections"`
+
+	// Collections specified by command line, they are kept when config was reloaded
+	flagCollections []string
+
+	Gravity   GravityConfig   `mapstructure:"gravity" yaml:"gravity"`
+	Datastore DatastoreConfig `mapstructure:"datastore" yaml:"datastore"`
+	Snapshot  SnapshotConfig  `mapstructure:"snapshot" yaml:"snapshot"`
+
+	// Settings of specific collections, names of collections are case-insensitive
+	Collection map[string]*CollectionConfig `mapstructure:"collection" yaml:"collection,omitempty"`
+
+	HTTP    HTTPConfig    `mapstructure:"http" yaml:"http"`
+	Health  HealthConfig  `mapstructure:"health" yaml:"health"`
+	Tracing TracingConfig `mapstructure:"tracing" yaml:"tracing"`
+	Cluster ClusterConfig `mapstructure:"cluster" yaml:"cluster"`
+	HA      HAConfig      `mapstructure:"ha" yaml:"ha"`
+	Backup  BackupConfig  `mapstructure:"backup" yaml:"backup"`
+	Export  ExportConfig  `mapstructure:"export" yaml:"export"`
+	Verify  VerifyConfig  `mapstructure:"verify" yaml:"verify"`
+	Log     LogConfig     `mapstructure:"log" yaml:"log"`
+	View    ViewConfig    `mapstructure:"view" yaml:"view"`
+	Reload  ReloadConfig  `mapstructure:"reload" yaml:"reload"`
+
+	// Settings which can be reloaded are guarded, they should be read by getters once service started
+	mutex sync.RWMutex
+}
+
+type GravityConfig struct {
+	Domain              string     `mapstructure:"domain" yaml:"domain"`
+	AccessKey           string     `mapstructure:"accessKey" yaml:"accessKey"`
+	Host                string     `mapstructure:"host" yaml:"host"`
+	Port                int        `mapstructure:"port" yaml:"port"`
+	Servers             []string   `mapstructure:"servers" yaml:"servers"`
+	Randomize           bool       `mapstructure:"randomize" yaml:"randomize"`
+	PingInterval        int        `mapstructure:"pingInterval" yaml:"pingInterval"`
+	MaxPingsOutstanding int        `mapstructure:"maxPingsOutstanding" yaml:"maxPingsOutstanding"`
+	MaxReconnects       int        `mapstructure:"maxReconnects" yaml:"maxReconnects"`
+	ValidatePermissions bool       `mapstructure:"validatePermissions" yaml:"validatePermissions"`
+	TLS                 TLSConfig  `mapstructure:"tls" yaml:"tls"`
+	Auth                AuthConfig `mapstructure:"auth" yaml:"auth"`
+}
+
+type TLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled" yaml:"enabled"`
+	CAFile             string `mapstructure:"caFile" yaml:"caFile"`
+	CertFile           string `mapstructure:"certFile" yaml:"certFile"`
+	KeyFile            string `mapstructure:"keyFile" yaml:"keyFile"`
+	ServerName         string `mapstructure:"serverName" yaml:"serverName"`
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify" yaml:"insecureSkipVerify"`
+}
+
+type AuthConfig struct {
+	Credentials string `mapstructure:"credentials" yaml:"credentials"`
+	NKey        string `mapstructure:"nkey" yaml:"nkey"`
+	User        string `mapstructure:"user" yaml:"user"`
+	Password    string `mapstructure:"password" yaml:"password"`
+	Token       string `mapstructure:"token" yaml:"token"`
+}
+
+type DatastoreConfig struct {
+	Path string `mapstructure:"path" yaml:"path"`
+}
+
+// SnapshotConfig contains settings of workers and collections, durations are in seconds
+type SnapshotConfig struct {
+	WorkerCount       int `mapstructure:"workerCount" yaml:"workerCount"`
+	WorkerBufferSize  int `mapstructure:"workerBufferSize" yaml:"workerBufferSize"`
+	PartitionCount    int `mapstructure:"partitionCount" yaml:"partitionCount"`
+	DiscoveryInterval int `mapstructure:"discoveryInterval" yaml:"discoveryInterval"`
+	ProgressInterval  int `mapstructure:"progressInterval" yaml:"progressInterval"`
+	CaughtUpLag       int `mapstructure:"caughtUpLag" yaml:"caughtUpLag"`
+	ShutdownTimeout   int `mapstructure:"shutdownTimeout" yaml:"shutdownTimeout"`
+	RecoverAttempts   int `mapstructure:"recoverAttempts" yaml:"recoverAttempts"`
+}
+
+type HTTPConfig struct {
+	Enabled bool   `mapstructure:"enabled" yaml:"enabled"`
+	Address string `mapstructure:"address" yaml:"address"`
+
+	// Endpoints to change log levels and debug controls at runtime
+	Admin bool `mapstructure:"admin" yaml:"admin"`
+}
+
+type HealthConfig struct {
+	MaxConsumerLag  uint64 `mapstructure:"maxConsumerLag" yaml:"maxConsumerLag"`
+	RequireCaughtUp bool   `mapstructure:"requireCaughtUp" yaml:"requireCaughtUp"`
+}
+
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled" yaml:"enabled"`
+	Exporter    string  `mapstructure:"exporter" yaml:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint" yaml:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure" yaml:"insecure"`
+	File        string  `mapstructure:"file" yaml:"file"`
+	SampleRatio float64 `mapstructure:"sampleRatio" yaml:"sampleRatio"`
+}
+
+type ClusterConfig struct {
+	Enabled        bool   `mapstructure:"enabled" yaml:"enabled"`
+	NodeID         string `mapstructure:"nodeID" yaml:"nodeID"`
+	LeaseTTL       int    `mapstructure:"leaseTTL" yaml:"leaseTTL"`
+	RequestTimeout int    `mapstructure:"requestTimeout" yaml:"requestTimeout"`
+}
+
+type HAConfig struct {
+	Enabled  bool `mapstructure:"enabled" yaml:"enabled"`
+	LeaseTTL int  `mapstructure:"leaseTTL" yaml:"leaseTTL"`
+}
+
+type BackupConfig struct {
+	Path    string `mapstructure:"path" yaml:"path"`
+	Timeout int    `mapstructure:"timeout" yaml:"timeout"`
+}
+
+type ExportConfig struct {
+	Path    string `mapstructure:"path" yaml:"path"`
+	Timeout int    `mapstructure:"timeout" yaml:"timeout"`
+}
+
+type VerifyConfig struct {
+	Timeout int `mapstructure:"timeout" yaml:"timeout"`
+}
+
+// LogConfig contains settings of logger, GRAVITY_DEBUG_LEVEL is used if level was not specified
+type LogConfig struct {
+	Level  string `mapstructure:"level" yaml:"level"`
+	Format string `mapstructure:"format" yaml:"format"`
+
+	// Levels of specific loggers by name, such as Snapshot, RPC, ViewManager and Connector
+	Levels map[string]string `mapstructure:"levels" yaml:"levels,omitempty"`
+
+	File LogFileConfig `mapstructure:"file" yaml:"file"`
+}
+
+// LogFileConfig contains settings of log file, size is in megabytes and age is in days
+type LogFileConfig struct {
+	Path       string `mapstructure:"path" yaml:"path"`
+	MaxSize    int    `mapstructure:"maxSize" yaml:"maxSize"`
+	MaxBackups int    `mapstructure:"maxBackups" yaml:"maxBackups"`
+	MaxAge     int    `mapstructure:"maxAge" yaml:"maxAge"`
+	Compress   bool   `mapstructure:"compress" yaml:"compress"`
+}
+
+// ViewConfig contains settings of snapshot views, views which are not accessed for TTL seconds are removed
+type ViewConfig struct {
+	TTL       int `mapstructure:"ttl" yaml:"ttl"`
+	BatchSize int `mapstructure:"batchSize" yaml:"batchSize"`
+
+	// Seconds to wait for collection to catch up with stream before the first batch is pushed
+	CaughtUpTimeout int `mapstructure:"caughtUpTimeout" yaml:"caughtUpTimeout"`
+}
+
+type ReloadConfig struct {
+	Watch bool `mapstructure:"watch" yaml:"watch"`
+}
+
+// GetCollectionConfig returns settings of specific collection, default settings are returned if collection has no block
+func (config *Config) GetCollectionConfig(name string) *CollectionConfig {
+
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	for n, c := range config.Collection {
+		if c != nil && strings.EqualFold(n, name) {
+			return c
+		}
+	}
+
+	return &CollectionConfig{}
 }
 
 func GetConfig() *Config {
@@ -19,28 +190,59 @@ func GetConfig() *Config {
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
+	// Settings should be known by viper, so they can be overridden by environment variables
+	setDefaults(viper.GetViper())
+
 	// From config file
 	viper.SetConfigName("config")
 	viper.AddConfigPath("./")
 	viper.AddConfigPath("./configs")
 
 	if err := viper.ReadInConfig(); err != nil {
-		fmt.Println("No configuration file was loaded")
+		fmt.Fprintln(os.Stderr, "No configuration file was loaded")
 	}
 
 	runtime.GOMAXPROCS(8)
 
 	config := &Config{
-		Collections: make([]string, 0),
+		Collections: getCollections(),
 	}
 
-	// Specify collections from environment variable for watching
-	collections := viper.GetStringSlice("COLLECTIONS")
-	for _, c := range collections {
-		config.Collections = append(config.Collections, c)
+	return config
+}
+
+// getCollections returns collections specified by environment variable or config file for watching
+func getCollections() []string {
+
+	collections := make([]string, 0)
+	for _, c := range viper.GetStringSlice("COLLECTIONS") {
+		collections = append(collections, c)
 	}
 
-	return config
+	return collections
+}
+
+// Load reads all settings from config file and environment, then validates them
+func (config *Config) Load() error {
+
+	err := viper.Unmarshal(config)
+	if err != nil {
+		return fmt.Errorf("Failed to load config: %w", err)
+	}
+
+	// Servers can be specified by comma-separated string in environment variable
+	servers := make([]string, 0)
+	for _, entry := range config.Gravity.Servers {
+		for _, server := range strings.Split(entry, ",") {
+			server = strings.TrimSpace(server)
+			if len(server) > 0 {
+				servers = append(servers, server)
+			}
+		}
+	}
+	config.Gravity.Servers = servers
+
+	return config.Validate()
 }
 
 func (config *Config) FindCollections(event string) int {
@@ -56,6 +258,8 @@ func (config *Config) FindCollections(event string) int {
 
 func (config *Config) AddCollections(events []string) {
 
+	config.flagCollections = append(config.flagCollections, events...)
+
 	for _, event := range events {
 		if config.FindCollections(event) == -1 {
 			config.Collections = append(config.Collections, event)
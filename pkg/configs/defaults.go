@@ -0,0 +1,131 @@
+package configs
+
+import "github.com/spf13/viper"
+
+const (
+	DefaultDomain              = "gravity"
+	DefaultHost                = "0.0.0.0"
+	DefaultPort                = 32803
+	DefaultPingInterval        = 10
+	DefaultMaxPingsOutstanding = 3
+	DefaultMaxReconnects       = -1
+	DefaultWorkerCount         = 8
+	DefaultWorkerBufferSize    = 102400
+	DefaultPartitionCount      = 256
+	DefaultDiscoveryInterval   = 30
+	DefaultProgressInterval    = 5
+	DefaultCaughtUpLag         = 100
+	DefaultShutdownTimeout     = 10
+	DefaultRecoverAttempts     = 12
+	DefaultHTTPAddress         = ":44480"
+	DefaultMaxConsumerLag      = 10000
+	DefaultClusterLeaseTTL     = 15
+	DefaultRequestTimeout      = 2
+	DefaultHALeaseTTL          = 6
+	DefaultBackupTimeout       = 30
+	DefaultVerifyTimeout       = 600
+	DefaultExportTimeout       = 600
+	DefaultViewTTL             = 0
+	DefaultViewBatchSize       = 100
+	DefaultViewCaughtUpTimeout = 30
+	DefaultLogFormat           = "console"
+	DefaultLogMaxSize          = 100
+	DefaultLogMaxBackups       = 5
+	DefaultLogMaxAge           = 30
+)
+
+func setDefaults(v *viper.Viper) {
+
+	// Gravity Network
+	v.SetDefault("gravity.domain", DefaultDomain)
+	v.SetDefault("gravity.accessKey", "")
+	v.SetDefault("gravity.host", DefaultHost)
+	v.SetDefault("gravity.port", DefaultPort)
+	v.SetDefault("gravity.servers", []string{})
+	v.SetDefault("gravity.randomize", true)
+	v.SetDefault("gravity.pingInterval", DefaultPingInterval)
+	v.SetDefault("gravity.maxPingsOutstanding", DefaultMaxPingsOutstanding)
+	v.SetDefault("gravity.maxReconnects", DefaultMaxReconnects)
+	v.SetDefault("gravity.validatePermissions", true)
+	v.SetDefault("gravity.tls.enabled", false)
+	v.SetDefault("gravity.tls.caFile", "")
+	v.SetDefault("gravity.tls.certFile", "")
+	v.SetDefault("gravity.tls.keyFile", "")
+	v.SetDefault("gravity.tls.serverName", "")
+	v.SetDefault("gravity.tls.insecureSkipVerify", false)
+	v.SetDefault("gravity.auth.credentials", "")
+	v.SetDefault("gravity.auth.nkey", "")
+	v.SetDefault("gravity.auth.user", "")
+	v.SetDefault("gravity.auth.password", "")
+	v.SetDefault("gravity.auth.token", "")
+
+	// Datastore
+	v.SetDefault("datastore.path", "./data")
+
+	// Snapshot
+	v.SetDefault("snapshot.workerCount", DefaultWorkerCount)
+	v.SetDefault("snapshot.workerBufferSize", DefaultWorkerBufferSize)
+	v.SetDefault("snapshot.partitionCount", DefaultPartitionCount)
+	v.SetDefault("snapshot.discoveryInterval", DefaultDiscoveryInterval)
+	v.SetDefault("snapshot.progressInterval", DefaultProgressInterval)
+	v.SetDefault("snapshot.caughtUpLag", DefaultCaughtUpLag)
+	v.SetDefault("snapshot.shutdownTimeout", DefaultShutdownTimeout)
+	v.SetDefault("snapshot.recoverAttempts", DefaultRecoverAttempts)
+
+	// Observability
+	v.SetDefault("http.enabled", true)
+	v.SetDefault("http.address", DefaultHTTPAddress)
+	v.SetDefault("http.admin", false)
+	v.SetDefault("health.maxConsumerLag", DefaultMaxConsumerLag)
+	v.SetDefault("health.requireCaughtUp", false)
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.exporter", "otlp")
+	v.SetDefault("tracing.endpoint", "localhost:4318")
+	v.SetDefault("tracing.insecure", true)
+	v.SetDefault("tracing.file", "./traces.json")
+	v.SetDefault("tracing.sampleRatio", 1.0)
+
+	// Scaling out
+	v.SetDefault("cluster.enabled", false)
+	v.SetDefault("cluster.nodeID", "")
+	v.SetDefault("cluster.leaseTTL", DefaultClusterLeaseTTL)
+	v.SetDefault("cluster.requestTimeout", DefaultRequestTimeout)
+	v.SetDefault("ha.enabled", false)
+	v.SetDefault("ha.leaseTTL", DefaultHALeaseTTL)
+
+	// RPC
+	v.SetDefault("backup.path", "./backups")
+	v.SetDefault("backup.timeout", DefaultBackupTimeout)
+	v.SetDefault("export.path", "./exports")
+	v.SetDefault("export.timeout", DefaultExportTimeout)
+	v.SetDefault("verify.timeout", DefaultVerifyTimeout)
+	v.SetDefault("view.ttl", DefaultViewTTL)
+	v.SetDefault("view.batchSize", DefaultViewBatchSize)
+	v.SetDefault("view.caughtUpTimeout", DefaultViewCaughtUpTimeout)
+
+	// Runtime
+	v.SetDefault("log.level", "")
+	v.SetDefault("log.format", DefaultLogFormat)
+	v.SetDefault("log.file.path", "")
+	v.SetDefault("log.file.maxSize", DefaultLogMaxSize)
+	v.SetDefault("log.file.maxBackups", DefaultLogMaxBackups)
+	v.SetDefault("log.file.maxAge", DefaultLogMaxAge)
+	v.SetDefault("log.file.compress", false)
+	v.SetDefault("reload.watch", true)
+}
+
+// NewDefaultConfig returns config which contains default settings only, it is not affected by config file or environment
+func NewDefaultConfig() *Config {
+
+	v := viper.New()
+	setDefaults(v)
+
+	config := &Config{
+		Collections: make([]string, 0),
+	}
+
+	// Defaults are always decodable
+	_ = v.Unmarshal(config)
+
+	return config
+}
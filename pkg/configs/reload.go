@@ -0,0 +1,198 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Settings which can be changed without restart, nested settings are included
+var reloadableSettings = []string{
+	"collections",
+	"collection",
+	"log.level",
+	"log.levels",
+	"view.ttl",
+	"view.batchSize",
+	"view.caughtUpTimeout",
+}
+
+// IsReloadable returns true if setting can be changed without restart
+func IsReloadable(setting string) bool {
+
+	for _, s := range reloadableSettings {
+		if setting == s || strings.HasPrefix(setting, s+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Refresh reads settings from config file and environment again, then returns new config and settings which were changed.
+// Config itself is not changed, new config should be applied by Update once it was accepted.
+func (config *Config) Refresh() (*Config, []string, error) {
+
+	err := viper.ReadInConfig()
+	if err != nil && !errors.As(err, &viper.ConfigFileNotFoundError{}) {
+		return nil, nil, fmt.Errorf("Failed to read config file: %w", err)
+	}
+
+	next := &Config{
+		Collections: getCollections(),
+	}
+
+	// Collections of command line are still required
+	next.AddCollections(config.flagCollections)
+
+	err = next.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := Diff(config, next)
+
+	restarts := make([]string, 0)
+	for _, setting := range changes {
+		if !IsReloadable(setting) {
+			restarts = append(restarts, setting)
+		}
+	}
+
+	if len(restarts) > 0 {
+		return nil, changes, fmt.Errorf("Settings require restart: %s", strings.Join(restarts, ", "))
+	}
+
+	return next, changes, nil
+}
+
+// Update applies settings which can be changed without restart from another config, settings of another config are
+// taken as they are, so they should not be changed later
+func (config *Config) Update(next *Config) {
+
+	config.mutex.Lock()
+	defer config.mutex.Unlock()
+
+	config.Collections = next.Collections
+	config.Collection = next.Collection
+	config.Log = next.Log
+	config.View = next.View
+}
+
+// GetCollections returns collections to be watched
+func (config *Config) GetCollections() []string {
+
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	collections := make([]string, len(config.Collections))
+	copy(collections, config.Collections)
+
+	return collections
+}
+
+// GetLogConfig returns copy of log settings
+func (config *Config) GetLogConfig() LogConfig {
+
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	return config.Log
+}
+
+// GetViewConfig returns copy of view settings
+func (config *Config) GetViewConfig() ViewConfig {
+
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	return config.View
+}
+
+// Diff returns settings which are different between two configs, settings are named by path such as "snapshot.workerCount"
+func Diff(a *Config, b *Config) []string {
+
+	changes := make([]string, 0)
+	diffValue("", reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem(), &changes)
+	sort.Strings(changes)
+
+	return changes
+}
+
+func diffValue(path string, a reflect.Value, b reflect.Value, changes *[]string) {
+
+	switch a.Kind() {
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+
+			f := t.Field(i)
+
+			// Unexported fields are not settings
+			if len(f.PkgPath) > 0 {
+				continue
+			}
+
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if len(name) == 0 || name == "-" {
+				continue
+			}
+
+			diffValue(joinPath(path, name), a.Field(i), b.Field(i), changes)
+		}
+	case reflect.Map:
+		keys := make(map[string]bool)
+		for _, k := range append(a.MapKeys(), b.MapKeys()...) {
+			keys[k.String()] = true
+		}
+
+		for k := range keys {
+
+			key := reflect.ValueOf(k)
+			va := a.MapIndex(key)
+			vb := b.MapIndex(key)
+
+			if !va.IsValid() || !vb.IsValid() {
+				*changes = append(*changes, joinPath(path, k))
+				continue
+			}
+
+			diffValue(joinPath(path, k), va, vb, changes)
+		}
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*changes = append(*changes, path)
+			}
+			return
+		}
+
+		diffValue(path, a.Elem(), b.Elem(), changes)
+	case reflect.Slice:
+		// Empty slice is the same as nil
+		if a.Len() == 0 && b.Len() == 0 {
+			return
+		}
+
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changes = append(*changes, path)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changes = append(*changes, path)
+		}
+	}
+}
+
+func joinPath(path string, name string) string {
+
+	if len(path) == 0 {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,146 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const redactedValue = "******"
+
+var domainPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Validate checks all settings and returns every problem at once
+func (config *Config) Validate() error {
+
+	errs := make([]string, 0)
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	// Gravity Network
+	g := &config.Gravity
+	check(domainPattern.MatchString(g.Domain), "gravity.domain: %q should only contain letters, digits, '_' and '-'", g.Domain)
+	check(len(g.Servers) > 0 || (g.Port > 0 && g.Port <= 65535), "gravity.port: %d is out of range", g.Port)
+	check(g.PingInterval > 0, "gravity.pingInterval: should be greater than 0")
+	check(g.MaxPingsOutstanding > 0, "gravity.maxPingsOutstanding: should be greater than 0")
+
+	hasCert := len(g.TLS.CertFile) > 0
+	hasKey := len(g.TLS.KeyFile) > 0
+	check(hasCert == hasKey, "gravity.tls: both certFile and keyFile are required for client certificate")
+
+	methods := make([]string, 0)
+	for name, v := range map[string]string{
+		"credentials": g.Auth.Credentials,
+		"nkey":        g.Auth.NKey,
+		"user":        g.Auth.User,
+		"token":       g.Auth.Token,
+	} {
+		if len(v) > 0 {
+			methods = append(methods, name)
+		}
+	}
+	check(len(methods) <= 1, "gravity.auth: only one of credentials, nkey, user and token can be specified")
+	check(len(g.Auth.Password) == 0 || len(g.Auth.User) > 0, "gravity.auth.password: user is required")
+	check(len(g.AccessKey) == 0, "gravity.accessKey: is not supported, use one of gravity.auth instead")
+
+	// Datastore
+	check(len(config.Datastore.Path) > 0, "datastore.path: is required")
+
+	// Snapshot
+	s := &config.Snapshot
+	check(s.WorkerCount > 0, "snapshot.workerCount: should be greater than 0")
+	check(s.WorkerBufferSize > 0, "snapshot.workerBufferSize: should be greater than 0")
+	check(s.PartitionCount > 0, "snapshot.partitionCount: should be greater than 0")
+	check(s.DiscoveryInterval > 0, "snapshot.discoveryInterval: should be greater than 0")
+	check(s.ProgressInterval > 0, "snapshot.progressInterval: should be greater than 0")
+	check(s.CaughtUpLag >= 0, "snapshot.caughtUpLag: should not be negative")
+	check(s.ShutdownTimeout > 0, "snapshot.shutdownTimeout: should be greater than 0")
+	check(s.RecoverAttempts >= 0, "snapshot.recoverAttempts: should not be negative")
+
+	for name, c := range config.Collection {
+		if c != nil {
+			c.validate(name, s.PartitionCount, check)
+		}
+	}
+
+	// Observability
+	check(!config.HTTP.Enabled || len(config.HTTP.Address) > 0, "http.address: is required if http is enabled")
+
+	t := &config.Tracing
+	check(t.Exporter == "otlp" || t.Exporter == "stdout" || t.Exporter == "file", "tracing.exporter: %q is not supported, should be otlp, stdout or file", t.Exporter)
+	check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "tracing.sampleRatio: should be between 0 and 1")
+
+	// Scaling out
+	check(!(config.Cluster.Enabled && config.HA.Enabled), "cluster and ha cannot be enabled at the same time")
+	check(config.Cluster.LeaseTTL >= 3, "cluster.leaseTTL: should be at least 3 seconds")
+	check(config.Cluster.RequestTimeout > 0, "cluster.requestTimeout: should be greater than 0")
+	check(config.HA.LeaseTTL >= 3, "ha.leaseTTL: should be at least 3 seconds")
+
+	// RPC
+	check(config.Backup.Timeout > 0, "backup.timeout: should be greater than 0")
+	check(config.Verify.Timeout > 0, "verify.timeout: should be greater than 0")
+	check(config.Export.Timeout > 0, "export.timeout: should be greater than 0")
+	check(config.View.TTL >= 0, "view.ttl: should not be negative")
+	check(config.View.BatchSize > 0, "view.batchSize: should be greater than 0")
+	check(config.View.CaughtUpTimeout >= 0, "view.caughtUpTimeout: should not be negative")
+
+	// Runtime
+	l := &config.Log
+	check(len(l.Level) == 0 || isLogLevel(l.Level), "log.level: %q is not supported, should be debug, info, warn, error, dpanic, panic or fatal", l.Level)
+	check(l.Format == "console" || l.Format == "json", "log.format: %q is not supported, should be console or json", l.Format)
+	check(l.File.MaxSize > 0, "log.file.maxSize: should be greater than 0")
+	check(l.File.MaxBackups >= 0, "log.file.maxBackups: should not be negative")
+	check(l.File.MaxAge >= 0, "log.file.maxAge: should not be negative")
+
+	for name, level := range l.Levels {
+		check(isLogLevel(level), "log.levels.%s: %q is not supported, should be debug, info, warn, error, dpanic, panic or fatal", name, level)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errors.New("Invalid configuration:\n  " + strings.Join(errs, "\n  "))
+}
+
+func isLogLevel(level string) bool {
+	var l zapcore.Level
+	return l.UnmarshalText([]byte(level)) == nil
+}
+
+// Redacted returns a copy of config with secrets masked, so it can be printed
+func (config *Config) Redacted() *Config {
+
+	config.mutex.RLock()
+	defer config.mutex.RUnlock()
+
+	// Settings are copied without the lock
+	c := &Config{}
+	src := reflect.ValueOf(config).Elem()
+	dst := reflect.ValueOf(c).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if len(src.Type().Field(i).PkgPath) == 0 {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+
+	redact := func(v string) string {
+		if len(v) == 0 {
+			return v
+		}
+		return redactedValue
+	}
+
+	c.Gravity.AccessKey = redact(c.Gravity.AccessKey)
+	c.Gravity.Auth.Password = redact(c.Gravity.Auth.Password)
+	c.Gravity.Auth.Token = redact(c.Gravity.Auth.Token)
+
+	return c
+}
@@ -2,48 +2,63 @@ package connector
 
 import (
 	"context"
-	"fmt"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/BrobridgeOrg/gravity-sdk/core"
-	"github.com/spf13/viper"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/metrics"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
-var logger *zap.Logger
-
-const (
-	DefaultHost                = "0.0.0.0"
-	DefaultPort                = 32803
-	DefaultPingInterval        = 10
-	DefaultMaxPingsOutstanding = 3
-	DefaultMaxReconnects       = -1
-	DefaultAccessKey           = ""
-	DefaultDomain              = "gravity"
-)
-
 type Connector struct {
-	client *core.Client
-	logger *zap.Logger
-	domain string
+	config       *configs.GravityConfig
+	logger       *zap.Logger
+	domain       string
+	conn         *nats.Conn
+	handlers     []func(string)
+	publications []string
+	events       *prometheus.CounterVec
+	mutex        sync.RWMutex
 }
 
-func New(lifecycle fx.Lifecycle, l *zap.Logger) *Connector {
-
-	logger = l.Named("Connector")
+func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, m *metrics.Registry) *Connector {
 
+	// Domain is required by other modules before connecting
 	c := &Connector{
-		client: core.NewClient(),
+		config:       &config.Gravity,
+		logger:       l.Named("Connector"),
+		domain:       config.Gravity.Domain,
+		handlers:     make([]func(string), 0),
+		publications: make([]string, 0),
 	}
 
+	c.registerMetrics(m)
+
 	lifecycle.Append(
 		fx.Hook{
 			OnStart: func(context.Context) error {
 				return c.initialize()
 			},
 			OnStop: func(ctx context.Context) error {
-				c.client.Disconnect()
+
+				conn := c.GetConnection()
+				if conn == nil {
+					return nil
+				}
+
+				// Flush outgoing messages before disconnecting
+				err := conn.Flush()
+				if err != nil {
+					c.logger.Warn(err.Error())
+				}
+
+				c.logger.Info("Disconnecting from Gravity Network...")
+				conn.Close()
+
 				return nil
 			},
 		},
@@ -56,7 +71,19 @@ func (c *Connector) initialize() error {
 
 	err := c.connect()
 	if err != nil {
-		//c.logger.Error(err.Error())
+		c.logger.Error(err.Error())
+		return err
+	}
+
+	// Make sure credentials grant subjects which are required
+	if !c.config.ValidatePermissions {
+		return nil
+	}
+
+	err = c.validatePermissions()
+	if err != nil {
+		c.logger.Error(err.Error())
+		c.GetConnection().Close()
 		return err
 	}
 
@@ -65,54 +92,127 @@ func (c *Connector) initialize() error {
 
 func (c *Connector) connect() error {
 
-	// default domain and access key
-	viper.SetDefault("gravity.domain", DefaultDomain)
-	viper.SetDefault("gravity.accessKey", DefaultAccessKey)
-
-	// default settings
-	viper.SetDefault("gravity.host", DefaultHost)
-	viper.SetDefault("gravity.port", DefaultPort)
-	viper.SetDefault("gravity.pingInterval", DefaultPingInterval)
-	viper.SetDefault("gravity.maxPingsOutstanding", DefaultMaxPingsOutstanding)
-	viper.SetDefault("gravity.maxReconnects", DefaultMaxReconnects)
-
 	// Read configs
-	domain := viper.GetString("gravity.domain")
-	//	accessKey := viper.GetString("gravity.accessKey")
-	host := viper.GetString("gravity.host")
-	port := viper.GetInt("gravity.port")
-	pingInterval := viper.GetInt64("gravity.pingInterval")
-	maxPingsOutstanding := viper.GetInt("gravity.maxPingsOutstanding")
-	maxReconnects := viper.GetInt("gravity.maxReconnects")
+	domain := c.config.Domain
+	servers := c.getServers()
+	randomize := c.config.Randomize
+	pingInterval := c.config.PingInterval
+	maxPingsOutstanding := c.config.MaxPingsOutstanding
+	maxReconnects := c.config.MaxReconnects
 
 	// Preparing options
-	options := core.NewOptions()
-	options.PingInterval = time.Duration(pingInterval) * time.Second
-	options.MaxPingsOutstanding = maxPingsOutstanding
-	options.MaxReconnects = maxReconnects
+	options := []nats.Option{
+		nats.Name("gravity-snapshot"),
+		nats.PingInterval(time.Duration(pingInterval) * time.Second),
+		nats.MaxPingsOutstanding(maxPingsOutstanding),
+		nats.MaxReconnects(maxReconnects),
+		nats.ErrorHandler(c.handleError),
+	}
+
+	// Servers are tried in order as specified
+	if !randomize {
+		options = append(options, nats.DontRandomize())
+	}
 
-	address := fmt.Sprintf("%s:%d", host, port)
+	security, err := getSecurityOptions(c.config)
+	if err != nil {
+		return err
+	}
 
-	logger.Info("Connecting to Gravity Network...",
+	options = append(options, security.options...)
+	options = append(options, c.getEventOptions()...)
+
+	c.logger.Info("Connecting to Gravity Network...",
 		zap.String("domain", domain),
-		zap.String("address", address),
-		zap.Duration("pingInterval", options.PingInterval),
-		zap.Int("maxPingsOutstanding", options.MaxPingsOutstanding),
-		zap.Int("maxReconnects", options.MaxReconnects),
+		zap.Strings("servers", servers),
+		zap.Bool("randomize", randomize),
+		zap.Duration("pingInterval", time.Duration(pingInterval)*time.Second),
+		zap.Int("maxPingsOutstanding", maxPingsOutstanding),
+		zap.Int("maxReconnects", maxReconnects),
+		zap.Bool("tls", security.tls),
+		zap.String("auth", security.auth),
 	)
 
 	c.domain = domain
 
-	// Initializing keyring
-	//keyInfo := synchronizer.keyring.Put("gravity", accessKey)
-	//keyInfo.Permission().AddPermissions([]string{"SYSTEM"})
-
 	// Connect
-	return c.client.Connect(address, options)
+	conn, err := nats.Connect(strings.Join(servers, ","), options...)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Info("Connected to Gravity Network",
+		zap.String("url", conn.ConnectedUrl()),
+		zap.String("server", conn.ConnectedServerName()),
+	)
+
+	c.mutex.Lock()
+	c.conn = conn
+	c.mutex.Unlock()
+
+	return nil
+}
+
+func (c *Connector) handleError(conn *nats.Conn, sub *nats.Subscription, err error) {
+
+	if sub != nil {
+		c.logger.Error(err.Error(), zap.String("subject", sub.Subject))
+		return
+	}
+
+	c.logger.Error(err.Error())
+}
+
+// GetConnection returns connection if it was established
+func (c *Connector) GetConnection() *nats.Conn {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.conn
+}
+
+func (c *Connector) GetJetStream() (nats.JetStreamContext, error) {
+
+	conn := c.GetConnection()
+	if conn == nil {
+		return nil, nats.ErrConnectionClosed
+	}
+
+	return conn.JetStream()
+}
+
+// IsConnected returns true if connection to Gravity Network is established
+func (c *Connector) IsConnected() bool {
+
+	conn := c.GetConnection()
+	if conn == nil {
+		return false
+	}
+
+	return conn.IsConnected()
 }
 
-func (c *Connector) GetClient() *core.Client {
-	return c.client
+// IsClosed returns true if connection was closed and will not be reconnected
+func (c *Connector) IsClosed() bool {
+
+	conn := c.GetConnection()
+	if conn == nil {
+		return false
+	}
+
+	return conn.IsClosed()
+}
+
+// GetConnectedUrl returns URL of server which client is connected to
+func (c *Connector) GetConnectedUrl() string {
+
+	conn := c.GetConnection()
+	if conn == nil {
+		return ""
+	}
+
+	return conn.ConnectedUrl()
 }
 
 func (c *Connector) GetDomain() string {
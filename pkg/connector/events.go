@@ -0,0 +1,119 @@
+package connector
+
+import (
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/metrics"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Events of connection to Gravity Network
+const (
+	EventDisconnected = "disconnected"
+	EventReconnected  = "reconnected"
+	EventClosed       = "closed"
+)
+
+// States of connection to Gravity Network
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+	StateClosed       = "closed"
+)
+
+func (c *Connector) registerMetrics(m *metrics.Registry) {
+
+	c.events = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "connection_events_total",
+		Help:      "Number of disconnected, reconnected and closed events of connection to Gravity Network",
+	}, []string{"event"})
+
+	err := m.Register(c.events)
+	if err != nil {
+		c.logger.Warn(err.Error())
+	}
+
+	err = m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "connected",
+		Help:      "Whether connection to Gravity Network is established",
+	}, func() float64 {
+		if c.IsConnected() {
+			return 1
+		}
+		return 0
+	}))
+	if err != nil {
+		c.logger.Warn(err.Error())
+	}
+}
+
+// Watch registers handler to be called when connection was disconnected, reconnected or closed
+func (c *Connector) Watch(fn func(string)) {
+	c.mutex.Lock()
+	c.handlers = append(c.handlers, fn)
+	c.mutex.Unlock()
+}
+
+func (c *Connector) getEventOptions() []nats.Option {
+	return []nats.Option{
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+
+			fields := []zap.Field{}
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+			}
+
+			c.logger.Warn("Disconnected from Gravity Network", fields...)
+			c.emit(EventDisconnected)
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			c.logger.Info("Reconnected to Gravity Network",
+				zap.String("url", conn.ConnectedUrl()),
+				zap.String("server", conn.ConnectedServerName()),
+			)
+			c.emit(EventReconnected)
+		}),
+		nats.DiscoveredServersHandler(func(conn *nats.Conn) {
+			c.logger.Info("Discovered servers of cluster", zap.Strings("servers", conn.DiscoveredServers()))
+		}),
+		nats.ClosedHandler(func(conn *nats.Conn) {
+			c.logger.Info("Connection to Gravity Network was closed")
+			c.emit(EventClosed)
+		}),
+	}
+}
+
+func (c *Connector) emit(event string) {
+
+	c.events.WithLabelValues(event).Inc()
+
+	c.mutex.RLock()
+	handlers := make([]func(string), len(c.handlers))
+	copy(handlers, c.handlers)
+	c.mutex.RUnlock()
+
+	for _, fn := range handlers {
+		fn(event)
+	}
+}
+
+// GetState returns state of connection to Gravity Network
+func (c *Connector) GetState() string {
+
+	conn := c.GetConnection()
+	if conn == nil {
+		return StateConnecting
+	}
+
+	switch {
+	case conn.IsClosed():
+		return StateClosed
+	case conn.IsConnected():
+		return StateConnected
+	}
+
+	return StateDisconnected
+}
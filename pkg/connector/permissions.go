@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+
+// PermissionProbe is used in names of streams, consumers and keys which are probed, they are never created
+const PermissionProbe = "PERMISSION_CHECK"
+
+// getRequiredSubjects returns subjects which should be subscribed by this component
+func (c *Connector) getRequiredSubjects() []string {
+	return []string{
+		fmt.Sprintf("$GRAVITY.%s.API.SNAPSHOT.>", c.domain),
+		"_INBOX.>",
+	}
+}
+
+// RequirePublish specifies subjects which are published to by components, they are validated after connected
+func (c *Connector) RequirePublish(subjects ...string) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.publications = append(c.publications, subjects...)
+}
+
+// RequireStream specifies stream which is consumed by components with durable consumers
+func (c *Connector) RequireStream(stream string) {
+	c.RequirePublish(
+		fmt.Sprintf("$JS.API.STREAM.INFO.%s", stream),
+		fmt.Sprintf("$JS.API.CONSUMER.INFO.%s.%s", stream, PermissionProbe),
+		fmt.Sprintf("$JS.API.CONSUMER.DURABLE.CREATE.%s.%s", stream, PermissionProbe),
+	)
+}
+
+// RequireKeyValue specifies bucket of JetStream KV which is read, written and watched by components
+func (c *Connector) RequireKeyValue(bucket string) {
+
+	stream := "KV_" + bucket
+
+	c.RequirePublish(
+		fmt.Sprintf("$JS.API.STREAM.INFO.%s", stream),
+		fmt.Sprintf("$JS.API.STREAM.CREATE.%s", stream),
+		fmt.Sprintf("$JS.API.STREAM.MSG.GET.%s", stream),
+		fmt.Sprintf("$JS.API.CONSUMER.CREATE.%s", stream),
+		fmt.Sprintf("$KV.%s.%s", bucket, PermissionProbe),
+	)
+}
+
+func (c *Connector) getRequiredPublications() []string {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	publications := make([]string, len(c.publications))
+	copy(publications, c.publications)
+
+	return publications
+}
+
+func isPermissionViolation(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "permissions violation")
+}
+
+func (c *Connector) validatePermissions() error {
+
+	conn := c.GetConnection()
+
+	// Server rejects subscriptions which are not allowed
+	for _, subject := range c.getRequiredSubjects() {
+		sub, err := conn.SubscribeSync(subject)
+		if err != nil {
+			return err
+		}
+		defer sub.Unsubscribe()
+	}
+
+	// Make sure JetStream API is available for this account
+	js, err := conn.JetStream()
+	if err != nil {
+		return err
+	}
+
+	_, err = js.AccountInfo()
+	if err != nil {
+		return fmt.Errorf("%w: JetStream is not available (%v)", ErrPermissionDenied, err)
+	}
+
+	// Probes change nothing, requests of JetStream API without reply subject are ignored, empty requests are invalid and
+	// writes to KV are rejected by the expected sequence
+	publications := c.getRequiredPublications()
+	for _, subject := range publications {
+
+		m := nats.NewMsg(subject)
+		m.Header.Set(nats.ExpectedLastSubjSeqHdr, strconv.FormatUint(math.MaxUint64, 10))
+
+		err := conn.PublishMsg(m)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Violations are sent by server before replying to flush, so they are known when flush returned
+	err = conn.FlushTimeout(5 * time.Second)
+	if err != nil {
+		return err
+	}
+
+	err = conn.LastError()
+	if err != nil && isPermissionViolation(err) {
+		return fmt.Errorf("%w: %v", ErrPermissionDenied, err)
+	}
+
+	c.logger.Info("Permissions were validated",
+		zap.Strings("subjects", c.getRequiredSubjects()),
+		zap.Strings("publications", publications),
+	)
+
+	return nil
+}
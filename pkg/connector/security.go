@@ -0,0 +1,130 @@
+package connector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/nats-io/nats.go"
+)
+
+type securityOptions struct {
+	options []nats.Option
+	tls     bool
+	auth    string
+}
+
+func assertFile(setting string, path string) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", setting, err)
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("%s: %s is a directory", setting, path)
+	}
+
+	return nil
+}
+
+func getTLSOptions(config *configs.TLSConfig) ([]nats.Option, error) {
+
+	caFile := config.CAFile
+	certFile := config.CertFile
+	keyFile := config.KeyFile
+
+	enabled := config.Enabled || len(caFile) > 0 || len(certFile) > 0
+	if !enabled {
+		return nil, nil
+	}
+
+	options := []nats.Option{
+		nats.Secure(&tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			ServerName:         config.ServerName,
+			InsecureSkipVerify: config.InsecureSkipVerify,
+		}),
+	}
+
+	if len(caFile) > 0 {
+		err := assertFile("gravity.tls.caFile", caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, nats.RootCAs(caFile))
+	}
+
+	// Client certificate
+	if len(certFile) > 0 && len(keyFile) > 0 {
+
+		_, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("gravity.tls: %w", err)
+		}
+
+		options = append(options, nats.ClientCert(certFile, keyFile))
+	}
+
+	return options, nil
+}
+
+// getAuthOptions returns options of authentication, only one method was allowed by validation of config
+func getAuthOptions(config *configs.AuthConfig) ([]nats.Option, string, error) {
+
+	credentials := config.Credentials
+	nkey := config.NKey
+	user := config.User
+	password := config.Password
+	token := config.Token
+
+	switch {
+	case len(credentials) > 0:
+		err := assertFile("gravity.auth.credentials", credentials)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return []nats.Option{nats.UserCredentials(credentials)}, "credentials", nil
+	case len(nkey) > 0:
+		err := assertFile("gravity.auth.nkey", nkey)
+		if err != nil {
+			return nil, "", err
+		}
+
+		opt, err := nats.NkeyOptionFromSeed(nkey)
+		if err != nil {
+			return nil, "", fmt.Errorf("gravity.auth.nkey: %w", err)
+		}
+
+		return []nats.Option{opt}, "nkey", nil
+	case len(user) > 0:
+		return []nats.Option{nats.UserInfo(user, password)}, "user", nil
+	case len(token) > 0:
+		return []nats.Option{nats.Token(token)}, "token", nil
+	}
+
+	return nil, "none", nil
+}
+
+// getSecurityOptions validates settings of TLS and authentication, then returns options for connecting
+func getSecurityOptions(config *configs.GravityConfig) (*securityOptions, error) {
+
+	tlsOptions, err := getTLSOptions(&config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	authOptions, auth, err := getAuthOptions(&config.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return &securityOptions{
+		options: append(tlsOptions, authOptions...),
+		tls:     len(tlsOptions) > 0,
+		auth:    auth,
+	}, nil
+}
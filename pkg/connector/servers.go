@@ -0,0 +1,17 @@
+package connector
+
+import (
+	"fmt"
+)
+
+// getServers returns seed servers, it falls back to host and port if no server was specified
+func (c *Connector) getServers() []string {
+
+	if len(c.config.Servers) > 0 {
+		return c.config.Servers
+	}
+
+	return []string{
+		fmt.Sprintf("%s:%d", c.config.Host, c.config.Port),
+	}
+}
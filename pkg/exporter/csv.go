@@ -0,0 +1,87 @@
+package exporter
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+)
+
+type CSVWriter struct {
+	schema *Schema
+	writer *csv.Writer
+	row    []string
+}
+
+func NewCSVWriter(schema *Schema, w io.Writer) (*CSVWriter, error) {
+
+	cw := &CSVWriter{
+		schema: schema,
+		writer: csv.NewWriter(w),
+		row:    make([]string, len(schema.Columns)),
+	}
+
+	// Header
+	for i, col := range schema.Columns {
+		cw.row[i] = col.Name
+	}
+
+	err := cw.writer.Write(cw.row)
+	if err != nil {
+		return nil, err
+	}
+
+	return cw, nil
+}
+
+func (cw *CSVWriter) Write(payload *gravity_sdk_types_record.Value) error {
+
+	for i := range cw.row {
+		cw.row[i] = ""
+	}
+
+	if payload != nil && payload.Map != nil {
+		for _, field := range payload.Map.Fields {
+
+			idx, ok := cw.schema.indexes[field.Name]
+			if !ok {
+				continue
+			}
+
+			cw.row[idx] = formatValue(field.Value)
+		}
+	}
+
+	return cw.writer.Write(cw.row)
+}
+
+func (cw *CSVWriter) Close() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+// formatValue converts value to string, maps and arrays will be encoded as JSON
+func formatValue(value *gravity_sdk_types_record.Value) string {
+
+	v := snapshot.GetValue(value)
+	switch d := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return d
+	case []byte:
+		return base64.StdEncoding.EncodeToString(d)
+	case time.Time:
+		return d.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(d)
+		return string(data)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
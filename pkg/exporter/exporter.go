@@ -0,0 +1,150 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+)
+
+const (
+	FormatJSONL   = "jsonl"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// Source iterates payloads of records and calls specific function with each of them
+type Source func(fn func(*gravity_sdk_types_record.Value) error) error
+
+type Writer interface {
+	Write(*gravity_sdk_types_record.Value) error
+	Close() error
+}
+
+type Exporter struct {
+	format string
+}
+
+func NewExporter(format string) (*Exporter, error) {
+
+	switch format {
+	case FormatJSONL, FormatCSV, FormatParquet:
+	default:
+		return nil, fmt.Errorf("Unsupported format: %s", format)
+	}
+
+	return &Exporter{
+		format: format,
+	}, nil
+}
+
+func (e *Exporter) createWriter(source Source, w io.Writer) (Writer, error) {
+
+	if e.format == FormatJSONL {
+		return NewJSONLWriter(w), nil
+	}
+
+	// Scanning all records to derive schema
+	schema := NewSchema()
+	err := source(func(payload *gravity_sdk_types_record.Value) error {
+		schema.Update(payload)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if e.format == FormatCSV {
+		return NewCSVWriter(schema, w)
+	}
+
+	return NewParquetWriter(schema, w)
+}
+
+// Export writes all records from source, it returns the number of records
+func (e *Exporter) Export(source Source, w io.Writer) (int, error) {
+
+	writer, err := e.createWriter(source, w)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = source(func(payload *gravity_sdk_types_record.Value) error {
+		count++
+		return writer.Write(payload)
+	})
+	if err != nil {
+		writer.Close()
+		return count, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
+
+func (e *Exporter) ExportToFile(source Source, path string) (int, error) {
+
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	count, err := e.Export(source, w)
+	if err != nil {
+		return count, err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return count, err
+	}
+
+	return count, f.Sync()
+}
+
+// StoreSource iterates records of specific table in store, schema and rows are read from the same view of snapshot
+type StoreSource struct {
+	scanner *snapshot.Scanner
+}
+
+func NewStoreSource(store *eventstore.Store, table string) (*StoreSource, error) {
+
+	scanner, err := snapshot.NewScanner(store, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StoreSource{
+		scanner: scanner,
+	}, nil
+}
+
+// Scan iterates payloads of records, it can be used as Source
+func (source *StoreSource) Scan(fn func(*gravity_sdk_types_record.Value) error) error {
+	return source.scanner.Scan(func(key []byte, record *gravity_sdk_types_snapshot_record.SnapshotRecord) error {
+		return fn(record.Payload)
+	})
+}
+
+// Close releases view of snapshot
+func (source *StoreSource) Close() {
+	source.scanner.Release()
+}
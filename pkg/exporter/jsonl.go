@@ -0,0 +1,27 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+)
+
+type JSONLWriter struct {
+	encoder *json.Encoder
+}
+
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+func (w *JSONLWriter) Write(payload *gravity_sdk_types_record.Value) error {
+	return w.encoder.Encode(snapshot.GetValue(payload))
+}
+
+func (w *JSONLWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type ParquetWriter struct {
+	schema *Schema
+	writer *writer.JSONWriter
+}
+
+func NewParquetWriter(schema *Schema, w io.Writer) (*ParquetWriter, error) {
+
+	pw, err := writer.NewJSONWriterFromWriter(schema.toParquetSchema(), w, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParquetWriter{
+		schema: schema,
+		writer: pw,
+	}, nil
+}
+
+func (schema *Schema) toParquetSchema() string {
+
+	fields := make([]string, 0, len(schema.Columns))
+	for _, col := range schema.Columns {
+
+		var t string
+		switch col.Type {
+		case gravity_sdk_types_record.DataType_BOOLEAN:
+			t = "type=BOOLEAN"
+		case gravity_sdk_types_record.DataType_INT64:
+			t = "type=INT64"
+		case gravity_sdk_types_record.DataType_UINT64:
+			t = "type=INT64, convertedtype=UINT_64"
+		case gravity_sdk_types_record.DataType_FLOAT64:
+			t = "type=DOUBLE"
+		case gravity_sdk_types_record.DataType_TIME:
+			t = "type=INT64, convertedtype=TIMESTAMP_MILLIS"
+		default:
+			// Strings, binaries, maps and arrays
+			t = "type=BYTE_ARRAY, convertedtype=UTF8"
+		}
+
+		fields = append(fields, fmt.Sprintf(`{"Tag":"name=%s, %s, repetitiontype=OPTIONAL"}`, getParquetColumnName(col.Name), t))
+	}
+
+	return fmt.Sprintf(`{"Tag":"name=parquet_go_root, repetitiontype=REQUIRED","Fields":[%s]}`, strings.Join(fields, ","))
+}
+
+// getParquetColumnName replaces characters which are not allowed in tag of schema
+func getParquetColumnName(name string) string {
+	return strings.NewReplacer(",", "_", "=", "_", `"`, "_", `\`, "_").Replace(name)
+}
+
+func (pw *ParquetWriter) Write(payload *gravity_sdk_types_record.Value) error {
+
+	row := make(map[string]interface{}, len(pw.schema.Columns))
+
+	if payload != nil && payload.Map != nil {
+		for _, field := range payload.Map.Fields {
+
+			idx, ok := pw.schema.indexes[field.Name]
+			if !ok || field.Value == nil {
+				continue
+			}
+
+			col := pw.schema.Columns[idx]
+			name := getParquetColumnName(field.Name)
+
+			// Type of column is inconsistent
+			if col.Type != field.Value.Type {
+				if field.Value.Type != gravity_sdk_types_record.DataType_NULL {
+					row[name] = formatValue(field.Value)
+				}
+				continue
+			}
+
+			switch v := snapshot.GetValue(field.Value).(type) {
+			case time.Time:
+				row[name] = v.UnixNano() / int64(time.Millisecond)
+			case []byte, map[string]interface{}, []interface{}:
+				row[name] = formatValue(field.Value)
+			default:
+				row[name] = v
+			}
+		}
+	}
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	return pw.writer.Write(string(data))
+}
+
+func (pw *ParquetWriter) Close() error {
+	return pw.writer.WriteStop()
+}
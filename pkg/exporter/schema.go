@@ -0,0 +1,67 @@
+package exporter
+
+import (
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+)
+
+type Column struct {
+	Name string
+	Type gravity_sdk_types_record.DataType
+}
+
+type Schema struct {
+	Columns []*Column
+	indexes map[string]int
+}
+
+func NewSchema() *Schema {
+	return &Schema{
+		Columns: make([]*Column, 0),
+		indexes: make(map[string]int),
+	}
+}
+
+// Update adds fields of payload to schema, column will be string if its type is inconsistent
+func (schema *Schema) Update(payload *gravity_sdk_types_record.Value) {
+
+	if payload == nil || payload.Map == nil {
+		return
+	}
+
+	for _, field := range payload.Map.Fields {
+
+		if field.Value == nil || field.Value.Type == gravity_sdk_types_record.DataType_NULL {
+			if _, ok := schema.indexes[field.Name]; !ok {
+				schema.add(field.Name, gravity_sdk_types_record.DataType_NULL)
+			}
+			continue
+		}
+
+		idx, ok := schema.indexes[field.Name]
+		if !ok {
+			schema.add(field.Name, field.Value.Type)
+			continue
+		}
+
+		col := schema.Columns[idx]
+		if col.Type == field.Value.Type {
+			continue
+		}
+
+		// Type was unknown because of null value
+		if col.Type == gravity_sdk_types_record.DataType_NULL {
+			col.Type = field.Value.Type
+			continue
+		}
+
+		col.Type = gravity_sdk_types_record.DataType_STRING
+	}
+}
+
+func (schema *Schema) add(name string, t gravity_sdk_types_record.DataType) {
+	schema.indexes[name] = len(schema.Columns)
+	schema.Columns = append(schema.Columns, &Column{
+		Name: name,
+		Type: t,
+	})
+}
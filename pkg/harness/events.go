@@ -0,0 +1,183 @@
+package harness
+
+import (
+	"fmt"
+	"strings"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats.go"
+)
+
+// NewRecord creates record of event, data should contain value of primary key. Numbers should be int64, uint64,
+// float64 or json.Number, because other kinds of number are encoded as binary by SDK
+func NewRecord(method gravity_sdk_types_record.Method, table string, primaryKey string, data map[string]interface{}) *gravity_sdk_types_record.Record {
+
+	record := &gravity_sdk_types_record.Record{
+		EventName:  table,
+		Table:      table,
+		Method:     method,
+		PrimaryKey: primaryKey,
+	}
+
+	err := gravity_sdk_types_record.UnmarshalMapData(data, record)
+	if err != nil {
+		panic(err)
+	}
+
+	return record
+}
+
+// AssertStream creates stream of collection if it does not exist, it is created by application as well when started
+func (h *Harness) AssertStream(collection string) {
+
+	h.t.Helper()
+
+	streamName := snapshot.GetStreamName(h.GetDomain(), collection)
+	_, err := h.js.StreamInfo(streamName)
+	if err == nil {
+		return
+	}
+
+	if err != nats.ErrStreamNotFound {
+		h.t.Fatal(err)
+	}
+
+	_, err = h.js.AddStream(&nats.StreamConfig{
+		Name: streamName,
+		Subjects: []string{
+			snapshot.GetSubjectPrefix(h.GetDomain(), collection) + ".>",
+		},
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// Publish publishes record as event of collection to specific partition, it returns sequence of stream
+func (h *Harness) Publish(collection string, partition uint64, record *gravity_sdk_types_record.Record) uint64 {
+
+	h.t.Helper()
+
+	h.AssertStream(collection)
+
+	data, err := gravity_sdk_types_record.Marshal(record)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	eventName := record.EventName
+	if len(eventName) == 0 {
+		eventName = record.Table
+	}
+
+	subject := fmt.Sprintf("%s.%d.EVENT.%s", snapshot.GetSubjectPrefix(h.GetDomain(), collection), partition, eventName)
+	ack, err := h.js.Publish(subject, data)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	h.mutex.Lock()
+	partitions, ok := h.published[collection]
+	if !ok {
+		partitions = make(map[uint64]uint64)
+		h.published[collection] = partitions
+	}
+	partitions[partition] = ack.Sequence
+	h.mutex.Unlock()
+
+	return ack.Sequence
+}
+
+// WaitForApplied blocks until all events which were published to collection by harness were applied to snapshot
+func (h *Harness) WaitForApplied(collection string) {
+
+	h.t.Helper()
+
+	h.mutex.Lock()
+	expected := make(map[uint64]uint64, len(h.published[collection]))
+	for partition, seq := range h.published[collection] {
+		expected[partition] = seq
+	}
+	h.mutex.Unlock()
+
+	h.waitFor(fmt.Sprintf("events of %s to be applied", collection), func() bool {
+
+		if h.snapshot == nil {
+			return false
+		}
+
+		status, err := h.snapshot.GetCollectionStatus(collection)
+		if err != nil {
+			return false
+		}
+
+		applied := make(map[uint64]uint64, len(status.Partitions))
+		for _, ps := range status.Partitions {
+			applied[ps.Partition] = ps.AppliedSequence
+		}
+
+		// Progress of applying is not kept after restarted, so events acknowledged by consumers
+		// which have no events pending in workers were applied as well
+		acked := make(map[uint64]uint64)
+		if status.WorkerPending == 0 {
+			acked = h.getAckFloors(collection)
+		}
+
+		for partition, seq := range expected {
+			if applied[partition] < seq && acked[partition] < seq {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// getAckFloors returns sequence which was acknowledged by consumer of each partition
+func (h *Harness) getAckFloors(collection string) map[uint64]uint64 {
+
+	floors := make(map[uint64]uint64)
+	prefix := snapshot.GetSubjectPrefix(h.GetDomain(), collection)
+
+	for info := range h.js.ConsumersInfo(snapshot.GetStreamName(h.GetDomain(), collection)) {
+
+		var partition uint64
+		_, err := fmt.Sscanf(strings.TrimPrefix(info.Config.FilterSubject, prefix+"."), "%d.", &partition)
+		if err != nil {
+			continue
+		}
+
+		if info.AckFloor.Stream > floors[partition] {
+			floors[partition] = info.AckFloor.Stream
+		}
+	}
+
+	return floors
+}
+
+// GetRecords returns payloads of all records in snapshot of table, records are keyed by formatted primary key
+func (h *Harness) GetRecords(collection string, table string) map[string]map[string]interface{} {
+
+	h.t.Helper()
+
+	cs, err := h.snapshot.GetCollectionStore(collection)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	records := make(map[string]map[string]interface{})
+	err = snapshot.ScanSnapshot(cs.GetStore(), table, func(key []byte, record *gravity_sdk_types_snapshot_record.SnapshotRecord) error {
+
+		payload, _ := snapshot.GetValue(record.Payload).(map[string]interface{})
+		records[snapshot.FormatKey(key)] = payload
+
+		return nil
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	return records
+}
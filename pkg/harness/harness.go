@@ -0,0 +1,311 @@
+// Package harness runs an embedded JetStream server and the whole application in a temporary directory,
+// so tests can publish events of collections and assert on snapshots, RPC replies and streams of views end to end.
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/app"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/view_manager"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/fx"
+)
+
+const (
+	DefaultTimeout        = 10 * time.Second
+	DefaultPartitionCount = 4
+)
+
+type Harness struct {
+	t           testing.TB
+	dir         string
+	config      *configs.Config
+	server      *server.Server
+	conn        *nats.Conn
+	js          nats.JetStreamContext
+	app         *fx.App
+	snapshot    *snapshot.Snapshot
+	viewManager *view_manager.ViewManager
+	timeout     time.Duration
+
+	// The last sequence which was published to each partition of collections
+	published map[string]map[uint64]uint64
+	mutex     sync.Mutex
+}
+
+// New starts JetStream server in temporary directory, application is not started until Start is called,
+// so events can be published before snapshot is running. Everything is stopped when test finished.
+func New(t testing.TB, opts ...func(*Harness)) *Harness {
+
+	t.Helper()
+
+	h := &Harness{
+		t:         t,
+		dir:       t.TempDir(),
+		timeout:   DefaultTimeout,
+		published: make(map[string]map[uint64]uint64),
+	}
+
+	t.Cleanup(h.Close)
+
+	h.startServer()
+
+	// Settings for running in test
+	config := configs.NewDefaultConfig()
+	config.Gravity.Host = "127.0.0.1"
+	config.Gravity.Port = h.server.Addr().(*net.TCPAddr).Port
+	config.Datastore.Path = filepath.Join(h.dir, "data")
+	config.Backup.Path = filepath.Join(h.dir, "backups")
+	config.Export.Path = filepath.Join(h.dir, "exports")
+	config.Snapshot.PartitionCount = DefaultPartitionCount
+	config.HTTP.Enabled = false
+	config.Reload.Watch = false
+	config.Log.Level = "error"
+	h.config = config
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	err := config.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.connect()
+
+	return h
+}
+
+func (h *Harness) startServer() {
+
+	opts := &server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  filepath.Join(h.dir, "jetstream"),
+		NoLog:     true,
+		NoSigs:    true,
+	}
+
+	s, err := server.NewServer(opts)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	go s.Start()
+
+	if !s.ReadyForConnections(h.timeout) {
+		h.t.Fatal("JetStream server is not ready")
+	}
+
+	h.server = s
+}
+
+func (h *Harness) connect() {
+
+	conn, err := nats.Connect(h.server.ClientURL())
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	h.conn = conn
+	h.js = js
+}
+
+// Start runs the whole application with config of harness
+func (h *Harness) Start() {
+
+	h.t.Helper()
+
+	h.app = fx.New(
+		app.Options(h.config),
+		fx.Populate(&h.snapshot, &h.viewManager),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	err := h.app.Start(ctx)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// Stop stops application, JetStream server is still running so application can be started again
+func (h *Harness) Stop() {
+
+	if h.app == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	err := h.app.Stop(ctx)
+	if err != nil {
+		h.t.Error(err)
+	}
+
+	h.app = nil
+}
+
+// Close stops application and JetStream server
+func (h *Harness) Close() {
+
+	h.Stop()
+
+	if h.conn != nil {
+		h.conn.Close()
+	}
+
+	if h.server != nil {
+		h.server.Shutdown()
+		h.server.WaitForShutdown()
+	}
+}
+
+func (h *Harness) GetConfig() *configs.Config {
+	return h.config
+}
+
+func (h *Harness) GetDomain() string {
+	return h.config.Gravity.Domain
+}
+
+func (h *Harness) GetConnection() *nats.Conn {
+	return h.conn
+}
+
+func (h *Harness) GetJetStream() nats.JetStreamContext {
+	return h.js
+}
+
+// GetSnapshot returns snapshot module of application which is running
+func (h *Harness) GetSnapshot() *snapshot.Snapshot {
+	return h.snapshot
+}
+
+// GetViewManager returns view manager of application which is running
+func (h *Harness) GetViewManager() *view_manager.ViewManager {
+	return h.viewManager
+}
+
+// Request sends RPC request to application, reply is decoded into resp
+func (h *Harness) Request(apiPath string, req interface{}, resp interface{}) {
+
+	h.t.Helper()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	subject := fmt.Sprintf("$GRAVITY.%s.API.SNAPSHOT.%s", h.GetDomain(), apiPath)
+	msg, err := h.conn.Request(subject, data, h.timeout)
+	if err != nil {
+		h.t.Fatalf("Failed to request %s: %v", apiPath, err)
+	}
+
+	err = json.Unmarshal(msg.Data, resp)
+	if err != nil {
+		h.t.Fatalf("Failed to decode reply of %s: %v", apiPath, err)
+	}
+}
+
+// SubscribeView subscribes to subject which data of view is published to
+func (h *Harness) SubscribeView(viewID string) *nats.Subscription {
+
+	h.t.Helper()
+
+	sub, err := h.conn.SubscribeSync(view_manager.GetSubject(h.GetDomain(), viewID))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	return sub
+}
+
+// GetViewStreamInfo returns information of stream which was created for view
+func (h *Harness) GetViewStreamInfo(viewID string) *nats.StreamInfo {
+
+	h.t.Helper()
+
+	info, err := h.js.StreamInfo(view_manager.GetStreamName(h.GetDomain(), viewID))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	return info
+}
+
+// waitFor checks condition until it is satisfied or timeout
+func (h *Harness) waitFor(description string, fn func() bool) {
+
+	h.t.Helper()
+
+	deadline := time.Now().Add(h.timeout)
+	for !fn() {
+
+		if time.Now().After(deadline) {
+			h.t.Fatalf("Timeout waiting for %s", description)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// WithCollections specifies collections to be watched, they are consumed from the first event of streams
+// so events which were published before application started will be applied as well
+func WithCollections(collections ...string) func(*Harness) {
+	return func(h *Harness) {
+
+		h.config.Collections = collections
+
+		if h.config.Collection == nil {
+			h.config.Collection = make(map[string]*configs.CollectionConfig)
+		}
+
+		for _, name := range collections {
+
+			c, ok := h.config.Collection[name]
+			if !ok || c == nil {
+				c = &configs.CollectionConfig{}
+				h.config.Collection[name] = c
+			}
+
+			if len(c.StartPosition) == 0 {
+				c.StartPosition = configs.StartFirst
+			}
+		}
+	}
+}
+
+// WithConfig changes config before application is started
+func WithConfig(fn func(*configs.Config)) func(*Harness) {
+	return func(h *Harness) {
+		fn(h.config)
+	}
+}
+
+// WithTimeout specifies how long to wait for server, application and events
+func WithTimeout(timeout time.Duration) func(*Harness) {
+	return func(h *Harness) {
+		h.timeout = timeout
+	}
+}
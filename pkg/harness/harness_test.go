@@ -0,0 +1,57 @@
+package harness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+)
+
+func TestHarnessLifecycle(t *testing.T) {
+
+	var h *Harness
+	t.Run("run", func(t *testing.T) {
+
+		h = New(t, WithCollections("accounts"))
+
+		// Files of application are kept in temporary directory
+		for _, path := range []string{h.GetConfig().Datastore.Path, h.GetConfig().Backup.Path, h.GetConfig().Export.Path} {
+			if filepath.Dir(path) != h.dir {
+				t.Fatalf("Path %s is not in temporary directory %s", path, h.dir)
+			}
+		}
+
+		if !h.server.Running() {
+			t.Fatal("JetStream server is not running")
+		}
+
+		h.Publish("accounts", 0, NewRecord(gravity_sdk_types_record.Method_INSERT, "accounts", "id", map[string]interface{}{"id": "a"}))
+		h.Start()
+		h.WaitForApplied("accounts")
+		h.Stop()
+
+		// Server keeps running, so application can be started again with the same datastore
+		h.Publish("accounts", 1, NewRecord(gravity_sdk_types_record.Method_INSERT, "accounts", "id", map[string]interface{}{"id": "b"}))
+		h.Start()
+		h.WaitForApplied("accounts")
+
+		records := h.GetRecords("accounts", "accounts")
+		if len(records) != 2 || records["a"] == nil || records["b"] == nil {
+			t.Fatalf("Unexpected records: %v", records)
+		}
+	})
+
+	if h.server.Running() {
+		t.Fatal("JetStream server is still running after test")
+	}
+
+	if !h.conn.IsClosed() {
+		t.Fatal("Connection is still open after test")
+	}
+
+	_, err := os.Stat(h.dir)
+	if !os.IsNotExist(err) {
+		t.Fatalf("Temporary directory %s was not removed: %v", h.dir, err)
+	}
+}
@@ -0,0 +1,160 @@
+package harness
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/cluster"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/rpc"
+)
+
+func countLines(t *testing.T, path string) int {
+
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		count++
+	}
+
+	return count
+}
+
+func TestCollectionRPC(t *testing.T) {
+
+	h := New(t, WithCollections("accounts"))
+	for i := 0; i < 20; i++ {
+		h.Publish("accounts", uint64(i%2), insert("accounts", string(rune('a'+i)), "fred"))
+	}
+	h.Publish("accounts", 0, remove("accounts", "a"))
+
+	h.Start()
+	h.WaitForApplied("accounts")
+
+	var digest rpc.DigestCollectionReply
+	h.Request("COLLECTION.DIGEST", map[string]interface{}{"collection": "accounts"}, &digest)
+	if digest.Error != nil || digest.Digest == nil || digest.Digest.Count != 19 || len(digest.Digest.Tables) != 1 {
+		t.Fatalf("Unexpected digest: %+v, %+v", digest.Digest, digest.Error)
+	}
+
+	var verify rpc.VerifyCollectionReply
+	h.Request("COLLECTION.VERIFY", map[string]interface{}{"collection": "accounts"}, &verify)
+	if verify.Error != nil || !verify.Consistent || verify.Report.ComparedCount != 19 {
+		t.Fatalf("Unexpected verification: %+v, %+v", verify.Report, verify.Error)
+	}
+
+	var export rpc.ExportCollectionReply
+	h.Request("COLLECTION.EXPORT", map[string]interface{}{"collection": "accounts", "path": "accounts.jsonl", "format": "jsonl"}, &export)
+	if export.Error != nil || export.Count != 19 {
+		t.Fatalf("Unexpected export: %+v", export)
+	}
+
+	if !strings.HasPrefix(export.Path, h.GetConfig().Export.Path) {
+		t.Fatalf("File was exported out of export directory: %s", export.Path)
+	}
+
+	if n := countLines(t, export.Path); n != 19 {
+		t.Fatalf("Expected 19 lines in exported file, got %d", n)
+	}
+
+	// Path of output file is required
+	var rejected rpc.ExportCollectionReply
+	h.Request("COLLECTION.EXPORT", map[string]interface{}{"collection": "accounts", "path": "", "format": "jsonl"}, &rejected)
+	if rejected.Error == nil || rejected.Error.Code != 44400 {
+		t.Fatalf("Export without path should be rejected: %+v", rejected.Error)
+	}
+
+	var missing rpc.DigestCollectionReply
+	h.Request("COLLECTION.DIGEST", map[string]interface{}{"collection": "unknown"}, &missing)
+	if missing.Error == nil || missing.Error.Code != 44405 {
+		t.Fatalf("Digest of unknown collection should fail: %+v", missing.Error)
+	}
+}
+
+func TestClusterRPC(t *testing.T) {
+
+	h := New(t, WithCollections("accounts"), WithConfig(func(c *configs.Config) {
+		c.Cluster.Enabled = true
+		c.Cluster.LeaseTTL = 3
+	}))
+
+	for i := 0; i < 20; i++ {
+		h.Publish("accounts", uint64(i%DefaultPartitionCount), insert("accounts", string(rune('a'+i)), "fred"))
+	}
+
+	h.Start()
+	h.WaitForApplied("accounts")
+
+	nodeID, err := ioutil.ReadFile(filepath.Join(h.GetConfig().Datastore.Path, cluster.NodeIDFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests are sent to all instances and replies are merged
+	var digest rpc.DigestCollectionReply
+	h.Request("COLLECTION.DIGEST", map[string]interface{}{"collection": "accounts"}, &digest)
+	if digest.Error != nil || len(digest.Nodes) != 1 || digest.Digest.Count != 20 {
+		t.Fatalf("Unexpected digest: %+v, %+v", digest.Digest, digest.Error)
+	}
+
+	var verify rpc.VerifyCollectionReply
+	h.Request("COLLECTION.VERIFY", map[string]interface{}{"collection": "accounts"}, &verify)
+	if verify.Error != nil || len(verify.Nodes) != 1 || !verify.Consistent {
+		t.Fatalf("Unexpected verification: %+v, %+v", verify.Report, verify.Error)
+	}
+
+	var export rpc.ExportCollectionReply
+	h.Request("COLLECTION.EXPORT", map[string]interface{}{"collection": "accounts", "path": "accounts.jsonl", "format": "jsonl"}, &export)
+	if export.Error != nil || len(export.Nodes) != 1 || export.Count != 20 {
+		t.Fatalf("Unexpected export: %+v", export)
+	}
+
+	// Every instance writes its own file
+	path := filepath.Join(h.GetConfig().Export.Path, strings.TrimSpace(string(nodeID)), "accounts.jsonl")
+	if export.Nodes[0].Path != path {
+		t.Fatalf("Expected file %s, got %s", path, export.Nodes[0].Path)
+	}
+
+	var backup rpc.BackupReply
+	h.Request("BACKUP", map[string]interface{}{}, &backup)
+	if backup.Error != nil || len(backup.Nodes) != 1 || backup.Collections["accounts"] == nil {
+		t.Fatalf("Unexpected backup: %+v", backup)
+	}
+
+	// Node keeps its ID after restarting
+	h.Stop()
+	h.Start()
+	h.WaitForApplied("accounts")
+
+	data, err := ioutil.ReadFile(filepath.Join(h.GetConfig().Datastore.Path, cluster.NodeIDFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != string(nodeID) {
+		t.Fatalf("Node ID was changed from %s to %s", nodeID, data)
+	}
+
+	// Records of partition which was taken over by another instance are purged
+	count, err := h.GetSnapshot().PurgePartition(context.Background(), "accounts", 1)
+	if err != nil || count != 5 {
+		t.Fatalf("Expected 5 records purged, got %d: %v", count, err)
+	}
+
+	if records := h.GetRecords("accounts", "accounts"); len(records) != 15 {
+		t.Fatalf("Expected 15 records after purging, got %d", len(records))
+	}
+}
@@ -0,0 +1,221 @@
+package harness
+
+import (
+	"fmt"
+	"testing"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+)
+
+func insert(table string, id string, name string) *gravity_sdk_types_record.Record {
+	return NewRecord(gravity_sdk_types_record.Method_INSERT, table, "id", map[string]interface{}{"id": id, "name": name})
+}
+
+func update(table string, id string, name string) *gravity_sdk_types_record.Record {
+	return NewRecord(gravity_sdk_types_record.Method_UPDATE, table, "id", map[string]interface{}{"id": id, "name": name})
+}
+
+func remove(table string, id string) *gravity_sdk_types_record.Record {
+	return NewRecord(gravity_sdk_types_record.Method_DELETE, table, "id", map[string]interface{}{"id": id})
+}
+
+func getDurableName(h *Harness, collection string, partition uint64) string {
+	return fmt.Sprintf("%s-%s-%d-SNAPSHOT", h.GetDomain(), collection, partition)
+}
+
+func TestApplyEvents(t *testing.T) {
+
+	for name, workers := range map[string]int{"eventstore": 0, "workers": 2} {
+
+		workers := workers
+		t.Run(name, func(t *testing.T) {
+
+			h := New(t, WithCollections("accounts"), WithConfig(func(c *configs.Config) {
+				c.Collection["accounts"].Workers = workers
+			}))
+
+			for i := 0; i < 200; i++ {
+				h.Publish("accounts", uint64(i%DefaultPartitionCount), insert("accounts", fmt.Sprintf("%03d", i), "fred"))
+			}
+			h.Publish("accounts", 1, remove("accounts", "001"))
+
+			h.Start()
+			h.WaitForApplied("accounts")
+
+			records := h.GetRecords("accounts", "accounts")
+			if len(records) != 199 || records["001"] != nil {
+				t.Fatalf("Expected 199 records without deleted one, got %d", len(records))
+			}
+
+			// Events which were published while stopped are applied after restarting
+			h.Stop()
+			h.Publish("accounts", 2, update("accounts", "002", "wilma"))
+			h.Start()
+			h.WaitForApplied("accounts")
+
+			records = h.GetRecords("accounts", "accounts")
+			if len(records) != 199 || records["002"]["name"] != "wilma" || records["003"]["name"] != "fred" {
+				t.Fatalf("Unexpected records after restarting: %d, %v", len(records), records["002"])
+			}
+
+			// Events were acknowledged once and only after they were applied
+			for info := range h.GetJetStream().ConsumersInfo(snapshot.GetStreamName(h.GetDomain(), "accounts")) {
+				if info.NumAckPending != 0 || info.NumRedelivered != 0 {
+					t.Fatalf("Consumer %s has %d pending and %d redelivered", info.Name, info.NumAckPending, info.NumRedelivered)
+				}
+			}
+		})
+	}
+}
+
+func TestNestedTables(t *testing.T) {
+
+	h := New(t, WithCollections("accounts"))
+	h.Publish("accounts", 0, insert("user", "a", "fred"))
+	h.Publish("accounts", 0, insert("user-profile", "b", "wilma"))
+
+	h.Start()
+	h.WaitForApplied("accounts")
+
+	// Records of "user-profile" are not in range of table "user"
+	if records := h.GetRecords("accounts", "user"); len(records) != 1 || records["a"] == nil {
+		t.Fatalf("Unexpected records of user: %v", records)
+	}
+
+	if records := h.GetRecords("accounts", "user-profile"); len(records) != 1 || records["b"] == nil {
+		t.Fatalf("Unexpected records of user-profile: %v", records)
+	}
+}
+
+func TestTombstones(t *testing.T) {
+
+	h := New(t, WithCollections("accounts"), WithConfig(func(c *configs.Config) {
+		c.Collection["accounts"].Tombstone = configs.TombstoneKeep
+	}))
+
+	for i := 0; i < 4; i++ {
+		h.Publish("accounts", 0, insert("accounts", fmt.Sprintf("%d", i), "fred"))
+	}
+	h.Publish("accounts", 0, remove("accounts", "0"))
+
+	h.Start()
+	h.WaitForApplied("accounts")
+
+	if records := h.GetRecords("accounts", "accounts"); len(records) != 3 || records["0"] != nil {
+		t.Fatalf("Tombstone was read as record: %v", records)
+	}
+
+	var reply struct {
+		Digest *snapshot.CollectionDigest `json:"digest"`
+	}
+	h.Request("COLLECTION.DIGEST", map[string]interface{}{"collection": "accounts"}, &reply)
+	if reply.Digest == nil || reply.Digest.Count != 3 {
+		t.Fatalf("Tombstone was counted by digest: %+v", reply.Digest)
+	}
+
+	// Record is alive again
+	h.Publish("accounts", 0, insert("accounts", "0", "barney"))
+	h.WaitForApplied("accounts")
+
+	if records := h.GetRecords("accounts", "accounts"); len(records) != 4 || records["0"]["name"] != "barney" {
+		t.Fatalf("Record was not recreated: %v", records)
+	}
+}
+
+func TestSeedRevision(t *testing.T) {
+
+	h := New(t, WithCollections("accounts"))
+	for i := 0; i < 5; i++ {
+		h.Publish("accounts", 0, insert("accounts", fmt.Sprintf("%d", i), "fred"))
+	}
+
+	h.Start()
+	h.WaitForApplied("accounts")
+	h.Stop()
+
+	es, err := snapshot.OpenDatastore(h.GetConfig().Datastore.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := es.GetStore("accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = snapshot.SetSeedRevision(store, 3)
+	es.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Start()
+
+	stream := snapshot.GetStreamName(h.GetDomain(), "accounts")
+	info, err := h.GetJetStream().ConsumerInfo(stream, getDurableName(h, "accounts", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Config.OptStartSeq != 4 {
+		t.Fatalf("Consumer should start after seed revision, got %d", info.Config.OptStartSeq)
+	}
+
+	// Consumer is recreated only once for the same seed
+	created := info.Created
+	h.Stop()
+	h.Start()
+
+	info, err = h.GetJetStream().ConsumerInfo(stream, getDurableName(h, "accounts", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.Created.Equal(created) {
+		t.Fatalf("Consumer was recreated again at %v", info.Created)
+	}
+}
+
+func TestRestorePositions(t *testing.T) {
+
+	h := New(t, WithCollections("accounts"))
+	h.Publish("accounts", 0, insert("accounts", "a", "fred"))
+
+	h.Start()
+	h.WaitForApplied("accounts")
+	h.Stop()
+
+	es, err := snapshot.OpenDatastore(h.GetConfig().Datastore.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := es.GetStore("accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = snapshot.SetRestorePositions(store, map[uint64]uint64{0: 1})
+	es.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Start()
+
+	// Partitions which had nothing applied start from the first event
+	stream := snapshot.GetStreamName(h.GetDomain(), "accounts")
+	for partition, expected := range map[uint64]uint64{0: 2, 1: 1, 2: 1, 3: 1} {
+
+		info, err := h.GetJetStream().ConsumerInfo(stream, getDurableName(h, "accounts", partition))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Config.OptStartSeq != expected {
+			t.Fatalf("Consumer of partition %d should start from %d, got %d", partition, expected, info.Config.OptStartSeq)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package harness
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/rpc"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/view_manager"
+	"github.com/nats-io/nats.go"
+)
+
+// receiveView returns messages which were published to view
+func receiveView(t *testing.T, sub *nats.Subscription, count int) []*nats.Msg {
+
+	t.Helper()
+
+	msgs := make([]*nats.Msg, 0, count)
+	for i := 0; i < count; i++ {
+		msg, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatalf("Expected %d messages of view, got %d: %v", count, i, err)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	return msgs
+}
+
+func TestViewPull(t *testing.T) {
+
+	h := New(t, WithCollections("accounts"), WithConfig(func(c *configs.Config) {
+		c.View.BatchSize = 4
+	}))
+
+	for i := 0; i < 5; i++ {
+		h.Publish("accounts", 0, insert("accounts", string(rune('a'+i)), "fred"))
+	}
+	h.Publish("accounts", 0, insert("accounts-x", "z", "wilma"))
+
+	h.Start()
+	h.WaitForApplied("accounts")
+
+	var view rpc.CreateSnapshotViewReply
+	h.Request("VIEW.CREATE", map[string]interface{}{"collection": "accounts", "subscriber": "tester"}, &view)
+	if len(view.ID) == 0 || view.Collection != "accounts" {
+		t.Fatalf("Unexpected view: %+v", view)
+	}
+
+	sub := h.SubscribeView(view.ID)
+
+	// The first batch
+	var pull rpc.PullSnapshotViewReply
+	h.Request("VIEW.PULL", map[string]interface{}{"id": view.ID}, &pull)
+	if pull.Count != 4 {
+		t.Fatalf("Expected 4 records in the first batch, got %d", pull.Count)
+	}
+
+	msgs := receiveView(t, sub, 4)
+	for i, msg := range msgs {
+
+		key, err := base64.StdEncoding.DecodeString(msg.Header.Get(view_manager.HeaderKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if msg.Header.Get(view_manager.HeaderTable) != "accounts" || string(key) != "accounts-"+string(rune('a'+i)) {
+			t.Fatalf("Unexpected message %d of view: %v, %s", i, msg.Header, key)
+		}
+
+		record := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+		err = gravity_sdk_types_snapshot_record.Unmarshal(msg.Data, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		payload, _ := snapshot.GetValue(record.Payload).(map[string]interface{})
+		if payload["name"] != "fred" {
+			t.Fatalf("Unexpected payload of message %d: %v", i, payload)
+		}
+	}
+
+	// The rest after the last key
+	lastKey := msgs[len(msgs)-1].Header.Get(view_manager.HeaderKey)
+	h.Request("VIEW.PULL", map[string]interface{}{"id": view.ID, "lastKey": lastKey, "afterLastKey": true}, &pull)
+	if pull.Count != 2 {
+		t.Fatalf("Expected 2 records in the last batch, got %d", pull.Count)
+	}
+
+	msgs = receiveView(t, sub, 2)
+	if table := msgs[1].Header.Get(view_manager.HeaderTable); table != "accounts-x" {
+		t.Fatalf("Expected record of nested table, got %s", table)
+	}
+
+	// Messages are kept by stream of view
+	if info := h.GetViewStreamInfo(view.ID); info.State.Msgs != 6 {
+		t.Fatalf("Expected 6 messages in stream of view, got %d", info.State.Msgs)
+	}
+
+	var deleted rpc.DeleteSnapshotViewReply
+	h.Request("VIEW.DELETE", map[string]interface{}{"id": view.ID}, &deleted)
+
+	var missing rpc.ErrorReply
+	h.Request("VIEW.PULL", map[string]interface{}{"id": view.ID}, &missing)
+	if missing.Error == nil || missing.Error.Code != 44404 {
+		t.Fatalf("Pulling deleted view should fail: %+v", missing.Error)
+	}
+}
+
+func TestViewCache(t *testing.T) {
+
+	h := New(t, WithCollections("accounts"))
+	h.Start()
+
+	var view rpc.CreateSnapshotViewReply
+	h.Request("VIEW.CREATE", map[string]interface{}{"collection": "accounts", "subscriber": "tester"}, &view)
+
+	if v, _ := h.GetViewManager().GetView(view.ID); v == nil {
+		t.Fatal("View was not found")
+	}
+
+	// View which was deleted by another instance is dropped from cache
+	kv, err := h.GetJetStream().KeyValue(view_manager.GetBucketName(h.GetDomain()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = kv.Delete(view.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.waitFor("view to be dropped from cache", func() bool {
+		v, _ := h.GetViewManager().GetView(view.ID)
+		return v == nil
+	})
+}
@@ -0,0 +1,191 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/http_server"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+type Check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type Report struct {
+	Status string   `json:"status"`
+	Checks []*Check `json:"checks"`
+}
+
+func (report *Report) add(name string, err error) {
+
+	check := &Check{
+		Name:   name,
+		Status: StatusOK,
+	}
+
+	if err != nil {
+		check.Status = StatusFail
+		check.Message = err.Error()
+		report.Status = StatusFail
+	}
+
+	report.Checks = append(report.Checks, check)
+}
+
+// IsHealthy returns true if all checks were passed
+func (report *Report) IsHealthy() bool {
+	return report.Status == StatusOK
+}
+
+type Health struct {
+	config    *configs.HealthConfig
+	connector *connector.Connector
+	snapshot  *snapshot.Snapshot
+}
+
+func New(config *configs.Config, l *zap.Logger, c *connector.Connector, s *snapshot.Snapshot, server *http_server.HTTPServer) *Health {
+
+	logger = l.Named("Health")
+
+	h := &Health{
+		config:    &config.Health,
+		connector: c,
+		snapshot:  s,
+	}
+
+	server.HandleFunc("/healthz", h.serve(h.Liveness))
+	server.HandleFunc("/readyz", h.serve(h.Readiness))
+
+	return h
+}
+
+func newReport() *Report {
+	return &Report{
+		Status: StatusOK,
+		Checks: make([]*Check, 0),
+	}
+}
+
+// Liveness reports whether process should be restarted
+func (h *Health) Liveness() *Report {
+
+	report := newReport()
+	report.add("connection", h.checkClosed())
+
+	return report
+}
+
+// Readiness reports whether snapshot is able to serve requests
+func (h *Health) Readiness() *Report {
+
+	report := newReport()
+	report.add("connection", h.checkConnection())
+	report.add("pipeline", h.checkPipeline())
+	report.add("store", h.checkStore())
+	report.add("consumerLag", h.checkConsumerLag())
+
+	if h.config.RequireCaughtUp {
+		report.add("caughtUp", h.checkCaughtUp())
+	}
+
+	return report
+}
+
+func (h *Health) checkClosed() error {
+
+	if h.connector.IsClosed() {
+		return fmt.Errorf("Connection to Gravity Network was closed")
+	}
+
+	return nil
+}
+
+func (h *Health) checkConnection() error {
+
+	if !h.connector.IsConnected() {
+		return fmt.Errorf("Disconnected from Gravity Network (%s)", h.connector.GetState())
+	}
+
+	return nil
+}
+
+func (h *Health) checkPipeline() error {
+
+	if paused, reason := h.snapshot.IsPipelinePaused(); paused {
+		return fmt.Errorf("Applying events is paused (%s)", reason)
+	}
+
+	return nil
+}
+
+func (h *Health) checkStore() error {
+
+	if !h.snapshot.IsStoreOpened() {
+		return fmt.Errorf("Store is not opened")
+	}
+
+	return nil
+}
+
+func (h *Health) checkConsumerLag() error {
+
+	maxLag := h.config.MaxConsumerLag
+
+	// Lag is not limited
+	if maxLag == 0 {
+		return nil
+	}
+
+	lags, err := h.snapshot.GetConsumerLags()
+	if err != nil {
+		return err
+	}
+
+	for collection, lag := range lags {
+		if lag > maxLag {
+			return fmt.Errorf("Consumer lag of collection %s is %d, which is over %d", collection, lag, maxLag)
+		}
+	}
+
+	return nil
+}
+
+func (h *Health) checkCaughtUp() error {
+
+	if !h.snapshot.IsCaughtUp() {
+		return fmt.Errorf("Collections have not caught up with streams yet")
+	}
+
+	return nil
+}
+
+func (h *Health) serve(fn func() *Report) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		report := fn()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !report.IsHealthy() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		err := json.NewEncoder(w).Encode(report)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
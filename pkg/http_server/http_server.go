@@ -0,0 +1,90 @@
+package http_server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+type HTTPServer struct {
+	config *configs.HTTPConfig
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger) *HTTPServer {
+
+	logger = l.Named("HTTPServer")
+
+	s := &HTTPServer{
+		config: &config.HTTP,
+		mux:    http.NewServeMux(),
+	}
+
+	lifecycle.Append(
+		fx.Hook{
+			OnStart: func(context.Context) error {
+				return s.start()
+			},
+			OnStop: func(ctx context.Context) error {
+
+				if s.server == nil {
+					return nil
+				}
+
+				logger.Info("Stopping HTTP server...")
+
+				return s.server.Shutdown(ctx)
+			},
+		},
+	)
+
+	return s
+}
+
+func (s *HTTPServer) start() error {
+
+	if !s.config.Enabled {
+		return nil
+	}
+
+	address := s.config.Address
+
+	// Listen before returning so errors of address can be reported on startup
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Starting HTTP server",
+		zap.String("address", listener.Addr().String()),
+	)
+
+	s.server = &http.Server{
+		Handler: s.mux,
+	}
+
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(err.Error())
+		}
+	}()
+
+	return nil
+}
+
+func (s *HTTPServer) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+func (s *HTTPServer) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, h)
+}
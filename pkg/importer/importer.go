@@ -0,0 +1,245 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+type Importer struct {
+	format     string
+	table      string
+	primaryKey string
+	revision   uint64
+	inferTypes bool
+	handler    *snapshot.SnapshotHandler
+}
+
+type Result struct {
+	Count   int
+	Skipped int
+}
+
+func NewImporter(format string, opts ...func(*Importer)) (*Importer, error) {
+
+	switch format {
+	case FormatJSONL, FormatCSV:
+	default:
+		return nil, fmt.Errorf("Unsupported format: %s", format)
+	}
+
+	im := &Importer{
+		format:  format,
+		handler: snapshot.NewSnapshotHandler(),
+	}
+
+	for _, opt := range opts {
+		opt(im)
+	}
+
+	if len(im.table) == 0 {
+		return nil, errors.New("Table is required")
+	}
+
+	if len(im.primaryKey) == 0 {
+		return nil, errors.New("Primary key is required")
+	}
+
+	return im, nil
+}
+
+// Import writes all rows from reader to snapshot of store, then records revision for consumers to resume from
+func (im *Importer) Import(store *eventstore.Store, r io.Reader) (*Result, error) {
+
+	// Digest of existing records should be ready before records are imported
+	_, err := snapshot.AssertDigest(store)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+
+	fn := func(row map[string]interface{}) error {
+
+		// Ignore row which has no primary key
+		if v, ok := row[im.primaryKey]; !ok || v == nil {
+			result.Skipped++
+			return nil
+		}
+
+		err := im.write(store, row)
+		if err != nil {
+			return err
+		}
+
+		result.Count++
+
+		return nil
+	}
+
+	switch im.format {
+	case FormatJSONL:
+		err = im.readJSONL(r, fn)
+	case FormatCSV:
+		err = im.readCSV(r, fn)
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	if im.revision == 0 {
+		return result, nil
+	}
+
+	return result, snapshot.SetSeedRevision(store, im.revision)
+}
+
+func (im *Importer) write(store *eventstore.Store, row map[string]interface{}) error {
+
+	record := &gravity_sdk_types_record.Record{
+		Table:      im.table,
+		Method:     gravity_sdk_types_record.Method_INSERT,
+		PrimaryKey: im.primaryKey,
+	}
+
+	err := gravity_sdk_types_record.UnmarshalMapData(row, record)
+	if err != nil {
+		return err
+	}
+
+	data, err := gravity_sdk_types_record.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return im.handler.Apply(store, im.revision, data, nil)
+}
+
+func (im *Importer) readJSONL(r io.Reader, fn func(map[string]interface{}) error) error {
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	decoder.UseNumber()
+
+	for {
+
+		var row map[string]interface{}
+		err := decoder.Decode(&row)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = fn(row)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (im *Importer) readCSV(r io.Reader, fn func(map[string]interface{}) error) error {
+
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.ReuseRecord = true
+
+	// Header
+	columns, err := reader.Read()
+	if err != nil {
+		return err
+	}
+
+	header := make([]string, len(columns))
+	copy(header, columns)
+
+	for {
+
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		row := make(map[string]interface{}, len(header))
+		for i, name := range header {
+
+			if i >= len(values) {
+				break
+			}
+
+			row[name] = im.parseCSVValue(values[i])
+		}
+
+		err = fn(row)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (im *Importer) parseCSVValue(value string) interface{} {
+
+	if !im.inferTypes {
+		return value
+	}
+
+	if len(value) == 0 {
+		return nil
+	}
+
+	if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return v
+	}
+
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		return v
+	}
+
+	if v, err := strconv.ParseBool(value); err == nil {
+		return v
+	}
+
+	return value
+}
+
+func WithTable(table string) func(*Importer) {
+	return func(im *Importer) {
+		im.table = table
+	}
+}
+
+func WithPrimaryKey(primaryKey string) func(*Importer) {
+	return func(im *Importer) {
+		im.primaryKey = primaryKey
+	}
+}
+
+func WithRevision(revision uint64) func(*Importer) {
+	return func(im *Importer) {
+		im.revision = revision
+	}
+}
+
+func WithTypeInference(enabled bool) func(*Importer) {
+	return func(im *Importer) {
+		im.inferTypes = enabled
+	}
+}
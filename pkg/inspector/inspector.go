@@ -0,0 +1,109 @@
+package inspector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+)
+
+// Inspector opens all stores in datastore read-only without connecting to Gravity
+type Inspector struct {
+	path   string
+	stores map[string]*Store
+}
+
+func Open(path string) (*Inspector, error) {
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	inspector := &Inspector{
+		path:   path,
+		stores: make(map[string]*Store),
+	}
+
+	for _, entry := range entries {
+
+		if !entry.IsDir() {
+			continue
+		}
+
+		dbPath := filepath.Join(path, entry.Name())
+
+		// Ignore directory which is not a store with snapshot
+		if _, err := os.Stat(filepath.Join(dbPath, "snapshot")); err != nil {
+			continue
+		}
+
+		store, err := OpenStore(dbPath)
+		if err != nil {
+			inspector.Close()
+			return nil, fmt.Errorf("Failed to open store \"%s\": %v", entry.Name(), err)
+		}
+
+		inspector.stores[entry.Name()] = store
+	}
+
+	return inspector, nil
+}
+
+func (inspector *Inspector) Close() {
+	for _, store := range inspector.stores {
+		store.Close()
+	}
+}
+
+func (inspector *Inspector) Collections() []string {
+
+	names := make([]string, 0, len(inspector.stores))
+	for name := range inspector.stores {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func (inspector *Inspector) GetStore(collection string) (*Store, error) {
+
+	store, ok := inspector.stores[collection]
+	if !ok {
+		return nil, fmt.Errorf("Not found collection: %s", collection)
+	}
+
+	return store, nil
+}
+
+// FormatKey shows key as string if it is printable, otherwise hex string with "0x" prefix
+func FormatKey(key []byte) string {
+	return snapshot.FormatKey(key)
+}
+
+// RecordToMap converts snapshot record to map which can be encoded as JSON
+func RecordToMap(collection string, table string, key []byte, data []byte) (map[string]interface{}, error) {
+
+	record, err := DecodeRecord(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta map[string]interface{}
+	if record.Meta != nil {
+		meta = record.Meta.AsMap()
+	}
+
+	return map[string]interface{}{
+		"collection": collection,
+		"table":      table,
+		"key":        FormatKey(key),
+		"meta":       meta,
+		"payload":    snapshot.GetValue(record.Payload),
+	}, nil
+}
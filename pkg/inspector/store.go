@@ -0,0 +1,242 @@
+package inspector
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/cockroachdb/pebble"
+)
+
+type Store struct {
+	name     string
+	snapshot *pebble.DB
+	states   *pebble.DB
+}
+
+type Table struct {
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+	Revision uint64 `json:"revision"`
+}
+
+func openDatabase(path string) (*pebble.DB, error) {
+
+	handler := snapshot.NewSnapshotHandler()
+
+	opts := &pebble.Options{
+		ReadOnly: true,
+		Merger: &pebble.Merger{
+			Merge: func(key []byte, value []byte) (pebble.ValueMerger, error) {
+				m := &eventstore.Merger{}
+				m.SetHandler(handler.MergeData)
+				return m, m.MergeNewer(value)
+			},
+		},
+	}
+
+	return pebble.Open(path, opts)
+}
+
+func OpenStore(dbPath string) (*Store, error) {
+
+	store := &Store{
+		name: filepath.Base(dbPath),
+	}
+
+	db, err := openDatabase(filepath.Join(dbPath, "snapshot"))
+	if err != nil {
+		return nil, err
+	}
+
+	store.snapshot = db
+
+	db, err = openDatabase(filepath.Join(dbPath, "snapshot_states"))
+	if err != nil {
+		store.snapshot.Close()
+		return nil, err
+	}
+
+	store.states = db
+
+	return store, nil
+}
+
+func (store *Store) Close() {
+	store.snapshot.Close()
+	store.states.Close()
+}
+
+func (store *Store) GetName() string {
+	return store.name
+}
+
+// tableNames returns names of all tables which have snapshot state
+func (store *Store) tableNames() []string {
+
+	iter := store.states.NewIter(nil)
+	defer iter.Close()
+
+	names := make([]string, 0)
+	for iter.First(); iter.Valid(); iter.Next() {
+
+		key := string(iter.Key())
+		if strings.HasSuffix(key, "-seq") {
+			names = append(names, strings.TrimSuffix(key, "-seq"))
+		}
+	}
+
+	return names
+}
+
+// Tables returns all tables which have snapshot state
+func (store *Store) Tables() ([]*Table, error) {
+
+	iter := store.states.NewIter(nil)
+	defer iter.Close()
+
+	tables := make([]*Table, 0)
+	for iter.First(); iter.Valid(); iter.Next() {
+
+		key := string(iter.Key())
+		if !strings.HasSuffix(key, "-seq") {
+			continue
+		}
+
+		tables = append(tables, &Table{
+			Name:     strings.TrimSuffix(key, "-seq"),
+			Revision: eventstore.BytesToUint64(iter.Value()),
+		})
+	}
+
+	for _, table := range tables {
+		count, err := store.Count(table.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		table.Count = count
+	}
+
+	return tables, nil
+}
+
+func (store *Store) Count(table string) (int, error) {
+
+	count := 0
+	err := store.Scan(table, nil, func(key []byte, value []byte) error {
+		count++
+		return nil
+	})
+
+	return count, err
+}
+
+func getTablePrefix(table string) []byte {
+	return []byte(table + "-")
+}
+
+func getUpperBound(prefix []byte) []byte {
+
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i] = end[i] + 1
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// Get returns encoded snapshot record of specific primary key
+func (store *Store) Get(table string, key []byte) ([]byte, error) {
+
+	value, closer, err := store.snapshot.Get(append(getTablePrefix(table), key...))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer closer.Close()
+
+	if snapshot.IsTombstoneData(value) {
+		return nil, nil
+	}
+
+	data := make([]byte, len(value))
+	copy(data, value)
+
+	return data, nil
+}
+
+// Scan iterates records of table which primary key has specific prefix
+func (store *Store) Scan(table string, prefix []byte, fn func([]byte, []byte) error) error {
+
+	tablePrefix := getTablePrefix(table)
+	keyPrefix := append(tablePrefix, prefix...)
+	filter := snapshot.NewTableKeyFilter(table, store.tableNames())
+
+	iter := store.snapshot.NewIter(&pebble.IterOptions{
+		LowerBound: keyPrefix,
+		UpperBound: getUpperBound(keyPrefix),
+	})
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+
+		// Record of another table which has the same prefix
+		key := bytes.TrimPrefix(iter.Key(), tablePrefix)
+		if !filter(key) {
+			continue
+		}
+
+		// Record was deleted but kept as tombstone
+		if snapshot.IsTombstoneData(iter.Value()) {
+			continue
+		}
+
+		err := fn(key, iter.Value())
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ScanPayloads iterates payloads of all records of table, it can be used as source of exporter
+func (store *Store) ScanPayloads(table string, fn func(*gravity_sdk_types_record.Value) error) error {
+	return store.Scan(table, nil, func(key []byte, data []byte) error {
+
+		record, err := DecodeRecord(data)
+		if err != nil {
+			return err
+		}
+
+		return fn(record.Payload)
+	})
+}
+
+func DecodeRecord(data []byte) (*gravity_sdk_types_snapshot_record.SnapshotRecord, error) {
+
+	record := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+	err := gravity_sdk_types_snapshot_record.Unmarshal(data, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -0,0 +1,165 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelChange describes level of logger which was changed at runtime
+type LevelChange struct {
+	Logger   string     `json:"logger"`
+	Level    string     `json:"level"`
+	Previous string     `json:"previous"`
+	RevertAt *time.Time `json:"revertAt,omitempty"`
+}
+
+type revert struct {
+	timer    *time.Timer
+	previous *zapcore.Level
+}
+
+var reverts = make(map[string]*revert)
+var revertMutex sync.Mutex
+
+// ChangeLevel changes level of logger at runtime, empty name for loggers which have no level of their own.
+// Level is reverted after duration unless duration is zero.
+func ChangeLevel(name string, levelName string, duration time.Duration) (*LevelChange, error) {
+
+	var level zapcore.Level
+	err := level.UnmarshalText([]byte(levelName))
+	if err != nil {
+		return nil, fmt.Errorf("Unsupported level: %s", levelName)
+	}
+
+	// Names of loggers are case-insensitive
+	name = strings.ToLower(name)
+
+	revertMutex.Lock()
+	defer revertMutex.Unlock()
+
+	// Level which was set before changes is restored, even though level was changed several times
+	previous := getLevel(name)
+	if r, ok := reverts[name]; ok {
+		r.timer.Stop()
+		previous = r.previous
+		delete(reverts, name)
+	}
+
+	setLevel(name, &level)
+
+	change := &LevelChange{
+		Logger:   name,
+		Level:    level.String(),
+		Previous: levelString(previous),
+	}
+
+	if duration > 0 {
+		revertAt := time.Now().Add(duration)
+		change.RevertAt = &revertAt
+
+		r := &revert{
+			previous: previous,
+		}
+
+		r.timer = time.AfterFunc(duration, func() {
+
+			revertMutex.Lock()
+			defer revertMutex.Unlock()
+
+			// Level was changed again
+			if reverts[name] != r {
+				return
+			}
+
+			delete(reverts, name)
+			setLevel(name, r.previous)
+
+			zap.L().Info("Level of logger was reverted",
+				zap.String("logger", name),
+				zap.String("level", levelString(r.previous)),
+			)
+		})
+
+		reverts[name] = r
+	}
+
+	zap.L().Info("Level of logger was changed",
+		zap.String("logger", name),
+		zap.String("level", change.Level),
+		zap.String("previous", change.Previous),
+		zap.Duration("duration", duration),
+	)
+
+	return change, nil
+}
+
+// reloadLevels replaces levels of all loggers by config, levels which were changed at runtime are not reverted later
+// because they would overwrite levels of config
+func reloadLevels(config *configs.LogConfig) {
+
+	revertMutex.Lock()
+	defer revertMutex.Unlock()
+
+	for name, r := range reverts {
+		r.timer.Stop()
+		delete(reverts, name)
+	}
+
+	setupLevels(config)
+}
+
+// GetLevels returns level of all loggers with empty name, and levels of specific loggers
+func GetLevels() map[string]string {
+
+	result := map[string]string{
+		"": levels.GetLevel().String(),
+	}
+
+	for name, level := range levels.GetNamedLevels() {
+		result[name] = level.String()
+	}
+
+	return result
+}
+
+// getLevel returns level of logger, nil if logger has no level of its own
+func getLevel(name string) *zapcore.Level {
+
+	if len(name) == 0 {
+		level := levels.GetLevel()
+		return &level
+	}
+
+	level, ok := levels.GetNamedLevels()[name]
+	if !ok {
+		return nil
+	}
+
+	return &level
+}
+
+// setLevel changes level of logger, logger takes level of its parent if level is nil
+func setLevel(name string, level *zapcore.Level) {
+
+	if len(name) == 0 {
+		levels.SetLevel(*level)
+		return
+	}
+
+	levels.SetNamedLevel(name, level)
+}
+
+func levelString(level *zapcore.Level) string {
+
+	if level == nil {
+		return ""
+	}
+
+	return level.String()
+}
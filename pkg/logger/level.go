@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Levels keeps level of all loggers and levels of specific loggers by name, they can be changed at runtime
+type Levels struct {
+	root  zap.AtomicLevel
+	named map[string]zapcore.Level
+	mutex sync.RWMutex
+
+	// Levels resolved by logger name, it is cleared whenever levels of specific loggers were changed
+	cache sync.Map
+}
+
+// resolvedLevel is level of logger, root level is taken if logger and its parents have no level of their own
+type resolvedLevel struct {
+	level zapcore.Level
+	named bool
+}
+
+func NewLevels() *Levels {
+	return &Levels{
+		root:  zap.NewAtomicLevel(),
+		named: make(map[string]zapcore.Level),
+	}
+}
+
+// SetLevel changes level of loggers which have no level of their own
+func (ls *Levels) SetLevel(level zapcore.Level) {
+	ls.root.SetLevel(level)
+}
+
+// GetLevel returns level of loggers which have no level of their own
+func (ls *Levels) GetLevel() zapcore.Level {
+	return ls.root.Level()
+}
+
+// SetNamedLevels replaces levels of specific loggers, names are case-insensitive
+func (ls *Levels) SetNamedLevels(levels map[string]zapcore.Level) {
+
+	named := make(map[string]zapcore.Level, len(levels))
+	for name, level := range levels {
+		named[strings.ToLower(name)] = level
+	}
+
+	ls.mutex.Lock()
+	ls.named = named
+	ls.clearCache()
+	ls.mutex.Unlock()
+}
+
+// SetNamedLevel changes level of specific logger, level of logger is removed if level is nil
+func (ls *Levels) SetNamedLevel(name string, level *zapcore.Level) {
+
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	defer ls.clearCache()
+
+	if level == nil {
+		delete(ls.named, strings.ToLower(name))
+		return
+	}
+
+	ls.named[strings.ToLower(name)] = *level
+}
+
+// GetNamedLevels returns levels of specific loggers
+func (ls *Levels) GetNamedLevels() map[string]zapcore.Level {
+
+	ls.mutex.RLock()
+	defer ls.mutex.RUnlock()
+
+	levels := make(map[string]zapcore.Level, len(ls.named))
+	for name, level := range ls.named {
+		levels[name] = level
+	}
+
+	return levels
+}
+
+// LevelOf returns level of logger, level of parent is used for child loggers such as "Snapshot.Handler"
+func (ls *Levels) LevelOf(name string) zapcore.Level {
+
+	if v, ok := ls.cache.Load(name); ok {
+		return ls.levelOf(v.(resolvedLevel))
+	}
+
+	// Cache is stored with the lock held, so it cannot be cleared in the meantime
+	ls.mutex.RLock()
+	defer ls.mutex.RUnlock()
+
+	resolved := ls.resolve(name)
+	ls.cache.Store(name, resolved)
+
+	return ls.levelOf(resolved)
+}
+
+func (ls *Levels) levelOf(resolved resolvedLevel) zapcore.Level {
+
+	if resolved.named {
+		return resolved.level
+	}
+
+	return ls.root.Level()
+}
+
+func (ls *Levels) resolve(name string) resolvedLevel {
+
+	name = strings.ToLower(name)
+	for len(name) > 0 {
+
+		if level, ok := ls.named[name]; ok {
+			return resolvedLevel{level: level, named: true}
+		}
+
+		i := strings.LastIndex(name, ".")
+		if i == -1 {
+			break
+		}
+
+		name = name[:i]
+	}
+
+	return resolvedLevel{}
+}
+
+// clearCache should be called with the lock held
+func (ls *Levels) clearCache() {
+	ls.cache.Range(func(key interface{}, value interface{}) bool {
+		ls.cache.Delete(key)
+		return true
+	})
+}
+
+// Enabled returns true if level is enabled by any of loggers
+func (ls *Levels) Enabled(level zapcore.Level) bool {
+
+	if ls.root.Enabled(level) {
+		return true
+	}
+
+	ls.mutex.RLock()
+	defer ls.mutex.RUnlock()
+
+	for _, l := range ls.named {
+		if l.Enabled(level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// namedCore filters entries by level of logger which wrote them
+type namedCore struct {
+	zapcore.Core
+	levels *Levels
+}
+
+func newNamedCore(core zapcore.Core, levels *Levels) zapcore.Core {
+	return &namedCore{
+		Core:   core,
+		levels: levels,
+	}
+}
+
+func (c *namedCore) Enabled(level zapcore.Level) bool {
+	return c.levels.Enabled(level)
+}
+
+func (c *namedCore) With(fields []zapcore.Field) zapcore.Core {
+	return newNamedCore(c.Core.With(fields), c.levels)
+}
+
+func (c *namedCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+
+	if !c.levels.LevelOf(entry.LoggerName).Enabled(entry.Level) {
+		return ce
+	}
+
+	return c.Core.Check(entry, ce)
+}
@@ -3,11 +3,23 @@ package logger
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/reloader"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Levels of all loggers, they can be changed at runtime
+var levels = NewLevels()
+
 func NewCustomEncoderConfig() zapcore.EncoderConfig {
 	return zapcore.EncoderConfig{
 		TimeKey:        "ts",
@@ -25,33 +37,109 @@ func NewCustomEncoderConfig() zapcore.EncoderConfig {
 	}
 }
 
-func GetLogger() *zap.Logger {
-	debugLevel := setupLevel()
-	core := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(NewCustomEncoderConfig()),
-		zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout)),
-		debugLevel,
-	)
+// NewJSONEncoderConfig returns settings of encoder for log pipeline, time is in ISO8601 and durations are in seconds
+func NewJSONEncoderConfig() zapcore.EncoderConfig {
+
+	config := NewCustomEncoderConfig()
+	config.EncodeLevel = zapcore.LowercaseLevelEncoder
+	config.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	return config
+}
+
+func newEncoder(format string, colored bool) zapcore.Encoder {
+
+	if format == FormatJSON {
+		return zapcore.NewJSONEncoder(NewJSONEncoderConfig())
+	}
+
+	config := NewCustomEncoderConfig()
+	if !colored {
+		config.EncodeLevel = zapcore.CapitalLevelEncoder
+	}
+
+	return zapcore.NewConsoleEncoder(config)
+}
+
+func GetLogger(config *configs.Config) *zap.Logger {
+
+	setupLevels(&config.Log)
+
+	// Levels are decided by named core, so entries of all levels are accepted here
+	cores := []zapcore.Core{
+		zapcore.NewCore(
+			newEncoder(config.Log.Format, true),
+			zapcore.Lock(os.Stdout),
+			zapcore.DebugLevel,
+		),
+	}
+
+	// Colors are not written to file
+	if len(config.Log.File.Path) > 0 {
+		cores = append(cores, zapcore.NewCore(
+			newEncoder(config.Log.Format, false),
+			zapcore.AddSync(newFileWriter(&config.Log.File)),
+			zapcore.DebugLevel,
+		))
+	}
+
+	core := newNamedCore(zapcore.NewTee(cores...), levels)
 
 	var logger *zap.Logger
 	if os.Getenv("GRAVITY_DEBUG_MODE") == "debug" {
-		logger.Info(fmt.Sprintf("Debug mode is set to \"%s\"\n", debugLevel.String()))
 		logger = zap.New(core, zap.AddCaller(), zap.Development())
+		logger.Info(fmt.Sprintf("Debug mode is set to \"%s\"", levels.GetLevel().String()))
 	} else {
 		logger = zap.New(core)
 	}
 
 	zap.ReplaceGlobals(logger)
 
-	logger.Info(fmt.Sprintf("Debug level is set to \"%s\"\n", debugLevel.String()))
+	logger.Info(fmt.Sprintf("Debug level is set to \"%s\"", levels.GetLevel().String()),
+		zap.String("format", config.Log.Format),
+		zap.String("file", config.Log.File.Path),
+	)
+
+	for name, level := range levels.GetNamedLevels() {
+		logger.Info(fmt.Sprintf("Debug level of %s is set to \"%s\"", name, level.String()))
+	}
 
 	return logger
 }
 
-func setupLevel() zap.AtomicLevel {
+// newFileWriter returns writer which rotates file when it reached maximum size
+func newFileWriter(config *configs.LogFileConfig) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   config.Path,
+		MaxSize:    config.MaxSize,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAge,
+		Compress:   config.Compress,
+		LocalTime:  true,
+	}
+}
+
+func setupLevels(config *configs.LogConfig) {
+
+	levels.SetLevel(parseLevel(config.Level))
+
+	named := make(map[string]zapcore.Level, len(config.Levels))
+	for name, level := range config.Levels {
+		named[name] = parseLevel(level)
+	}
+
+	levels.SetNamedLevels(named)
+}
+
+func parseLevel(name string) zapcore.Level {
+
+	// Level of config file takes precedence over environment variable
+	if len(name) == 0 {
+		name = os.Getenv("GRAVITY_DEBUG_LEVEL")
+	}
 
 	debugLevel := zap.DebugLevel
-	switch os.Getenv("GRAVITY_DEBUG_LEVEL") {
+	switch strings.ToLower(name) {
 	case zap.InfoLevel.String():
 		debugLevel = zap.InfoLevel
 	case zap.WarnLevel.String():
@@ -66,5 +154,22 @@ func setupLevel() zap.AtomicLevel {
 		debugLevel = zap.FatalLevel
 	}
 
-	return zap.NewAtomicLevelAt(debugLevel)
+	return debugLevel
+}
+
+// WatchConfig changes levels of loggers when config was reloaded
+func WatchConfig(r *reloader.Reloader) {
+	r.Register(nil, func(config *configs.Config, changes []string) {
+
+		if !reloader.HasChanged(changes, "log.level") && !reloader.HasChanged(changes, "log.levels") {
+			return
+		}
+
+		log := config.GetLogConfig()
+		reloadLevels(&log)
+
+		zap.L().Info(fmt.Sprintf("Debug level is set to \"%s\"", levels.GetLevel().String()),
+			zap.Any("levels", log.Levels),
+		)
+	})
 }
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/http_server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const Namespace = "gravity_snapshot"
+
+// Registry holds metrics of application, every application has its own registry so they can be run in the same process
+type Registry struct {
+	registry *prometheus.Registry
+
+	EventsConsumed *prometheus.CounterVec
+	ApplyDuration  *prometheus.HistogramVec
+	Records        *prometheus.CounterVec
+	HandlerErrors  *prometheus.CounterVec
+	RPCRequests    *prometheus.CounterVec
+	RPCDuration    *prometheus.HistogramVec
+}
+
+func NewRegistry() *Registry {
+
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+
+		EventsConsumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "events_consumed_total",
+			Help:      "Number of events consumed from collection streams",
+		}, []string{"collection", "partition"}),
+
+		ApplyDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "apply_duration_seconds",
+			Help:      "Time spent on applying event to snapshot",
+			Buckets:   prometheus.ExponentialBuckets(0.00005, 2, 16),
+		}, []string{"collection"}),
+
+		Records: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "records_total",
+			Help:      "Number of records which were upserted, deleted or skipped",
+		}, []string{"collection", "operation"}),
+
+		HandlerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "handler_errors_total",
+			Help:      "Number of errors occurred while applying events",
+		}, []string{"collection", "reason"}),
+
+		RPCRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "rpc_requests_total",
+			Help:      "Number of RPC requests",
+		}, []string{"route"}),
+
+		RPCDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "rpc_duration_seconds",
+			Help:      "Time spent on handling RPC requests",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route"}),
+	}
+
+	r.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		r.EventsConsumed,
+		r.ApplyDuration,
+		r.Records,
+		r.HandlerErrors,
+		r.RPCRequests,
+		r.RPCDuration,
+	)
+
+	return r
+}
+
+// Register registers collector which collects metrics from state of component
+func (r *Registry) Register(c prometheus.Collector) error {
+	return r.registry.Register(c)
+}
+
+// New exposes metrics on HTTP server
+func New(server *http_server.HTTPServer, r *Registry) {
+	server.Handle("/metrics", promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{}))
+}
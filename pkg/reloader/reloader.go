@@ -0,0 +1,198 @@
+package reloader
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+type handler struct {
+	check func(*configs.Config, []string) error
+	apply func(*configs.Config, []string)
+}
+
+// Reloader applies changes of configuration without restart
+type Reloader struct {
+	config   *configs.Config
+	handlers []*handler
+	watcher  *fsnotify.Watcher
+	stopped  bool
+	mutex    sync.Mutex
+}
+
+func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger) *Reloader {
+
+	logger = l.Named("Reloader")
+
+	r := &Reloader{
+		config:   config,
+		handlers: make([]*handler, 0),
+	}
+
+	lifecycle.Append(
+		fx.Hook{
+			OnStart: func(context.Context) error {
+				return r.watch()
+			},
+			OnStop: func(context.Context) error {
+				r.mutex.Lock()
+				r.stopped = true
+				r.mutex.Unlock()
+
+				if r.watcher != nil {
+					return r.watcher.Close()
+				}
+
+				return nil
+			},
+		},
+	)
+
+	return r
+}
+
+// watch reloads config when config file was changed. File is watched here rather than by viper, because viper reads
+// file from its own goroutine, but viper should only be used by Reload which is serialized.
+func (r *Reloader) watch() error {
+
+	if !r.config.Reload.Watch {
+		return nil
+	}
+
+	file := viper.ConfigFileUsed()
+	if len(file) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// Directory is watched because file may be replaced by editors or mounted by symbolic links
+	file = filepath.Clean(file)
+	err = watcher.Add(filepath.Dir(file))
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	r.watcher = watcher
+	realFile, _ := filepath.EvalSymlinks(file)
+
+	logger.Info("Watching config file for changes", zap.String("file", file))
+
+	go func() {
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				// Target of symbolic link was replaced
+				target, _ := filepath.EvalSymlinks(file)
+				changed := len(target) > 0 && target != realFile
+				realFile = target
+
+				if !changed && (filepath.Clean(e.Name) != file || e.Op&(fsnotify.Write|fsnotify.Create) == 0) {
+					continue
+				}
+
+				_, err := r.Reload()
+				if err != nil {
+					logger.Error(err.Error(), zap.String("file", e.Name))
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				logger.Warn(err.Error())
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Register specifies functions to be called when config was reloaded, check rejects changes by returning error,
+// then apply is called with new config once all checks were passed
+func (r *Reloader) Register(check func(*configs.Config, []string) error, apply func(*configs.Config, []string)) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handlers = append(r.handlers, &handler{
+		check: check,
+		apply: apply,
+	})
+}
+
+// Reload reads config again and applies changes, nothing is changed if any of changes cannot be applied without restart
+func (r *Reloader) Reload() ([]string, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stopped {
+		return nil, nil
+	}
+
+	next, changes, err := r.config.Refresh()
+	if err != nil {
+		logger.Warn("Rejected changes of config", zap.Strings("changes", changes))
+		return changes, err
+	}
+
+	if len(changes) == 0 {
+		logger.Debug("No changes of config")
+		return changes, nil
+	}
+
+	for _, h := range r.handlers {
+
+		if h.check == nil {
+			continue
+		}
+
+		err := h.check(next, changes)
+		if err != nil {
+			logger.Warn("Rejected changes of config", zap.Strings("changes", changes))
+			return changes, err
+		}
+	}
+
+	r.config.Update(next)
+
+	for _, h := range r.handlers {
+		if h.apply != nil {
+			h.apply(r.config, changes)
+		}
+	}
+
+	logger.Info("Config was reloaded", zap.Strings("changes", changes))
+
+	return changes, nil
+}
+
+// HasChanged returns true if setting or any of its nested settings was changed
+func HasChanged(changes []string, setting string) bool {
+
+	for _, c := range changes {
+		if c == setting || strings.HasPrefix(c, setting+".") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/admin"
+	"github.com/nats-io/nats.go"
+)
+
+type LogLevelReply struct {
+	*admin.LogLevelReply
+	Node  string           `json:"node,omitempty"`
+	Nodes []*LogLevelReply `json:"nodes,omitempty"`
+	Error *Error           `json:"error,omitempty"`
+}
+
+type MergeDebugReply struct {
+	*admin.MergeDebugReply
+	Node  string             `json:"node,omitempty"`
+	Nodes []*MergeDebugReply `json:"nodes,omitempty"`
+	Error *Error             `json:"error,omitempty"`
+}
+
+func (rpc *RPC) setLogLevel(msg *nats.Msg) {
+
+	// Parsing request
+	var req admin.LogLevelRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err != nil {
+		rpc.respondError(msg, InvalidRequestErr(err.Error()))
+		return
+	}
+
+	// Every instance of cluster has its own loggers
+	if rpc.cluster.IsEnabled() && !rpc.isForwarded(msg) {
+
+		resp := &LogLevelReply{
+			Nodes: make([]*LogLevelReply, 0),
+		}
+
+		failed, e := rpc.gatherNodes("LOG.LEVEL", msg, func(data []byte) (*Error, error) {
+
+			var reply LogLevelReply
+			err := json.Unmarshal(data, &reply)
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Nodes = append(resp.Nodes, &reply)
+
+			return reply.Error, nil
+		})
+		if e != nil {
+			rpc.respondError(msg, e)
+			return
+		}
+
+		if failed != nil {
+			resp.Error = &Error{
+				Code:    failed.Code,
+				Message: "Level of logger was not changed by some of nodes",
+			}
+		}
+
+		rpc.respond(msg, resp)
+		return
+	}
+
+	resp := &LogLevelReply{
+		Node: rpc.cluster.GetNodeID(),
+	}
+
+	resp.LogLevelReply, err = rpc.admin.SetLogLevel(&req)
+	if err != nil {
+		resp.Error = InvalidRequestErr(err.Error())
+	}
+
+	rpc.respond(msg, resp)
+}
+
+func (rpc *RPC) setMergeDebug(msg *nats.Msg) {
+
+	// Parsing request
+	var req admin.MergeDebugRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err != nil {
+		rpc.respondError(msg, InvalidRequestErr(err.Error()))
+		return
+	}
+
+	// Partitions of collection are spread over instances of cluster
+	if rpc.cluster.IsEnabled() && !rpc.isForwarded(msg) {
+
+		resp := &MergeDebugReply{
+			Nodes: make([]*MergeDebugReply, 0),
+		}
+
+		failed, e := rpc.gatherNodes("DEBUG.MERGE", msg, func(data []byte) (*Error, error) {
+
+			var reply MergeDebugReply
+			err := json.Unmarshal(data, &reply)
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Nodes = append(resp.Nodes, &reply)
+
+			return reply.Error, nil
+		})
+		if e != nil {
+			rpc.respondError(msg, e)
+			return
+		}
+
+		if failed != nil {
+			resp.Error = &Error{
+				Code:    failed.Code,
+				Message: "Merge debugging was not changed by some of nodes",
+			}
+		}
+
+		rpc.respond(msg, resp)
+		return
+	}
+
+	resp := &MergeDebugReply{
+		Node: rpc.cluster.GetNodeID(),
+	}
+
+	resp.MergeDebugReply, err = rpc.admin.SetMergeDebug(&req)
+	if err != nil {
+		resp.Error = InvalidRequestErr(err.Error())
+	}
+
+	rpc.respond(msg, resp)
+}
@@ -0,0 +1,163 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/backup"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+type BackupRequest struct {
+	Path string `json:"path"`
+}
+
+type BackupReply struct {
+	Node        string                             `json:"node,omitempty"`
+	Path        string                             `json:"path"`
+	CreatedAt   time.Time                          `json:"createdAt"`
+	Collections map[string]*backup.CollectionState `json:"collections"`
+	Nodes       []*BackupReply                     `json:"nodes,omitempty"`
+	Error       *Error                             `json:"error,omitempty"`
+}
+
+func (rpc *RPC) backup(msg *nats.Msg) {
+
+	// Parsing request
+	var req BackupRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	if len(req.Path) == 0 {
+		req.Path = fmt.Sprintf("backup-%s.tar.gz", time.Now().Format("20060102150405"))
+	}
+
+	path, err := resolveLocalPath(rpc.config.Backup.Path, rpc.getNodePath(req.Path))
+	if err != nil {
+		rpc.respondError(msg, InvalidRequestErr(err.Error()))
+		return
+	}
+
+	// Every instance backs up its own datastore, archives have the same name in directories of instances
+	if rpc.isClusterRequest(msg) {
+		msg.Data, _ = json.Marshal(&req)
+		timeout := time.Duration(rpc.config.Backup.Timeout)*time.Second + rpc.getClusterRequestTimeout()
+		rpc.fanOut("BACKUP", msg, timeout, func(replies [][]byte) (interface{}, *Error) {
+			return mergeBackupReplies(req.Path, replies)
+		})
+		return
+	}
+
+	logger.Info("Creating backup...",
+		zap.String("path", path),
+	)
+
+	manifest, err := rpc.writeBackup(path)
+	if err != nil {
+		logger.Error(err.Error())
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return
+	}
+
+	rpc.respond(msg, &BackupReply{
+		Node:        rpc.cluster.GetNodeID(),
+		Path:        path,
+		CreatedAt:   manifest.CreatedAt,
+		Collections: manifest.Collections,
+	})
+}
+
+func (rpc *RPC) writeBackup(path string) (*backup.Manifest, error) {
+
+	timeout := time.Duration(rpc.config.Backup.Timeout) * time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	manifest, err := rpc.snapshot.Backup(ctx, w)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, f.Sync()
+}
+
+func mergeBackupReplies(path string, replies [][]byte) (interface{}, *Error) {
+
+	merged := &BackupReply{
+		Path:        path,
+		Collections: make(map[string]*backup.CollectionState),
+		Nodes:       make([]*BackupReply, 0, len(replies)),
+	}
+
+	for _, data := range replies {
+
+		var reply BackupReply
+		err := json.Unmarshal(data, &reply)
+		if err != nil {
+			return nil, InternalErr(err.Error())
+		}
+
+		if reply.Error != nil {
+			return nil, reply.Error
+		}
+
+		if reply.CreatedAt.After(merged.CreatedAt) {
+			merged.CreatedAt = reply.CreatedAt
+		}
+
+		// Positions of partitions which are owned by instances
+		for name, state := range reply.Collections {
+
+			collection, ok := merged.Collections[name]
+			if !ok {
+				collection = &backup.CollectionState{
+					Partitions: make(map[uint64]uint64),
+				}
+				merged.Collections[name] = collection
+			}
+
+			for partition, seq := range state.Partitions {
+				if seq > collection.Partitions[partition] {
+					collection.Partitions[partition] = seq
+				}
+			}
+		}
+
+		merged.Nodes = append(merged.Nodes, &reply)
+	}
+
+	sort.Slice(merged.Nodes, func(i, j int) bool {
+		return merged.Nodes[i].Node < merged.Nodes[j].Node
+	})
+
+	return merged, nil
+}
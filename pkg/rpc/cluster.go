@@ -0,0 +1,261 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const forwardedHeader = "Gravity-Snapshot-Forwarded"
+
+type NodeStatus struct {
+	Node       string              `json:"node"`
+	Partitions map[string][]uint64 `json:"partitions"`
+	Views      int                 `json:"views"`
+}
+
+type ClusterStatusReply struct {
+	Enabled bool          `json:"enabled"`
+	Members []string      `json:"members"`
+	Nodes   []*NodeStatus `json:"nodes"`
+}
+
+func (rpc *RPC) registerCluster() error {
+
+	handlers := map[string]func(*nats.Msg){
+		"VIEW.DELETE":       rpc.deleteSnapshotView,
+		"VIEW.PULL":         rpc.pullSnapshotView,
+		"COLLECTION.EXPORT": rpc.exportCollection,
+		"COLLECTION.VERIFY": rpc.verifyCollection,
+		"COLLECTION.DIGEST": rpc.digestCollection,
+		"COLLECTION.STATUS": rpc.getCollectionStatus,
+		"BACKUP":            rpc.backup,
+		"CLUSTER.STATUS":    rpc.getClusterStatus,
+		"CONFIG.RELOAD":     rpc.reloadConfig,
+		"LOG.LEVEL":         rpc.setLogLevel,
+		"DEBUG.MERGE":       rpc.setMergeDebug,
+	}
+
+	return registerHandlers(rpc.clusterRoutes, handlers)
+}
+
+func (rpc *RPC) getClusterRequestTimeout() time.Duration {
+	return time.Duration(rpc.config.Cluster.RequestTimeout) * time.Second
+}
+
+// isForwarded returns true if request was forwarded from another instance, it should be handled locally only
+func (rpc *RPC) isForwarded(msg *nats.Msg) bool {
+
+	if msg.Header == nil {
+		return false
+	}
+
+	return len(msg.Header.Get(forwardedHeader)) > 0
+}
+
+func (rpc *RPC) newForwardedMsg(apiPath string, msg *nats.Msg) *nats.Msg {
+
+	m := nats.NewMsg(rpc.clusterRoutes.prefix + "." + apiPath)
+	m.Data = msg.Data
+
+	// Keep trace context of original request
+	for k, v := range msg.Header {
+		m.Header[k] = v
+	}
+
+	m.Header.Set(forwardedHeader, rpc.cluster.GetNodeID())
+
+	return m
+}
+
+// forward sends request to instance which is able to handle it, it returns false if no one replied
+func (rpc *RPC) forward(apiPath string, msg *nats.Msg) bool {
+
+	if !rpc.cluster.IsEnabled() || rpc.isForwarded(msg) {
+		return false
+	}
+
+	conn := rpc.connector.GetConnection()
+	reply, err := conn.RequestMsg(rpc.newForwardedMsg(apiPath, msg), rpc.getClusterRequestTimeout())
+	if err != nil {
+		if err != nats.ErrTimeout {
+			logger.Warn(err.Error(), zap.String("api", apiPath))
+		}
+
+		return false
+	}
+
+	err = msg.Respond(reply.Data)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	return true
+}
+
+// isClusterRequest returns true if request should be sent to all instances of cluster
+func (rpc *RPC) isClusterRequest(msg *nats.Msg) bool {
+	return rpc.cluster.IsEnabled() && !rpc.isForwarded(msg)
+}
+
+// gather sends request to all instances of cluster and collects replies until all members replied or timeout
+func (rpc *RPC) gather(apiPath string, msg *nats.Msg) ([][]byte, error) {
+	return rpc.gatherWithin(apiPath, msg, rpc.getClusterRequestTimeout())
+}
+
+func (rpc *RPC) gatherWithin(apiPath string, msg *nats.Msg, timeout time.Duration) ([][]byte, error) {
+
+	conn := rpc.connector.GetConnection()
+
+	inbox := conn.NewRespInbox()
+	sub, err := conn.SubscribeSync(inbox)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	m := rpc.newForwardedMsg(apiPath, msg)
+	m.Reply = inbox
+
+	err = conn.PublishMsg(m)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := len(rpc.cluster.GetMembers())
+	deadline := time.Now().Add(timeout)
+	replies := make([][]byte, 0, expected)
+	for len(replies) < expected {
+
+		reply, err := sub.NextMsg(time.Until(deadline))
+		if err == nats.ErrTimeout {
+			logger.Warn("Some of members did not reply",
+				zap.String("api", apiPath),
+				zap.Int("expected", expected),
+				zap.Int("replied", len(replies)),
+			)
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		replies = append(replies, reply.Data)
+	}
+
+	return replies, nil
+}
+
+// gatherNodes sends request to all instances of cluster and passes replies to fn. It returns error of the first instance
+// which failed, and error of gathering which should be replied as it is if request could not be sent.
+func (rpc *RPC) gatherNodes(apiPath string, msg *nats.Msg, fn func([]byte) (*Error, error)) (*Error, *Error) {
+
+	replies, err := rpc.gather(apiPath, msg)
+	if err != nil {
+		logger.Error(err.Error(), zap.String("api", apiPath))
+		return nil, InternalErr(err.Error())
+	}
+
+	var failed *Error
+	for _, data := range replies {
+
+		e, err := fn(data)
+		if err != nil {
+			logger.Warn(err.Error(), zap.String("api", apiPath))
+			continue
+		}
+
+		if e != nil && failed == nil {
+			failed = e
+		}
+	}
+
+	return failed, nil
+}
+
+// fanOut sends request to all instances of cluster in background and replies with result merged by fn, request fails
+// if any of instances did not reply in time because data of its partitions would be missing
+func (rpc *RPC) fanOut(apiPath string, msg *nats.Msg, timeout time.Duration, fn func([][]byte) (interface{}, *Error)) {
+
+	rpc.background.Add(1)
+	go func() {
+		defer rpc.background.Done()
+
+		expected := len(rpc.cluster.GetMembers())
+		replies, err := rpc.gatherWithin(apiPath, msg, timeout)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("api", apiPath))
+			rpc.respondError(msg, InternalErr(err.Error()))
+			return
+		}
+
+		if len(replies) < expected {
+			rpc.respondError(msg, InternalErr(fmt.Sprintf("Only %d of %d nodes replied", len(replies), expected)))
+			return
+		}
+
+		resp, e := fn(replies)
+		if e != nil {
+			rpc.respondError(msg, e)
+			return
+		}
+
+		rpc.respond(msg, resp)
+	}()
+}
+
+func (rpc *RPC) getNodeStatus() *NodeStatus {
+	return &NodeStatus{
+		Node:       rpc.cluster.GetNodeID(),
+		Partitions: rpc.cluster.GetOwnedPartitions(),
+		Views:      rpc.viewManager.GetViewCount(),
+	}
+}
+
+func (rpc *RPC) getClusterStatus(msg *nats.Msg) {
+
+	if !rpc.cluster.IsEnabled() {
+		rpc.respond(msg, &ClusterStatusReply{
+			Enabled: false,
+			Members: make([]string, 0),
+			Nodes:   make([]*NodeStatus, 0),
+		})
+		return
+	}
+
+	if rpc.isForwarded(msg) {
+		rpc.respond(msg, rpc.getNodeStatus())
+		return
+	}
+
+	replies, err := rpc.gather("CLUSTER.STATUS", msg)
+	if err != nil {
+		logger.Error(err.Error())
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return
+	}
+
+	resp := &ClusterStatusReply{
+		Enabled: true,
+		Members: rpc.cluster.GetMembers(),
+		Nodes:   make([]*NodeStatus, 0, len(replies)),
+	}
+
+	for _, data := range replies {
+
+		var status NodeStatus
+		err := json.Unmarshal(data, &status)
+		if err != nil {
+			logger.Warn(err.Error())
+			continue
+		}
+
+		resp.Nodes = append(resp.Nodes, &status)
+	}
+
+	rpc.respond(msg, resp)
+}
@@ -0,0 +1,201 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/exporter"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+type ExportCollectionRequest struct {
+	Collection string `json:"collection"`
+	Table      string `json:"table"`
+	Format     string `json:"format"`
+	Path       string `json:"path"`
+}
+
+type ExportCollectionReply struct {
+	Node       string                   `json:"node,omitempty"`
+	Collection string                   `json:"collection"`
+	Format     string                   `json:"format"`
+	Path       string                   `json:"path"`
+	Count      int                      `json:"count"`
+	Nodes      []*ExportCollectionReply `json:"nodes,omitempty"`
+	Error      *Error                   `json:"error,omitempty"`
+}
+
+func (rpc *RPC) respondError(msg *nats.Msg, e *Error) {
+
+	data, _ := json.Marshal(&ErrorReply{
+		Error: e,
+	})
+
+	err := msg.Respond(data)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+func (rpc *RPC) respond(msg *nats.Msg, resp interface{}) {
+
+	data, _ := json.Marshal(resp)
+
+	err := msg.Respond(data)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+func (rpc *RPC) getCollectionStore(msg *nats.Msg, collection string) *snapshot.CollectionStore {
+
+	cs, err := rpc.snapshot.GetCollectionStore(collection)
+	if err != nil {
+		if errors.Is(err, snapshot.ErrNotFoundCollection) {
+			rpc.respondError(msg, NotFoundCollectionErr())
+			return nil
+		}
+
+		logger.Error(err.Error())
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return nil
+	}
+
+	return cs
+}
+
+// resolveLocalPath makes sure that path is inside of specific directory
+func resolveLocalPath(dir string, path string) (string, error) {
+
+	baseDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	fullPath := filepath.Join(baseDir, filepath.Clean("/"+path))
+	if !strings.HasPrefix(fullPath, baseDir+string(filepath.Separator)) {
+		return "", fmt.Errorf("Invalid path: %s", path)
+	}
+
+	return fullPath, nil
+}
+
+// getNodePath returns path in directory of this instance, because every instance of cluster writes its own file
+func (rpc *RPC) getNodePath(path string) string {
+
+	if !rpc.cluster.IsEnabled() {
+		return path
+	}
+
+	return filepath.Join(rpc.cluster.GetNodeID(), path)
+}
+
+func (rpc *RPC) exportCollection(msg *nats.Msg) {
+
+	// Parsing request
+	var req ExportCollectionRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	if len(req.Path) == 0 {
+		rpc.respondError(msg, InvalidRequestErr("Path of output file is required"))
+		return
+	}
+
+	if len(req.Table) == 0 {
+		req.Table = req.Collection
+	}
+
+	ex, err := exporter.NewExporter(req.Format)
+	if err != nil {
+		rpc.respondError(msg, InvalidRequestErr(err.Error()))
+		return
+	}
+
+	path, err := resolveLocalPath(rpc.config.Export.Path, rpc.getNodePath(req.Path))
+	if err != nil {
+		rpc.respondError(msg, InvalidRequestErr(err.Error()))
+		return
+	}
+
+	// Every instance exports partitions it owns
+	if rpc.isClusterRequest(msg) {
+		timeout := time.Duration(rpc.config.Export.Timeout)*time.Second + rpc.getClusterRequestTimeout()
+		rpc.fanOut("COLLECTION.EXPORT", msg, timeout, func(replies [][]byte) (interface{}, *Error) {
+
+			merged := &ExportCollectionReply{
+				Collection: req.Collection,
+				Format:     req.Format,
+				Path:       req.Path,
+				Nodes:      make([]*ExportCollectionReply, 0, len(replies)),
+			}
+
+			for _, data := range replies {
+
+				var reply ExportCollectionReply
+				err := json.Unmarshal(data, &reply)
+				if err != nil {
+					return nil, InternalErr(err.Error())
+				}
+
+				if reply.Error != nil {
+					return nil, reply.Error
+				}
+
+				merged.Count += reply.Count
+				merged.Nodes = append(merged.Nodes, &reply)
+			}
+
+			sort.Slice(merged.Nodes, func(i, j int) bool {
+				return merged.Nodes[i].Node < merged.Nodes[j].Node
+			})
+
+			return merged, nil
+		})
+		return
+	}
+
+	cs := rpc.getCollectionStore(msg, req.Collection)
+	if cs == nil {
+		return
+	}
+
+	logger.Info("Exporting collection...",
+		zap.String("collection", req.Collection),
+		zap.String("format", req.Format),
+		zap.String("path", path),
+	)
+
+	source, err := exporter.NewStoreSource(cs.GetStore(), req.Table)
+	if err != nil {
+		logger.Error(err.Error())
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return
+	}
+	defer source.Close()
+
+	count, err := ex.ExportToFile(source.Scan, path)
+	if err != nil {
+		logger.Error(err.Error())
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return
+	}
+
+	rpc.respond(msg, &ExportCollectionReply{
+		Node:       rpc.cluster.GetNodeID(),
+		Collection: req.Collection,
+		Format:     req.Format,
+		Path:       path,
+		Count:      count,
+	})
+}
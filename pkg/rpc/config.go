@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+type ConfigReloadReply struct {
+	Node    string               `json:"node,omitempty"`
+	Changes []string             `json:"changes"`
+	Nodes   []*ConfigReloadReply `json:"nodes,omitempty"`
+	Error   *Error               `json:"error,omitempty"`
+}
+
+func (rpc *RPC) reloadConfig(msg *nats.Msg) {
+
+	// Every instance of cluster has its own config
+	if rpc.cluster.IsEnabled() && !rpc.isForwarded(msg) {
+		rpc.reloadClusterConfig(msg)
+		return
+	}
+
+	rpc.respond(msg, rpc.reloadLocalConfig())
+}
+
+func (rpc *RPC) reloadLocalConfig() *ConfigReloadReply {
+
+	changes, err := rpc.reloader.Reload()
+
+	resp := &ConfigReloadReply{
+		Node:    rpc.cluster.GetNodeID(),
+		Changes: changes,
+	}
+
+	if resp.Changes == nil {
+		resp.Changes = make([]string, 0)
+	}
+
+	if err != nil {
+		resp.Error = ConfigRejectedErr(err.Error())
+	}
+
+	return resp
+}
+
+func (rpc *RPC) reloadClusterConfig(msg *nats.Msg) {
+
+	resp := &ConfigReloadReply{
+		Changes: make([]string, 0),
+		Nodes:   make([]*ConfigReloadReply, 0),
+	}
+
+	failed, e := rpc.gatherNodes("CONFIG.RELOAD", msg, func(data []byte) (*Error, error) {
+
+		var reply ConfigReloadReply
+		err := json.Unmarshal(data, &reply)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Nodes = append(resp.Nodes, &reply)
+
+		return reply.Error, nil
+	})
+	if e != nil {
+		rpc.respondError(msg, e)
+		return
+	}
+
+	if failed != nil {
+		resp.Error = ConfigRejectedErr("Config was not reloaded by some of nodes")
+	}
+
+	rpc.respond(msg, resp)
+}
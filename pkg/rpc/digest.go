@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+type DigestCollectionRequest struct {
+	Collection string `json:"collection"`
+	Table      string `json:"table"`
+	Buckets    []int  `json:"buckets"`
+}
+
+type DigestCollectionReply struct {
+	Positions map[uint64]uint64                  `json:"positions"`
+	Digest    *snapshot.CollectionDigest         `json:"digest"`
+	Records   map[int][]*snapshot.RecordChecksum `json:"records,omitempty"`
+	Nodes     []string                           `json:"nodes,omitempty"`
+	Error     *Error                             `json:"error,omitempty"`
+}
+
+func (rpc *RPC) digestCollection(msg *nats.Msg) {
+
+	// Parsing request
+	var req DigestCollectionRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	for _, bucket := range req.Buckets {
+		if bucket < 0 || bucket >= snapshot.DigestBucketCount {
+			rpc.respondError(msg, InvalidRequestErr("Invalid bucket"))
+			return
+		}
+	}
+
+	if len(req.Buckets) > 0 && len(req.Table) == 0 {
+		rpc.respondError(msg, InvalidRequestErr("Table is required for getting checksums of buckets"))
+		return
+	}
+
+	// Partitions are spread over instances of cluster
+	if rpc.isClusterRequest(msg) {
+		rpc.fanOut("COLLECTION.DIGEST", msg, rpc.getClusterRequestTimeout(), mergeDigestReplies)
+		return
+	}
+
+	cs := rpc.getCollectionStore(msg, req.Collection)
+	if cs == nil {
+		return
+	}
+
+	reply := &DigestCollectionReply{
+		Positions: cs.GetPositions(),
+	}
+
+	if rpc.cluster.IsEnabled() {
+		reply.Nodes = []string{rpc.cluster.GetNodeID()}
+	}
+
+	reply.Digest, err = snapshot.GetDigest(req.Collection, cs.GetStore())
+	if err != nil {
+		logger.Error(err.Error(), zap.String("collection", req.Collection))
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return
+	}
+
+	// Checksums of records in specific buckets for narrowing down divergence
+	if len(req.Buckets) > 0 {
+		reply.Records, err = snapshot.GetBucketChecksums(cs.GetStore(), req.Table, req.Buckets)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("collection", req.Collection))
+			rpc.respondError(msg, InternalErr(err.Error()))
+			return
+		}
+	}
+
+	rpc.respond(msg, reply)
+}
+
+func mergeDigestReplies(replies [][]byte) (interface{}, *Error) {
+
+	merged := &DigestCollectionReply{
+		Positions: make(map[uint64]uint64),
+		Nodes:     make([]string, 0, len(replies)),
+	}
+
+	digests := make([]*snapshot.CollectionDigest, 0, len(replies))
+	for _, data := range replies {
+
+		var reply DigestCollectionReply
+		err := json.Unmarshal(data, &reply)
+		if err != nil {
+			return nil, InternalErr(err.Error())
+		}
+
+		if reply.Error != nil {
+			return nil, reply.Error
+		}
+
+		digests = append(digests, reply.Digest)
+		merged.Nodes = append(merged.Nodes, reply.Nodes...)
+
+		for partition, seq := range reply.Positions {
+			if seq > merged.Positions[partition] {
+				merged.Positions[partition] = seq
+			}
+		}
+
+		for bucket, records := range reply.Records {
+
+			if merged.Records == nil {
+				merged.Records = make(map[int][]*snapshot.RecordChecksum)
+			}
+
+			merged.Records[bucket] = append(merged.Records[bucket], records...)
+		}
+	}
+
+	for _, records := range merged.Records {
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].Key < records[j].Key
+		})
+	}
+
+	sort.Strings(merged.Nodes)
+	merged.Digest = snapshot.MergeDigests(digests...)
+
+	return merged, nil
+}
@@ -5,9 +5,37 @@ type Error struct {
 	Message string `json:"message"`
 }
 
+func InvalidRequestErr(message string) *Error {
+	return &Error{
+		Code:    44400,
+		Message: message,
+	}
+}
+
 func NotFoundViewErr() *Error {
 	return &Error{
 		Code:    44404,
 		Message: "Not found view",
 	}
 }
+
+func NotFoundCollectionErr() *Error {
+	return &Error{
+		Code:    44405,
+		Message: "Not found collection",
+	}
+}
+
+func InternalErr(message string) *Error {
+	return &Error{
+		Code:    44500,
+		Message: message,
+	}
+}
+
+func ConfigRejectedErr(message string) *Error {
+	return &Error{
+		Code:    44409,
+		Message: message,
+	}
+}
@@ -0,0 +1,28 @@
+package rpc
+
+import (
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/health"
+	"github.com/nats-io/nats.go"
+)
+
+type HealthReply struct {
+	Liveness  *health.Report `json:"liveness"`
+	Readiness *health.Report `json:"readiness"`
+	Role      string         `json:"role,omitempty"`
+	Leader    string         `json:"leader,omitempty"`
+}
+
+func (rpc *RPC) health(msg *nats.Msg) {
+
+	resp := &HealthReply{
+		Liveness:  rpc.healthChecker.Liveness(),
+		Readiness: rpc.healthChecker.Readiness(),
+	}
+
+	if rpc.election.IsEnabled() {
+		resp.Role = rpc.election.GetRole()
+		resp.Leader = rpc.election.GetLeader()
+	}
+
+	rpc.respond(msg, resp)
+}
@@ -1,20 +1,96 @@
 package rpc
 
-import "github.com/nats-io/nats.go"
+import (
+	"context"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/tracing"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
 
 type Route struct {
-	prefix string
-	rpc    *RPC
+	prefix        string
+	queue         string
+	rpc           *RPC
+	subscriptions []*nats.Subscription
+	draining      []*nats.Subscription
 }
 
-func NewRoute(rpc *RPC, prefix string) *Route {
+// NewRoute creates route for handling requests, requests will be load balanced between instances if queue was specified
+func NewRoute(rpc *RPC, prefix string, queue string) *Route {
 	return &Route{
-		rpc:    rpc,
-		prefix: prefix,
+		rpc:           rpc,
+		prefix:        prefix,
+		queue:         queue,
+		subscriptions: make([]*nats.Subscription, 0),
+	}
+}
+
+func (r *Route) Handle(apiPath string, h func(*nats.Msg)) error {
+	conn := r.rpc.connector.GetConnection()
+	sub, err := conn.QueueSubscribe(r.prefix+"."+apiPath, r.queue, func(msg *nats.Msg) {
+		start := time.Now()
+		defer func() {
+			r.rpc.metrics.RPCRequests.WithLabelValues(apiPath).Inc()
+			r.rpc.metrics.RPCDuration.WithLabelValues(apiPath).Observe(time.Since(start).Seconds())
+		}()
+
+		ctx, span := tracing.Tracer().Start(tracing.Extract(context.Background(), msg), "rpc "+apiPath,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("rpc.route", apiPath)),
+		)
+		defer span.End()
+
+		// Handlers will get span of RPC from message
+		tracing.Inject(ctx, msg)
+
+		h(msg)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.subscriptions = append(r.subscriptions, sub)
+
+	return nil
+}
+
+// Close stops accepting requests, requests in progress are still being handled in background until Wait returns
+func (r *Route) Close() {
+
+	for _, sub := range r.subscriptions {
+		err := sub.Drain()
+		if err != nil {
+			logger.Warn(err.Error(), zap.String("subject", sub.Subject))
+			continue
+		}
+
+		r.draining = append(r.draining, sub)
 	}
+
+	r.subscriptions = make([]*nats.Subscription, 0)
 }
 
-func (r *Route) Handle(apiPath string, h func(*nats.Msg)) {
-	conn := r.rpc.connector.GetClient().GetConnection()
-	conn.Subscribe(r.prefix+".", h)
+// Wait blocks until requests in progress were handled after closing, or context was done
+func (r *Route) Wait(ctx context.Context) error {
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for _, sub := range r.draining {
+		for sub.IsValid() {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	r.draining = nil
+
+	return nil
 }
@@ -3,9 +3,15 @@ package rpc
 import (
 	"context"
 	"fmt"
+	"sync"
 
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/admin"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/cluster"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/health"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/metrics"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/reloader"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/view_manager"
 	"github.com/nats-io/nats.go"
@@ -16,36 +22,126 @@ import (
 var logger *zap.Logger
 
 type RPC struct {
-	snapshot    *snapshot.Snapshot
-	connector   *connector.Connector
-	viewManager *view_manager.ViewManager
-	routes      *Route
+	config        *configs.Config
+	snapshot      *snapshot.Snapshot
+	connector     *connector.Connector
+	viewManager   *view_manager.ViewManager
+	healthChecker *health.Health
+	cluster       *cluster.Cluster
+	election      *cluster.Election
+	reloader      *reloader.Reloader
+	admin         *admin.Admin
+	metrics       *metrics.Registry
+	prefix        string
+	routes        *Route
+	clusterRoutes *Route
+	leaderRoutes  *Route
+	mutex         sync.Mutex
+
+	// Requests which take a while are handled in background
+	ctx        context.Context
+	cancel     context.CancelFunc
+	background sync.WaitGroup
+	verifying  map[string]bool
 }
 
-func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *connector.Connector, s *snapshot.Snapshot, vm *view_manager.ViewManager) *RPC {
+func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *connector.Connector, s *snapshot.Snapshot, vm *view_manager.ViewManager, h *health.Health, cl *cluster.Cluster, e *cluster.Election, r *reloader.Reloader, a *admin.Admin, m *metrics.Registry) *RPC {
 
 	logger = l.Named("RPC")
 
 	rpc := &RPC{
-		snapshot:    s,
-		connector:   c,
-		viewManager: vm,
+		config:        config,
+		snapshot:      s,
+		connector:     c,
+		viewManager:   vm,
+		healthChecker: h,
+		cluster:       cl,
+		election:      e,
+		reloader:      r,
+		admin:         a,
+		metrics:       m,
+		verifying:     make(map[string]bool),
 	}
 
+	rpc.ctx, rpc.cancel = context.WithCancel(context.Background())
+
 	lifecycle.Append(
 		fx.Hook{
 			OnStart: func(context.Context) error {
 
 				// Preparing prefix
 				prefix := fmt.Sprintf("$GRAVITY.%s.API.SNAPSHOT", rpc.connector.GetDomain())
-				rpc.routes = NewRoute(rpc, prefix)
+				rpc.prefix = prefix
+
+				// Requests are load balanced between instances of cluster
+				queue := ""
+				if rpc.cluster.IsEnabled() || rpc.election.IsEnabled() {
+					queue = prefix
+				}
+
+				rpc.routes = NewRoute(rpc, prefix, queue)
 
 				logger.Info("Initializing RPC",
 					zap.String("prefix", prefix),
+					zap.String("queue", queue),
 				)
-				return rpc.register()
+
+				err := rpc.register()
+				if err != nil {
+					return err
+				}
+
+				// Only leader accepts requests which make changes
+				if rpc.election.IsEnabled() {
+					rpc.election.Watch(rpc.onLeaderChanged)
+					return nil
+				}
+
+				if !rpc.cluster.IsEnabled() {
+					return nil
+				}
+
+				// Requests forwarded from other instances
+				rpc.clusterRoutes = NewRoute(rpc, prefix+".CLUSTER", "")
+
+				return rpc.registerCluster()
 			},
 			OnStop: func(ctx context.Context) error {
+
+				if rpc.routes == nil {
+					return nil
+				}
+
+				logger.Info("Stopping RPC...")
+				rpc.routes.Close()
+
+				if rpc.clusterRoutes != nil {
+					rpc.clusterRoutes.Close()
+				}
+
+				rpc.mutex.Lock()
+				leaderRoutes := rpc.leaderRoutes
+				rpc.mutex.Unlock()
+
+				rpc.onLeaderChanged(false)
+
+				// Waiting for requests in progress
+				for _, r := range []*Route{rpc.routes, rpc.clusterRoutes, leaderRoutes} {
+					if r == nil {
+						continue
+					}
+
+					err := r.Wait(ctx)
+					if err != nil {
+						logger.Warn("Requests are still in progress on shutdown", zap.Error(err))
+						break
+					}
+				}
+
+				// Requests in background are cancelled
+				rpc.cancel()
+				rpc.background.Wait()
+
 				return nil
 			},
 		},
@@ -54,51 +150,80 @@ func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *conne
 	return rpc
 }
 
-func (rpc *RPC) register() error {
-	rpc.routes.Handle("VIEW.CREATE", rpc.createSnapshotView)
-	rpc.routes.Handle("VIEW.DELETE", rpc.deleteSnapshotView)
-	rpc.routes.Handle("VIEW.PULL", rpc.pullSnapshotView)
-
-	return nil
+// getLeaderHandlers returns handlers which make changes to views or the running instance, they are handled by leader
+// only in HA mode
+func (rpc *RPC) getLeaderHandlers() map[string]func(*nats.Msg) {
+	return map[string]func(*nats.Msg){
+		"VIEW.CREATE":   rpc.createSnapshotView,
+		"VIEW.DELETE":   rpc.deleteSnapshotView,
+		"VIEW.PULL":     rpc.pullSnapshotView,
+		"BACKUP":        rpc.backup,
+		"CONFIG.RELOAD": rpc.reloadConfig,
+		"LOG.LEVEL":     rpc.setLogLevel,
+		"DEBUG.MERGE":   rpc.setMergeDebug,
+	}
 }
 
-func (rpc *RPC) assertStream(streamName string) error {
+func (rpc *RPC) register() error {
 
-	// Preparing JetStream
-	js, err := rpc.connector.GetClient().GetJetStream()
-	if err != nil {
-		return err
+	handlers := map[string]func(*nats.Msg){
+		"COLLECTION.EXPORT": rpc.exportCollection,
+		"COLLECTION.VERIFY": rpc.verifyCollection,
+		"COLLECTION.DIGEST": rpc.digestCollection,
+		"COLLECTION.STATUS": rpc.getCollectionStatus,
+		"HEALTH":            rpc.health,
+		"CLUSTER.STATUS":    rpc.getClusterStatus,
 	}
 
-	// Check if the stream already exists
-	stream, err := js.StreamInfo(streamName)
-	if err != nil {
-		logger.Warn(err.Error())
+	if !rpc.election.IsEnabled() {
+		for apiPath, h := range rpc.getLeaderHandlers() {
+			handlers[apiPath] = h
+		}
 	}
 
-	// New stream
-	if stream == nil {
-
-		subject := fmt.Sprintf("%s.*", streamName)
-
-		// Initializing stream
-		logger.Info("Creating stream for snapshot view...",
-			zap.String("stream", streamName),
-			zap.String("subject", subject),
-		)
+	return registerHandlers(rpc.routes, handlers)
+}
 
-		_, err := js.AddStream(&nats.StreamConfig{
-			Name:        streamName,
-			Description: "Gravity snapshot view",
-			Subjects: []string{
-				subject,
-			},
-		})
+func registerHandlers(r *Route, handlers map[string]func(*nats.Msg)) error {
 
+	for apiPath, h := range handlers {
+		err := r.Handle(apiPath, h)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	// Requests are accepted once subscriptions were processed by server
+	return r.rpc.connector.GetConnection().Flush()
+}
+
+func (rpc *RPC) onLeaderChanged(isLeader bool) {
+
+	rpc.mutex.Lock()
+	defer rpc.mutex.Unlock()
+
+	if !isLeader {
+
+		if rpc.leaderRoutes == nil {
+			return
+		}
+
+		logger.Info("Stopping RPC for leader...")
+		rpc.leaderRoutes.Close()
+		rpc.leaderRoutes = nil
+
+		return
+	}
+
+	if rpc.leaderRoutes != nil {
+		return
+	}
+
+	logger.Info("Initializing RPC for leader")
+
+	rpc.leaderRoutes = NewRoute(rpc, rpc.prefix, "")
+	err := registerHandlers(rpc.leaderRoutes, rpc.getLeaderHandlers())
+	if err != nil {
+		logger.Error(err.Error())
+	}
 }
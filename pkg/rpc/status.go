@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+type CollectionStatusRequest struct {
+	Collection string `json:"collection"`
+}
+
+type CollectionStatusReply struct {
+	*snapshot.CollectionStatus
+	Error *Error `json:"error,omitempty"`
+}
+
+func (rpc *RPC) getCollectionStatus(msg *nats.Msg) {
+
+	// Parsing request
+	var req CollectionStatusRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	// Partitions are spread over instances of cluster
+	if rpc.cluster.IsEnabled() && !rpc.isForwarded(msg) {
+		rpc.getClusterCollectionStatus(msg, req.Collection)
+		return
+	}
+
+	status, err := rpc.snapshot.GetCollectionStatus(req.Collection)
+	if err != nil {
+		if errors.Is(err, snapshot.ErrNotFoundCollection) {
+			rpc.respondError(msg, NotFoundCollectionErr())
+			return
+		}
+
+		logger.Error(err.Error(), zap.String("collection", req.Collection))
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return
+	}
+
+	rpc.respond(msg, &CollectionStatusReply{
+		CollectionStatus: status,
+	})
+}
+
+func (rpc *RPC) getClusterCollectionStatus(msg *nats.Msg, collection string) {
+
+	replies, err := rpc.gather("COLLECTION.STATUS", msg)
+	if err != nil {
+		logger.Error(err.Error(), zap.String("collection", collection))
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return
+	}
+
+	statuses := make([]*snapshot.CollectionStatus, 0, len(replies))
+	for _, data := range replies {
+
+		var reply CollectionStatusReply
+		err := json.Unmarshal(data, &reply)
+		if err != nil {
+			logger.Warn(err.Error())
+			continue
+		}
+
+		if reply.Error != nil {
+			rpc.respondError(msg, reply.Error)
+			return
+		}
+
+		statuses = append(statuses, reply.CollectionStatus)
+	}
+
+	if len(statuses) == 0 {
+		rpc.respondError(msg, InternalErr("No status was replied from cluster"))
+		return
+	}
+
+	rpc.respond(msg, &CollectionStatusReply{
+		CollectionStatus: snapshot.MergeCollectionStatus(statuses...),
+	})
+}
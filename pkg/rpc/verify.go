@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+type VerifyCollectionRequest struct {
+	Collection     string `json:"collection"`
+	MaxDifferences int    `json:"maxDifferences"`
+}
+
+type VerifyCollectionReply struct {
+	Consistent bool                         `json:"consistent"`
+	Report     *snapshot.VerificationReport `json:"report"`
+	Nodes      []string                     `json:"nodes,omitempty"`
+	Error      *Error                       `json:"error,omitempty"`
+}
+
+func (rpc *RPC) verifyCollection(msg *nats.Msg) {
+
+	// Parsing request
+	var req VerifyCollectionRequest
+	err := json.Unmarshal(msg.Data, &req)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	// Every instance verifies partitions it owns, the timeout of instances is included
+	if rpc.isClusterRequest(msg) {
+		timeout := time.Duration(rpc.config.Verify.Timeout)*time.Second + rpc.getClusterRequestTimeout()
+		rpc.fanOut("COLLECTION.VERIFY", msg, timeout, mergeVerifyReplies)
+		return
+	}
+
+	// Only one verification of collection is running at the same time
+	rpc.mutex.Lock()
+	if rpc.verifying[req.Collection] {
+		rpc.mutex.Unlock()
+		rpc.respondError(msg, InvalidRequestErr("Verification of collection is in progress"))
+		return
+	}
+
+	rpc.verifying[req.Collection] = true
+	rpc.mutex.Unlock()
+
+	// Verification takes a while, reply is sent when it was completed so other requests are not blocked
+	rpc.background.Add(1)
+	go func() {
+		defer rpc.background.Done()
+
+		rpc.verify(msg, &req)
+
+		rpc.mutex.Lock()
+		delete(rpc.verifying, req.Collection)
+		rpc.mutex.Unlock()
+	}()
+}
+
+func (rpc *RPC) verify(msg *nats.Msg, req *VerifyCollectionRequest) {
+
+	opts := make([]func(*snapshot.VerifyOptions), 0)
+	if req.MaxDifferences > 0 {
+		opts = append(opts, snapshot.WithMaxDifferences(req.MaxDifferences))
+	}
+
+	timeout := time.Duration(rpc.config.Verify.Timeout) * time.Second
+
+	ctx, cancel := context.WithTimeout(rpc.ctx, timeout)
+	defer cancel()
+
+	report, err := rpc.snapshot.Verify(ctx, req.Collection, opts...)
+	if err != nil {
+		if errors.Is(err, snapshot.ErrNotFoundCollection) {
+			rpc.respondError(msg, NotFoundCollectionErr())
+			return
+		}
+
+		logger.Error(err.Error(), zap.String("collection", req.Collection))
+		rpc.respondError(msg, InternalErr(err.Error()))
+		return
+	}
+
+	reply := &VerifyCollectionReply{
+		Consistent: report.IsConsistent(),
+		Report:     report,
+	}
+
+	if rpc.cluster.IsEnabled() {
+		reply.Nodes = []string{rpc.cluster.GetNodeID()}
+	}
+
+	rpc.respond(msg, reply)
+}
+
+func mergeVerifyReplies(replies [][]byte) (interface{}, *Error) {
+
+	reports := make([]*snapshot.VerificationReport, 0, len(replies))
+	nodes := make([]string, 0, len(replies))
+	for _, data := range replies {
+
+		var reply VerifyCollectionReply
+		err := json.Unmarshal(data, &reply)
+		if err != nil {
+			return nil, InternalErr(err.Error())
+		}
+
+		if reply.Error != nil {
+			return nil, reply.Error
+		}
+
+		reports = append(reports, reply.Report)
+		nodes = append(nodes, reply.Nodes...)
+	}
+
+	sort.Strings(nodes)
+	report := snapshot.MergeVerificationReports(reports...)
+
+	return &VerifyCollectionReply{
+		Consistent: report.IsConsistent(),
+		Report:     report,
+		Nodes:      nodes,
+	}, nil
+}
@@ -1,10 +1,12 @@
 package rpc
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"time"
 
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/tracing"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/view_manager"
 	"github.com/nats-io/nats.go"
 )
@@ -91,6 +93,23 @@ func (rpc *RPC) deleteSnapshotView(msg *nats.Msg) {
 		return
 	}
 
+	// View might be created by another instance of cluster
+	view, err := rpc.viewManager.GetView(req.ID)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	if view == nil {
+		if rpc.isForwarded(msg) {
+			return
+		}
+
+		if rpc.forward("VIEW.DELETE", msg) {
+			return
+		}
+	}
+
 	// Delete view
 	err = rpc.viewManager.DeleteView(req.ID)
 	if err != nil {
@@ -130,6 +149,17 @@ func (rpc *RPC) pullSnapshotView(msg *nats.Msg) {
 	}
 
 	if view == nil {
+
+		// Only instance which owns the view replies to forwarded request
+		if rpc.isForwarded(msg) {
+			return
+		}
+
+		// View might be created by another instance of cluster
+		if rpc.forward("VIEW.PULL", msg) {
+			return
+		}
+
 		e := &ErrorReply{
 			Error: NotFoundViewErr(),
 		}
@@ -152,9 +182,10 @@ func (rpc *RPC) pullSnapshotView(msg *nats.Msg) {
 		return
 	}
 
-	count, err := view.Fetch(lastKey, req.AfterLastKey)
+	count, err := view.Fetch(tracing.Extract(context.Background(), msg), lastKey, req.AfterLastKey)
 	if err != nil {
 		logger.Error(err.Error())
+		rpc.respondError(msg, InternalErr(err.Error()))
 		return
 	}
 
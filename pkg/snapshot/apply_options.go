@@ -0,0 +1,164 @@
+package snapshot
+
+import (
+	"context"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/tracing"
+	"github.com/cockroachdb/pebble"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// metaDeleted is set to meta of record which was deleted while tombstones are kept
+const metaDeleted = "deleted"
+
+var defaultApplyOptions = NewApplyOptions(&configs.CollectionConfig{})
+
+// ApplyOptions decides how events of collection are applied to snapshot
+type ApplyOptions struct {
+	MergeStrategy string
+	PrimaryKey    string
+	Tombstone     string
+	includeFields map[string]struct{}
+	excludeFields map[string]struct{}
+}
+
+func NewApplyOptions(config *configs.CollectionConfig) *ApplyOptions {
+
+	options := &ApplyOptions{
+		MergeStrategy: config.GetMergeStrategy(),
+		PrimaryKey:    config.PrimaryKey,
+		Tombstone:     config.GetTombstone(),
+	}
+
+	if len(config.IncludeFields) > 0 {
+		options.includeFields = make(map[string]struct{}, len(config.IncludeFields))
+		for _, name := range config.IncludeFields {
+			options.includeFields[name] = struct{}{}
+		}
+	}
+
+	if len(config.ExcludeFields) > 0 {
+		options.excludeFields = make(map[string]struct{}, len(config.ExcludeFields))
+		for _, name := range config.ExcludeFields {
+			options.excludeFields[name] = struct{}{}
+		}
+	}
+
+	return options
+}
+
+// filterFields returns top-level fields which should be stored, field of primary key is always kept
+func (options *ApplyOptions) filterFields(fields []*gravity_sdk_types_record.Field, primaryKey string) []*gravity_sdk_types_record.Field {
+
+	if options.includeFields == nil && options.excludeFields == nil {
+		return fields
+	}
+
+	filtered := fields[:0]
+	for _, field := range fields {
+
+		if field.Name != primaryKey {
+
+			if _, ok := options.excludeFields[field.Name]; ok {
+				continue
+			}
+
+			if _, ok := options.includeFields[field.Name]; options.includeFields != nil && !ok {
+				continue
+			}
+		}
+
+		filtered = append(filtered, field)
+	}
+
+	return filtered
+}
+
+// updateMeta sets meta data of record, keys which are specified are removed
+func updateMeta(record *gravity_sdk_types_snapshot_record.SnapshotRecord, meta map[string]interface{}, removes ...string) {
+
+	if len(meta) == 0 && len(removes) == 0 {
+		return
+	}
+
+	origMeta := record.Meta.AsMap()
+
+	for k, v := range meta {
+		origMeta[k] = v
+	}
+
+	for _, k := range removes {
+		delete(origMeta, k)
+	}
+
+	// Replace old meta data
+	m, _ := structpb.NewStruct(origMeta)
+	record.Meta = m
+}
+
+// IsTombstone returns true if record was deleted but kept as tombstone, tombstones are not read as records
+func IsTombstone(record *gravity_sdk_types_snapshot_record.SnapshotRecord) bool {
+	return record.Meta.GetFields()[metaDeleted].GetBoolValue()
+}
+
+// IsTombstoneData returns true if encoded record is tombstone
+func IsTombstoneData(data []byte) bool {
+
+	record := snapshotRecordPool.Get().(*gravity_sdk_types_snapshot_record.SnapshotRecord)
+	defer snapshotRecordPool.Put(record)
+
+	err := gravity_sdk_types_snapshot_record.Unmarshal(data, record)
+	if err != nil {
+		return false
+	}
+
+	return IsTombstone(record)
+}
+
+// markDeleted keeps record as tombstone rather than deleting it, checksum of tombstone is removed from digest
+func (handler *SnapshotHandler) markDeleted(ctx context.Context, meta map[string]interface{}, request *eventstore.SnapshotRequest, table []byte, primaryKey []byte) (string, error) {
+
+	origin, err := request.Get(table, primaryKey)
+	if err == pebble.ErrNotFound {
+		return OperationDelete, &SkipError{Reason: SkipReasonNotFound}
+	}
+
+	if err != nil {
+		return OperationDelete, err
+	}
+
+	record := snapshotRecordPool.Get().(*gravity_sdk_types_snapshot_record.SnapshotRecord)
+	defer snapshotRecordPool.Put(record)
+	err = gravity_sdk_types_snapshot_record.Unmarshal(origin, record)
+	if err != nil {
+		handler.logger.Error(err.Error())
+		return "", &SkipError{Reason: SkipReasonInvalidRecord, Err: err}
+	}
+
+	tombstone := map[string]interface{}{
+		metaDeleted: true,
+	}
+
+	for k, v := range meta {
+		tombstone[k] = v
+	}
+
+	updateMeta(record, tombstone)
+
+	data, err := record.ToBytes()
+	if err != nil {
+		handler.logger.Error(err.Error())
+		return "", &SkipError{Reason: SkipReasonEncodingFailed, Err: err}
+	}
+
+	_, span := tracing.Tracer().Start(ctx, "eventstore.tombstone", trace.WithAttributes(attribute.String("table", string(table))))
+	defer span.End()
+
+	return OperationDelete, writeRecord(request, table, primaryKey, data, nil)
+}
@@ -0,0 +1,230 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/backup"
+	"go.uber.org/zap"
+)
+
+// Backup writes a consistent archive of all stores and positions of partitions while running
+func (d *Snapshot) Backup(ctx context.Context, w io.Writer) (*backup.Manifest, error) {
+
+	tmpDir, err := ioutil.TempDir("", "gravity-snapshot-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest, err := d.checkpoint(ctx, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = backup.WriteArchive(w, manifest, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func (d *Snapshot) checkpoint(ctx context.Context, destDir string) (*backup.Manifest, error) {
+
+	// Pausing to apply new events
+	d.applyMutex.Lock()
+	defer d.applyMutex.Unlock()
+
+	err := d.waitForPendings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.storeMutex.Lock()
+	defer d.storeMutex.Unlock()
+
+	manifest := backup.NewManifest(d.connector.GetDomain())
+	for name, cs := range d.stores {
+
+		d.logger.Info("Creating checkpoint...",
+			zap.String("collection", name),
+		)
+
+		err := cs.SavePositions()
+		if err != nil {
+			return nil, err
+		}
+
+		err = backup.CheckpointStore(cs.store, filepath.Join(destDir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.SetPositions(name, cs.GetPositions())
+	}
+
+	return manifest, nil
+}
+
+// BackupDatastore writes archive of datastore which is not being used by service
+func BackupDatastore(w io.Writer, datastorePath string, domain string) (*backup.Manifest, error) {
+
+	es, err := OpenDatastore(datastorePath)
+	if err != nil {
+		return nil, err
+	}
+	defer es.Close()
+
+	tmpDir, err := ioutil.TempDir("", "gravity-snapshot-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	entries, err := ioutil.ReadDir(datastorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := backup.NewManifest(domain)
+	for _, entry := range entries {
+
+		if !entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		store, err := es.GetStore(name)
+		if err != nil {
+			return nil, err
+		}
+
+		positions, err := LoadPositions(store)
+		if err != nil {
+			return nil, err
+		}
+
+		err = backup.CheckpointStore(store, filepath.Join(tmpDir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.SetPositions(name, positions)
+	}
+
+	err = backup.WriteArchive(w, manifest, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// RestoreDatastore rebuilds datastore from archive, consumers will resume from positions of backup on next start
+func RestoreDatastore(r io.Reader, datastorePath string, force bool) (*backup.Manifest, error) {
+
+	entries, err := ioutil.ReadDir(datastorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(entries) > 0 && !force {
+		return nil, fmt.Errorf("Datastore is not empty: %s", datastorePath)
+	}
+
+	// Archive is extracted next to datastore, so it replaces datastore by renaming only after it was verified
+	datastorePath = filepath.Clean(datastorePath)
+	parent := filepath.Dir(datastorePath)
+	err = os.MkdirAll(parent, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := ioutil.TempDir(parent, "."+filepath.Base(datastorePath)+"-restore")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest, err := backup.ExtractArchive(r, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = prepareRestoredDatastore(tmpDir, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid backup: %w", err)
+	}
+
+	if len(entries) == 0 {
+		err := os.RemoveAll(datastorePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return manifest, os.Rename(tmpDir, datastorePath)
+	}
+
+	// Original datastore is moved back if it cannot be replaced
+	oldDir := tmpDir + "-old"
+	err = os.Rename(datastorePath, oldDir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Rename(tmpDir, datastorePath)
+	if err != nil {
+		if e := os.Rename(oldDir, datastorePath); e != nil {
+			return nil, fmt.Errorf("%v, original datastore was kept in %s", err, oldDir)
+		}
+
+		return nil, err
+	}
+
+	return manifest, os.RemoveAll(oldDir)
+}
+
+// prepareRestoredDatastore verifies stores of all collections in manifest and sets positions to resume from
+func prepareRestoredDatastore(datastorePath string, manifest *backup.Manifest) error {
+
+	es, err := OpenDatastore(datastorePath)
+	if err != nil {
+		return err
+	}
+	defer es.Close()
+
+	for name, state := range manifest.Collections {
+
+		// Store would be created if it was missing
+		info, err := os.Stat(filepath.Join(datastorePath, name))
+		if err != nil {
+			return fmt.Errorf("collection %s: %w", name, err)
+		}
+
+		if !info.IsDir() {
+			return fmt.Errorf("collection %s: not a directory", name)
+		}
+
+		store, err := es.GetStore(name)
+		if err != nil {
+			return fmt.Errorf("collection %s: %w", name, err)
+		}
+
+		_, err = AssertDigest(store)
+		if err != nil {
+			return fmt.Errorf("collection %s: %w", name, err)
+		}
+
+		err = SetRestorePositions(store, state.Partitions)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package snapshot
+
+import (
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// configureCollection applies settings of collection block, settings of applying events are taken by store of collection
+func (d *Snapshot) configureCollection(c *Collection) {
+
+	config := d.config.GetCollectionConfig(c.GetName())
+
+	// Position was validated with config
+	position, _ := configs.ParseStartPosition(config.StartPosition)
+	c.SetStartPosition(position)
+
+	if len(config.Partitions) > 0 {
+		c.SetPartitions(config.Partitions)
+	}
+
+	c.SetRetention(time.Duration(config.Retention) * time.Second)
+
+	d.logger.Info("Configured collection",
+		zap.String("collection", c.GetName()),
+		zap.String("startPosition", position.Mode),
+		zap.Int("partitions", len(c.GetPartitions())),
+		zap.String("mergeStrategy", config.GetMergeStrategy()),
+		zap.String("tombstone", config.GetTombstone()),
+		zap.Int("workers", config.Workers),
+	)
+}
+
+func setStartPosition(config *nats.ConsumerConfig, position *configs.StartPosition) {
+
+	switch position.Mode {
+	case configs.StartFirst:
+		config.DeliverPolicy = nats.DeliverAllPolicy
+	case configs.StartLast:
+		config.DeliverPolicy = nats.DeliverLastPolicy
+	case configs.StartSequence:
+		config.DeliverPolicy = nats.DeliverByStartSequencePolicy
+		config.OptStartSeq = position.Sequence
+	case configs.StartTime:
+		config.DeliverPolicy = nats.DeliverByStartTimePolicy
+		config.OptStartTime = &position.Time
+	}
+}
@@ -0,0 +1,67 @@
+package snapshot
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+type CollectionPattern struct {
+	expr   string
+	regexp *regexp.Regexp
+}
+
+// IsCollectionPattern returns true if expression is a glob ("erp_*") or a regular expression ("/^erp_.+$/")
+func IsCollectionPattern(expr string) bool {
+
+	if isRegexpPattern(expr) {
+		return true
+	}
+
+	return strings.ContainsAny(expr, "*?[")
+}
+
+func isRegexpPattern(expr string) bool {
+	return len(expr) > 2 && strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/")
+}
+
+func NewCollectionPattern(expr string) (*CollectionPattern, error) {
+
+	cp := &CollectionPattern{
+		expr: expr,
+	}
+
+	if isRegexpPattern(expr) {
+		re, err := regexp.Compile(expr[1 : len(expr)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		cp.regexp = re
+
+		return cp, nil
+	}
+
+	// Validate glob pattern
+	_, err := path.Match(expr, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+func (cp *CollectionPattern) String() string {
+	return cp.expr
+}
+
+func (cp *CollectionPattern) Match(name string) bool {
+
+	if cp.regexp != nil {
+		return cp.regexp.MatchString(name)
+	}
+
+	matched, _ := path.Match(cp.expr, name)
+
+	return matched
+}
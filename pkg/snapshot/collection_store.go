@@ -0,0 +1,126 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type CollectionStore struct {
+	name          string
+	key           uint64
+	store         *eventstore.Store
+	pending       int64
+	positions     map[uint64]uint64
+	positionDirty bool
+	positionMutex sync.Mutex
+	inflight      sync.Map
+	progress      *Progress
+	options       *ApplyOptions
+	workers       *workerPool
+}
+
+type pendingEvent struct {
+	partition   uint64
+	timestamp   time.Time
+	spanContext trace.SpanContext
+	msg         *nats.Msg
+}
+
+// complete acknowledges event after it was applied, event will be delivered again if it was failed
+func (event *pendingEvent) complete(err error) error {
+
+	if event.msg == nil {
+		return nil
+	}
+
+	if err != nil {
+		return event.msg.Nak()
+	}
+
+	return event.msg.Ack()
+}
+
+func NewCollectionStore(name string, store *eventstore.Store) *CollectionStore {
+
+	// Events of collection are applied by the same shared worker in order
+	h := fnv.New64a()
+	h.Write([]byte(name))
+
+	return &CollectionStore{
+		name:      name,
+		key:       h.Sum64(),
+		store:     store,
+		positions: make(map[uint64]uint64),
+		progress:  NewProgress(),
+		options:   defaultApplyOptions,
+	}
+}
+
+func (cs *CollectionStore) GetName() string {
+	return cs.name
+}
+
+func (cs *CollectionStore) GetStore() *eventstore.Store {
+	return cs.store
+}
+
+// GetPendingCount returns the number of events which are waiting to be applied to snapshot
+func (cs *CollectionStore) GetPendingCount() int64 {
+	return atomic.LoadInt64(&cs.pending)
+}
+
+func (cs *CollectionStore) increasePending() {
+	atomic.AddInt64(&cs.pending, 1)
+}
+
+func (cs *CollectionStore) decreasePending() {
+	atomic.AddInt64(&cs.pending, -1)
+}
+
+// waitForPendings blocks until all events which were dispatched are applied
+func (cs *CollectionStore) waitForPendings(ctx context.Context) error {
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for cs.GetPendingCount() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("Timeout waiting for pending events of collection \"%s\": %v", cs.name, ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// dispatch keeps information of event for workers to continue the trace and report progress when event was applied
+func (cs *CollectionStore) dispatch(seq uint64, event *pendingEvent) {
+	cs.increasePending()
+	cs.inflight.Store(seq, event)
+}
+
+// isPending returns true if event was dispatched to workers but not yet applied
+func (cs *CollectionStore) isPending(seq uint64) bool {
+	_, ok := cs.inflight.Load(seq)
+	return ok
+}
+
+// takePendingEvent returns information of event which was dispatched to workers
+func (cs *CollectionStore) takePendingEvent(seq uint64) *pendingEvent {
+
+	v, ok := cs.inflight.LoadAndDelete(seq)
+	if !ok {
+		return nil
+	}
+
+	return v.(*pendingEvent)
+}
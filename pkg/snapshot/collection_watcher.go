@@ -1,25 +1,161 @@
 package snapshot
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
-	"github.com/BrobridgeOrg/gravity-sdk/core"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
 	"github.com/nats-io/nats.go"
 )
 
+// DrainTimeout is the maximum time to wait for events which were delivered when partition was released
+const DrainTimeout = 30 * time.Second
+
+// ErrLegacyStream is returned if stream was found by name of earlier releases only
+var ErrLegacyStream = errors.New("Found stream of legacy name")
+
 type Collection struct {
-	client     *core.Client
-	domain     string
-	partitions []uint64
-	name       string
+	client         *connector.Connector
+	logger         *zap.Logger
+	domain         string
+	partitions     []uint64
+	filter         func(string, uint64) bool
+	acquireHandler func(*Collection, uint64)
+	handler        func(string, uint64, *nats.Msg)
+	name           string
+	startPosition  *configs.StartPosition
+	retention      time.Duration
+	resets         map[uint64]uint64
+	resetHandler   func(*Collection, uint64)
+	subscriptions  map[uint64]*nats.Subscription
+	consumerInfos  map[uint64]*nats.ConsumerInfo
+	consumerErr    error
+	mutex          sync.Mutex
 }
 
 func NewCollection() *Collection {
 	return &Collection{
-		partitions: make([]uint64, 0),
+		partitions:    make([]uint64, 0),
+		resets:        make(map[uint64]uint64),
+		subscriptions: make(map[uint64]*nats.Subscription),
+	}
+}
+
+func (c *Collection) GetName() string {
+	return c.name
+}
+
+// SetStartPosition specifies where consumers which are not created yet start from
+func (c *Collection) SetStartPosition(position *configs.StartPosition) {
+	c.startPosition = position
+}
+
+// SetPartitions specifies partitions of collection to be watched
+func (c *Collection) SetPartitions(partitions []uint64) {
+	c.partitions = partitions
+}
+
+// SetRetention specifies maximum age of events in stream of collection, zero for no limit
+func (c *Collection) SetRetention(retention time.Duration) {
+	c.retention = retention
+}
+
+// ResetPartition makes consumer of partition to be recreated from specific stream sequence
+func (c *Collection) ResetPartition(partition uint64, seq uint64) {
+	c.mutex.Lock()
+	c.resets[partition] = seq
+	c.mutex.Unlock()
+}
+
+// SetResetHandler specifies function to be called after consumer of partition was recreated
+func (c *Collection) SetResetHandler(fn func(*Collection, uint64)) {
+	c.resetHandler = fn
+}
+
+// isResetting returns true if consumer of partition is going to be recreated
+func (c *Collection) isResetting(partition uint64) bool {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.resets[partition]
+
+	return ok
+}
+
+// GetStreamName returns name of stream which keeps events of collection, names of streams cannot contain '.'
+func GetStreamName(domain string, collection string) string {
+	return fmt.Sprintf("GRAVITY_%s_COLLECTION_%s", domain, collection)
+}
+
+// getLegacyStreamName returns name of stream which kept events of collection before streams were renamed
+func getLegacyStreamName(domain string, collection string) string {
+	return fmt.Sprintf("GRAVITY-%s.COLLECTION.%s", domain, collection)
+}
+
+// HasStream returns true if stream is listed by server, stream of legacy name cannot be looked up by StreamInfo
+// because its name contains '.'
+func HasStream(js nats.JetStreamContext, streamName string) bool {
+
+	found := false
+	for name := range js.StreamNames() {
+		if name == streamName {
+			found = true
+		}
 	}
+
+	return found
+}
+
+// GetSubjectPrefix returns prefix of subjects which events of collection are published to,
+// events of partition are published to "<prefix>.<partition>.EVENT.<event name>"
+func GetSubjectPrefix(domain string, collection string) string {
+	return fmt.Sprintf("GRAVITY-%s.COLLECTION.%s", domain, collection)
+}
+
+func (c *Collection) getStreamName() string {
+	return GetStreamName(c.domain, c.name)
+}
+
+func (c *Collection) getSubjectPrefix() string {
+	return GetSubjectPrefix(c.domain, c.name)
+}
+
+// getDurableName returns name of consumer of partition, consumer is shared by nodes in cluster mode and it is recreated
+// by the node which acquired partition
+func (c *Collection) getDurableName(partition uint64) string {
+	return fmt.Sprintf("%s-%s-%d-SNAPSHOT", c.domain, c.name, partition)
+}
+
+// GetPartitions returns all partitions of collection
+func (c *Collection) GetPartitions() []uint64 {
+	return c.partitions
+}
+
+func (c *Collection) isAssigned(partition uint64) bool {
+
+	if c.filter == nil {
+		return true
+	}
+
+	return c.filter(c.name, partition)
+}
+
+func (c *Collection) isWatching(partition uint64) bool {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, ok := c.subscriptions[partition]
+
+	return ok
 }
 
 func (c *Collection) assertStream(streamName string) error {
@@ -33,18 +169,28 @@ func (c *Collection) assertStream(streamName string) error {
 	// Check if the stream already exists
 	stream, err := js.StreamInfo(streamName)
 	if err != nil {
-		logger.Warn(err.Error())
+		c.logger.Warn(err.Error())
+	}
+
+	// Stream of legacy name captures subjects of collection, it should be migrated instead of being ignored
+	if err == nats.ErrStreamNotFound {
+		legacyName := getLegacyStreamName(c.domain, c.name)
+		if HasStream(js, legacyName) {
+			return fmt.Errorf("%w: %s should be migrated to %s", ErrLegacyStream, legacyName, streamName)
+		}
 	}
 
 	// New stream
 	if stream == nil {
 
-		subject := fmt.Sprintf("%s.*", streamName)
+		// Events of all partitions
+		subject := fmt.Sprintf("%s.>", c.getSubjectPrefix())
 
 		// Initializing stream
-		logger.Info("Creating stream...",
+		c.logger.Info("Creating stream...",
 			zap.String("stream", streamName),
 			zap.String("subject", subject),
+			zap.Duration("retention", c.retention),
 		)
 
 		_, err := js.AddStream(&nats.StreamConfig{
@@ -53,11 +199,121 @@ func (c *Collection) assertStream(streamName string) error {
 			Subjects: []string{
 				subject,
 			},
+			MaxAge: c.retention,
 		})
 
 		if err != nil {
 			return err
 		}
+
+		return nil
+	}
+
+	// Stream is owned by upstream, so retention which was specified is only applied to stream created by snapshot
+	if c.retention == 0 || stream.Config.MaxAge == c.retention {
+		return nil
+	}
+
+	c.logger.Warn("Retention of existing stream differs from settings, it is not changed",
+		zap.String("stream", streamName),
+		zap.Duration("maxAge", stream.Config.MaxAge),
+		zap.Duration("retention", c.retention),
+	)
+
+	return nil
+}
+
+func (c *Collection) assertConsumer(partition uint64, streamName string, durableName string, subject string) error {
+
+	// Preparing JetStream
+	js, err := c.client.GetJetStream()
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	resetSeq, reset := c.resets[partition]
+	c.mutex.Unlock()
+
+	if reset {
+		return c.resetConsumer(partition, streamName, durableName, subject, resetSeq)
+	}
+
+	// Check if the consumer already exists
+	consumer, err := js.ConsumerInfo(streamName, durableName)
+	if err != nil && err != nats.ErrConsumerNotFound {
+		c.logger.Warn(err.Error())
+	}
+
+	if consumer != nil {
+		return nil
+	}
+
+	// Create consumer explicitly, so it will not be removed by library when unsubscribing
+	c.logger.Info("Creating consumer...",
+		zap.String("stream", streamName),
+		zap.String("durable", durableName),
+	)
+
+	return c.addConsumer(js, streamName, durableName, subject, 0)
+}
+
+func (c *Collection) addConsumer(js nats.JetStreamContext, streamName string, durableName string, subject string, startSeq uint64) error {
+
+	config := &nats.ConsumerConfig{
+		Durable:        durableName,
+		DeliverSubject: nats.NewInbox(),
+		DeliverPolicy:  nats.DeliverNewPolicy,
+		AckPolicy:      nats.AckExplicitPolicy,
+		FilterSubject:  subject,
+	}
+
+	// Resume from specific position
+	if startSeq > 0 {
+		config.DeliverPolicy = nats.DeliverByStartSequencePolicy
+		config.OptStartSeq = startSeq
+	} else if c.startPosition != nil {
+		setStartPosition(config, c.startPosition)
+	}
+
+	_, err := js.AddConsumer(streamName, config)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Collection) resetConsumer(partition uint64, streamName string, durableName string, subject string, startSeq uint64) error {
+
+	// Preparing JetStream
+	js, err := c.client.GetJetStream()
+	if err != nil {
+		return err
+	}
+
+	c.logger.Info("Resetting consumer...",
+		zap.String("stream", streamName),
+		zap.String("durable", durableName),
+		zap.Uint64("startSeq", startSeq),
+	)
+
+	err = js.DeleteConsumer(streamName, durableName)
+	if err != nil && err != nats.ErrConsumerNotFound {
+		return err
+	}
+
+	err = c.addConsumer(js, streamName, durableName, subject, startSeq)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	delete(c.resets, partition)
+	c.mutex.Unlock()
+
+	if c.resetHandler != nil {
+		c.resetHandler(c, partition)
 	}
 
 	return nil
@@ -65,9 +321,9 @@ func (c *Collection) assertStream(streamName string) error {
 
 func (c *Collection) watch(partition uint64, fn func(string, uint64, *nats.Msg)) error {
 
-	streamName := fmt.Sprintf("GRAVITY-%s.COLLECTION.%s", c.domain, c.name)
-	subject := fmt.Sprintf("%s.%d.EVENT.*", streamName, partition)
-	durableName := fmt.Sprintf("%s-%s-%d-SNAPSHOT", c.domain, c.name, partition)
+	streamName := c.getStreamName()
+	subject := fmt.Sprintf("%s.%d.EVENT.*", c.getSubjectPrefix(), partition)
+	durableName := c.getDurableName(partition)
 
 	// Preparing JetStream
 	js, err := c.client.GetJetStream()
@@ -75,82 +331,648 @@ func (c *Collection) watch(partition uint64, fn func(string, uint64, *nats.Msg))
 		return err
 	}
 
-	logger.Info("Watching collection",
+	err = c.assertConsumer(partition, streamName, durableName, subject)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Info("Watching collection",
 		zap.String("stream", streamName),
+		zap.Uint64("partition", partition),
 	)
 
-	// Subscribe to stream
-	_, err = js.Subscribe(subject, func(msg *nats.Msg) {
+	// Subscribe to stream, events are acknowledged by handler after they were applied
+	sub, err := js.Subscribe(subject, func(msg *nats.Msg) {
 		fn(c.name, partition, msg)
-	}, nats.DeliverNew(), nats.AckAll(), nats.Durable(durableName))
+	}, nats.Bind(streamName, durableName), nats.ManualAck())
 	if err != nil {
 		return err
 	}
 
+	c.mutex.Lock()
+	c.subscriptions[partition] = sub
+	c.mutex.Unlock()
+
 	return nil
 }
 
 func (c *Collection) Watch(fn func(string, uint64, *nats.Msg)) error {
 
-	logger.Info("Subscribing to collection",
+	c.logger.Info("Subscribing to collection",
 		zap.String("name", c.name),
 	)
 
-	streamName := fmt.Sprintf("GRAVITY-%s.COLLECTION.%s", c.domain, c.name)
+	c.mutex.Lock()
+	c.handler = fn
+	c.mutex.Unlock()
+
+	err := c.assertStream(c.getStreamName())
+	if err != nil {
+		return err
+	}
+
+	for _, partition := range c.partitions {
+
+		// Partition is owned by another node
+		if !c.isAssigned(partition) {
+			continue
+		}
+
+		err := c.acquire(partition, fn)
+		if err != nil {
+			c.logger.Error(err.Error(),
+				zap.String("collection", c.name),
+				zap.Uint64("partition", partition),
+			)
+		}
+	}
+
+	return nil
+}
+
+// acquire starts consuming partition which was not consumed by this instance
+func (c *Collection) acquire(partition uint64, fn func(string, uint64, *nats.Msg)) error {
+
+	// Consumer may be left by another node, so it is prepared to continue from data of local store
+	if c.acquireHandler != nil {
+		c.acquireHandler(c, partition)
+	}
+
+	return c.watch(partition, fn)
+}
+
+// Refresh starts or stops consuming partitions according to assignment, it returns partitions which were no longer
+// consumed and all events delivered by them were dispatched
+func (c *Collection) Refresh() []uint64 {
+
+	c.mutex.Lock()
+	fn := c.handler
+	c.mutex.Unlock()
+
+	released := make([]uint64, 0)
+
+	// Collection is not being watched yet
+	if fn == nil {
+		return released
+	}
+
+	for _, partition := range c.partitions {
+
+		assigned := c.isAssigned(partition)
+		watching := c.isWatching(partition)
+
+		if assigned && !watching {
+			err := c.acquire(partition, fn)
+			if err != nil {
+				c.logger.Error(err.Error(),
+					zap.String("collection", c.name),
+					zap.Uint64("partition", partition),
+				)
+			}
+		} else if !assigned && watching {
+			if c.unwatchPartition(partition) {
+				released = append(released, partition)
+			}
+		}
+	}
+
+	return released
+}
+
+// unwatchPartition stops consuming partition, it returns true after subscription was drained
+func (c *Collection) unwatchPartition(partition uint64) bool {
+
+	c.mutex.Lock()
+	sub, ok := c.subscriptions[partition]
+	delete(c.subscriptions, partition)
+	c.mutex.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	c.logger.Info("Stop watching partition",
+		zap.String("collection", c.name),
+		zap.Uint64("partition", partition),
+	)
+
+	// Events which were delivered already will be processed
+	err := sub.Drain()
+	if err != nil {
+		c.logger.Warn(err.Error(),
+			zap.String("collection", c.name),
+			zap.Uint64("partition", partition),
+		)
+		return false
+	}
+
+	// Subscription becomes invalid after all delivered events were handled
+	timeout := time.After(DrainTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for sub.IsValid() {
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			c.logger.Warn("Timeout waiting for partition to be drained",
+				zap.String("collection", c.name),
+				zap.Uint64("partition", partition),
+			)
+			return false
+		}
+	}
+
+	return true
+}
+
+// Recover verifies stream and consumers of partitions being watched, partitions will be resubscribed if subscription or consumer was lost
+func (c *Collection) Recover(positions map[uint64]uint64) (int, error) {
+
+	c.mutex.Lock()
+	fn := c.handler
+	subscriptions := make(map[uint64]*nats.Subscription, len(c.subscriptions))
+	for partition, sub := range c.subscriptions {
+		subscriptions[partition] = sub
+	}
+	c.mutex.Unlock()
 
+	// Collection is not being watched yet
+	if fn == nil {
+		return 0, nil
+	}
+
+	streamName := c.getStreamName()
 	err := c.assertStream(streamName)
+	if err != nil {
+		return 0, err
+	}
+
+	js, err := c.client.GetJetStream()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for partition, sub := range subscriptions {
+
+		_, err := js.ConsumerInfo(streamName, c.getDurableName(partition))
+		if err != nil && err != nats.ErrConsumerNotFound {
+			return count, err
+		}
+
+		consumerLost := err == nats.ErrConsumerNotFound
+		if !consumerLost && sub.IsValid() {
+			continue
+		}
+
+		c.logger.Warn("Resubscribing to partition",
+			zap.String("collection", c.name),
+			zap.Uint64("partition", partition),
+			zap.Bool("consumerLost", consumerLost),
+		)
+
+		// Events which were not applied will be delivered by new subscription
+		c.mutex.Lock()
+		delete(c.subscriptions, partition)
+		c.mutex.Unlock()
+
+		err = sub.Unsubscribe()
+		if err != nil && err != nats.ErrBadSubscription {
+			c.logger.Warn(err.Error())
+		}
+
+		// Continue from the last position which was applied to snapshot, partition which has nothing applied starts from
+		// the first event of stream
+		if consumerLost {
+			c.ResetPartition(partition, positions[partition]+1)
+		}
+
+		err = c.watch(partition, fn)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// Unwatch stops consuming all partitions, consumers of partitions owned by this instance are deleted if deleteDurable is true
+func (c *Collection) Unwatch(deleteDurable bool) error {
+
+	c.mutex.Lock()
+	subscriptions := c.subscriptions
+	c.subscriptions = make(map[uint64]*nats.Subscription)
+	c.mutex.Unlock()
+
+	for partition, sub := range subscriptions {
+
+		err := sub.Unsubscribe()
+		if err != nil {
+			c.logger.Warn(err.Error(),
+				zap.String("collection", c.name),
+				zap.Uint64("partition", partition),
+			)
+		}
+	}
+
+	if !deleteDurable {
+		return nil
+	}
+
+	// Preparing JetStream
+	js, err := c.client.GetJetStream()
 	if err != nil {
 		return err
 	}
 
+	streamName := c.getStreamName()
 	for _, partition := range c.partitions {
-		c.watch(partition, fn)
+
+		// Consumers of partitions which are owned by other nodes are kept
+		_, acquired := subscriptions[partition]
+		if !acquired && !c.isAssigned(partition) {
+			continue
+		}
+
+		durableName := c.getDurableName(partition)
+
+		c.logger.Info("Deleting consumer...",
+			zap.String("stream", streamName),
+			zap.String("durable", durableName),
+		)
+
+		err := js.DeleteConsumer(streamName, durableName)
+		if err != nil && err != nats.ErrConsumerNotFound {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func (c *Collection) Drain() {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for partition, sub := range c.subscriptions {
+		err := sub.Drain()
+		if err != nil {
+			c.logger.Warn(err.Error(),
+				zap.String("collection", c.name),
+				zap.Uint64("partition", partition),
+			)
+		}
+	}
+}
+
+// GetConsumerInfos returns states of consumers of all partitions which are being watched, error of the first partition
+// which failed is returned with states of others
+func (c *Collection) GetConsumerInfos() (map[uint64]*nats.ConsumerInfo, error) {
+
+	c.mutex.Lock()
+	subscriptions := make(map[uint64]*nats.Subscription, len(c.subscriptions))
+	for partition, sub := range c.subscriptions {
+		subscriptions[partition] = sub
+	}
+	c.mutex.Unlock()
+
+	var lastErr error
+	for _, partition := range c.GetPartitions() {
+		if _, ok := subscriptions[partition]; !ok && c.isAssigned(partition) {
+			lastErr = fmt.Errorf("Partition %d is not being watched", partition)
+			break
+		}
+	}
+
+	infos := make(map[uint64]*nats.ConsumerInfo, len(subscriptions))
+	for partition, sub := range subscriptions {
+		info, err := sub.ConsumerInfo()
+		if err != nil {
+			c.logger.Warn(err.Error(),
+				zap.String("collection", c.name),
+				zap.Uint64("partition", partition),
+			)
+
+			if lastErr == nil {
+				lastErr = fmt.Errorf("Failed to get consumer of partition %d: %v", partition, err)
+			}
+
+			continue
+		}
+
+		infos[partition] = info
+	}
+
+	return infos, lastErr
+}
+
+// sampleConsumers refreshes states of consumers, so they can be served without requesting server
+func (c *Collection) sampleConsumers() (map[uint64]*nats.ConsumerInfo, error) {
+
+	infos, err := c.GetConsumerInfos()
+
+	c.mutex.Lock()
+	c.consumerInfos = infos
+	c.consumerErr = err
+	c.mutex.Unlock()
+
+	return infos, err
+}
+
+// GetSampledConsumerInfos returns states of consumers which were taken by the last sampling of progress,
+// error is returned if consumers were not sampled yet or the last sampling failed
+func (c *Collection) GetSampledConsumerInfos() (map[uint64]*nats.ConsumerInfo, error) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.consumerInfos == nil {
+		return nil, fmt.Errorf("Consumers of collection %s were not sampled yet", c.name)
+	}
+
+	infos := make(map[uint64]*nats.ConsumerInfo, len(c.consumerInfos))
+	for partition, info := range c.consumerInfos {
+		infos[partition] = info
+	}
+
+	return infos, c.consumerErr
+}
+
+func (c *Collection) isDrained() bool {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, sub := range c.subscriptions {
+		if sub.IsValid() {
+			return false
+		}
+	}
+
+	return true
+}
+
 type CollectionWatcher struct {
-	client      *core.Client
-	domain      string
-	collections map[string]*Collection
+	client            *connector.Connector
+	logger            *zap.Logger
+	domain            string
+	partitions        []uint64
+	filter            func(string, uint64) bool
+	acquireHandler    func(*Collection, uint64)
+	collections       map[string]*Collection
+	unregistered      map[string]bool
+	patterns          []*CollectionPattern
+	handler           func(string, uint64, *nats.Msg)
+	initializer       func(*Collection) error
+	configurator      func(*Collection)
+	discoveryInterval time.Duration
+	discoveryTrigger  chan struct{}
+	discovering       bool
+	closed            chan struct{}
+	mutex             sync.RWMutex
 }
 
-func NewCollectionWatcher(client *core.Client, domain string) *CollectionWatcher {
+func NewCollectionWatcher(client *connector.Connector, domain string, logger *zap.Logger) *CollectionWatcher {
 	return &CollectionWatcher{
-		client:      client,
-		domain:      domain,
-		collections: make(map[string]*Collection),
+		client:            client,
+		logger:            logger,
+		domain:            domain,
+		collections:       make(map[string]*Collection),
+		unregistered:      make(map[string]bool),
+		patterns:          make([]*CollectionPattern, 0),
+		discoveryInterval: 30 * time.Second,
+		discoveryTrigger:  make(chan struct{}, 1),
+		closed:            make(chan struct{}),
 	}
 }
 
+func (ew *CollectionWatcher) getStreamPrefix() string {
+	return GetStreamName(ew.domain, "")
+}
+
+// SetCollectionInitializer specifies function to prepare collection before watching
+func (ew *CollectionWatcher) SetCollectionInitializer(fn func(*Collection) error) {
+	ew.initializer = fn
+}
+
+// SetCollectionConfigurator specifies function to apply settings to collection when it was registered
+func (ew *CollectionWatcher) SetCollectionConfigurator(fn func(*Collection)) {
+	ew.configurator = fn
+}
+
+func (ew *CollectionWatcher) watchCollection(collection *Collection) error {
+
+	if ew.initializer != nil {
+		err := ew.initializer(collection)
+		if err != nil {
+			return err
+		}
+	}
+
+	return collection.Watch(ew.handler)
+}
+
+func (ew *CollectionWatcher) SetDiscoveryInterval(interval time.Duration) {
+	ew.discoveryInterval = interval
+}
+
+// SetPartitions specifies partitions of collections to be watched
+func (ew *CollectionWatcher) SetPartitions(partitions []uint64) {
+
+	ew.mutex.Lock()
+	defer ew.mutex.Unlock()
+
+	ew.partitions = partitions
+	for _, c := range ew.collections {
+		c.partitions = partitions
+	}
+}
+
+// SetPartitionFilter specifies function to decide whether partition of collection should be consumed
+func (ew *CollectionWatcher) SetPartitionFilter(fn func(string, uint64) bool) {
+
+	ew.mutex.Lock()
+	defer ew.mutex.Unlock()
+
+	ew.filter = fn
+	for _, c := range ew.collections {
+		c.filter = fn
+	}
+}
+
+// SetAcquireHandler specifies function to be called before partition which was not consumed is watched
+func (ew *CollectionWatcher) SetAcquireHandler(fn func(*Collection, uint64)) {
+
+	ew.mutex.Lock()
+	defer ew.mutex.Unlock()
+
+	ew.acquireHandler = fn
+	for _, c := range ew.collections {
+		c.acquireHandler = fn
+	}
+}
+
+// Refresh makes all collections follow assignment of partitions, it returns partitions of collections which were released
+func (ew *CollectionWatcher) Refresh() map[string][]uint64 {
+
+	released := make(map[string][]uint64)
+	for _, c := range ew.GetCollections() {
+
+		partitions := c.Refresh()
+		if len(partitions) > 0 {
+			released[c.GetName()] = partitions
+		}
+	}
+
+	return released
+}
+
+func (ew *CollectionWatcher) RegisterPattern(expr string) error {
+
+	cp, err := NewCollectionPattern(expr)
+	if err != nil {
+		return err
+	}
+
+	ew.mutex.Lock()
+	ew.patterns = append(ew.patterns, cp)
+	started := ew.handler != nil
+	ew.mutex.Unlock()
+
+	// Pattern was registered after started watching, collections should be discovered right now
+	if started {
+		ew.startDiscovery()
+		ew.triggerDiscovery()
+	}
+
+	return nil
+}
+
+// UnregisterPattern stops discovering collections by pattern, collections which were discovered already are still being watched
+func (ew *CollectionWatcher) UnregisterPattern(expr string) {
+
+	ew.mutex.Lock()
+	defer ew.mutex.Unlock()
+
+	patterns := make([]*CollectionPattern, 0, len(ew.patterns))
+	for _, cp := range ew.patterns {
+		if cp.String() != expr {
+			patterns = append(patterns, cp)
+		}
+	}
+
+	ew.patterns = patterns
+}
+
+// GetPatterns returns expressions of all patterns
+func (ew *CollectionWatcher) GetPatterns() []string {
+
+	ew.mutex.RLock()
+	defer ew.mutex.RUnlock()
+
+	exprs := make([]string, 0, len(ew.patterns))
+	for _, cp := range ew.patterns {
+		exprs = append(exprs, cp.String())
+	}
+
+	return exprs
+}
+
+func (ew *CollectionWatcher) matchPatterns(name string) bool {
+
+	ew.mutex.RLock()
+	defer ew.mutex.RUnlock()
+
+	for _, cp := range ew.patterns {
+		if cp.Match(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (ew *CollectionWatcher) RegisterCollection(name string) *Collection {
 
+	ew.mutex.Lock()
+	defer ew.mutex.Unlock()
+
 	if e, ok := ew.collections[name]; ok {
 		return e
 	}
 
+	// Collection is registered explicitly, so it can be discovered again
+	delete(ew.unregistered, name)
+
 	e := NewCollection()
+	e.client = ew.client
+	e.logger = ew.logger
+	e.domain = ew.domain
+	e.partitions = ew.partitions
+	e.filter = ew.filter
+	e.acquireHandler = ew.acquireHandler
 	e.name = name
 
-	subject := fmt.Sprintf("GRAVITY-%s.COLLECTION.%s", ew.domain, name)
+	if ew.configurator != nil {
+		ew.configurator(e)
+	}
 
-	ew.collections[subject] = e
+	ew.collections[name] = e
 
 	return e
 }
 
-func (ew *CollectionWatcher) UnregisterCollection(name string) {
+// AddCollection registers collection, it will be watched immediately if watcher was started already
+func (ew *CollectionWatcher) AddCollection(name string) error {
 
-	if _, ok := ew.collections[name]; !ok {
-		return
+	ew.mutex.RLock()
+	_, exists := ew.collections[name]
+	started := ew.handler != nil
+	ew.mutex.RUnlock()
+
+	collection := ew.RegisterCollection(name)
+	if exists || !started {
+		return nil
 	}
 
+	return ew.watchCollection(collection)
+}
+
+func (ew *CollectionWatcher) UnregisterCollection(name string, deleteDurable bool) error {
+
+	ew.mutex.Lock()
+	collection, ok := ew.collections[name]
+	if !ok {
+		ew.mutex.Unlock()
+		return nil
+	}
+
+	// Collection should not be discovered by patterns again
 	delete(ew.collections, name)
+	ew.unregistered[name] = true
+	ew.mutex.Unlock()
+
+	return collection.Unwatch(deleteDurable)
+}
+
+// IsUnregistered returns true if collection was unregistered and has not been registered again
+func (ew *CollectionWatcher) IsUnregistered(name string) bool {
+
+	ew.mutex.RLock()
+	defer ew.mutex.RUnlock()
+
+	return ew.unregistered[name]
 }
 
 func (ew *CollectionWatcher) GetCollection(name string) *Collection {
 
+	ew.mutex.RLock()
+	defer ew.mutex.RUnlock()
+
 	if v, ok := ew.collections[name]; ok {
 		return v
 	}
@@ -158,20 +980,180 @@ func (ew *CollectionWatcher) GetCollection(name string) *Collection {
 	return nil
 }
 
+func (ew *CollectionWatcher) GetCollections() []*Collection {
+
+	ew.mutex.RLock()
+	defer ew.mutex.RUnlock()
+
+	collections := make([]*Collection, 0, len(ew.collections))
+	for _, c := range ew.collections {
+		collections = append(collections, c)
+	}
+
+	return collections
+}
+
 func (ew *CollectionWatcher) Watch(fn func(string, uint64, *nats.Msg)) error {
 
-	logger.Info("Starting watch collections...")
+	ew.logger.Info("Starting watch collections...")
+
+	ew.mutex.Lock()
+	ew.handler = fn
+	hasPatterns := len(ew.patterns) > 0
+	ew.mutex.Unlock()
+
+	// Collections which match patterns will be watched by discovery
+	if hasPatterns {
+		defer ew.startDiscovery()
+	}
+
+	// Collections can be registered by discovery or reloading while subscribing
+	for _, collection := range ew.GetCollections() {
+
+		err := ew.watchCollection(collection)
+		if err != nil {
+			ew.logger.Warn(err.Error())
+			continue
+		}
+
+		ew.logger.Info(fmt.Sprintf("    Watched %s", collection.name))
+	}
+
+	return nil
+}
 
+func (ew *CollectionWatcher) Stop() {
+	close(ew.closed)
+}
+
+// Drain stops receiving new events and waits until all delivered events are processed
+func (ew *CollectionWatcher) Drain(ctx context.Context) error {
+
+	ew.mutex.RLock()
+	collections := make([]*Collection, 0, len(ew.collections))
 	for _, collection := range ew.collections {
+		collections = append(collections, collection)
+	}
+	ew.mutex.RUnlock()
+
+	ew.logger.Info("Draining collections...")
+
+	for _, collection := range collections {
+		collection.Drain()
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for _, collection := range collections {
+		for !collection.isDrained() {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return fmt.Errorf("Timeout draining collection \"%s\": %v", collection.name, ctx.Err())
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ew *CollectionWatcher) discover() error {
+
+	// Preparing JetStream
+	js, err := ew.client.GetJetStream()
+	if err != nil {
+		return err
+	}
 
-		err := collection.Watch(fn)
+	prefix := ew.getStreamPrefix()
+	for streamName := range js.StreamNames() {
+
+		if !strings.HasPrefix(streamName, prefix) {
+			continue
+		}
+
+		name := streamName[len(prefix):]
+
+		// Ignore collections which are being watched already
+		if ew.GetCollection(name) != nil {
+			continue
+		}
+
+		if !ew.matchPatterns(name) {
+			continue
+		}
+
+		// Collection was unregistered on purpose
+		if ew.IsUnregistered(name) {
+			continue
+		}
+
+		ew.logger.Info(fmt.Sprintf("Discovered collection: %s", name))
+
+		collection := ew.RegisterCollection(name)
+		err := ew.watchCollection(collection)
 		if err != nil {
-			logger.Warn(err.Error())
+			ew.logger.Warn(err.Error())
 			continue
 		}
 
-		logger.Info(fmt.Sprintf("    Watched %s", collection.name))
+		ew.logger.Info(fmt.Sprintf("    Watched %s", collection.name))
 	}
 
 	return nil
 }
+
+// startDiscovery starts discovering collections by patterns if it was not started yet
+func (ew *CollectionWatcher) startDiscovery() {
+
+	ew.mutex.Lock()
+	defer ew.mutex.Unlock()
+
+	if ew.discovering {
+		return
+	}
+
+	ew.discovering = true
+
+	go ew.runDiscovery()
+}
+
+func (ew *CollectionWatcher) triggerDiscovery() {
+	select {
+	case ew.discoveryTrigger <- struct{}{}:
+	default:
+	}
+}
+
+func (ew *CollectionWatcher) runDiscovery() {
+
+	// Being notified when new stream was created
+	conn := ew.client.GetConnection()
+	sub, err := conn.Subscribe("$JS.EVENT.ADVISORY.STREAM.CREATED.>", func(msg *nats.Msg) {
+		ew.triggerDiscovery()
+	})
+	if err != nil {
+		ew.logger.Warn(err.Error())
+	} else {
+		defer sub.Unsubscribe()
+	}
+
+	ticker := time.NewTicker(ew.discoveryInterval)
+	defer ticker.Stop()
+
+	for {
+
+		err := ew.discover()
+		if err != nil {
+			ew.logger.Error(err.Error())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ew.discoveryTrigger:
+		case <-ew.closed:
+			return
+		}
+	}
+}
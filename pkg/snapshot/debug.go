@@ -0,0 +1,93 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"go.uber.org/zap"
+)
+
+type mergeDebugKey struct{}
+
+// MergeDebug specifies record of collection whose inputs and outputs of merging are logged for every event
+type MergeDebug struct {
+	Collection string     `json:"collection"`
+	PrimaryKey string     `json:"primaryKey"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (md *MergeDebug) isExpired() bool {
+	return md.ExpiresAt != nil && time.Now().After(*md.ExpiresAt)
+}
+
+// SetMergeDebug turns on logging of merging for record of collection, it is turned off after duration unless duration is zero.
+// Only one record can be debugged at the same time.
+func (d *Snapshot) SetMergeDebug(collection string, primaryKey string, duration time.Duration) *MergeDebug {
+
+	md := &MergeDebug{
+		Collection: collection,
+		PrimaryKey: primaryKey,
+	}
+
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		md.ExpiresAt = &expiresAt
+	}
+
+	d.mergeDebug.Store(md)
+
+	d.logger.Info("Merge debugging was turned on",
+		zap.String("collection", collection),
+		zap.String("primaryKey", primaryKey),
+		zap.Duration("duration", duration),
+	)
+
+	return md
+}
+
+// ClearMergeDebug turns off logging of merging
+func (d *Snapshot) ClearMergeDebug() {
+
+	if d.GetMergeDebug() == nil {
+		return
+	}
+
+	d.mergeDebug.Store((*MergeDebug)(nil))
+
+	d.logger.Info("Merge debugging was turned off")
+}
+
+// GetMergeDebug returns record which is being debugged, it returns nil if merge debugging is off
+func (d *Snapshot) GetMergeDebug() *MergeDebug {
+
+	md, _ := d.mergeDebug.Load().(*MergeDebug)
+	if md == nil || md.isExpired() {
+		return nil
+	}
+
+	return md
+}
+
+// withMergeDebug makes handler log merging if record of collection is being debugged
+func (d *Snapshot) withMergeDebug(ctx context.Context, collection string) context.Context {
+
+	md := d.GetMergeDebug()
+	if md == nil || md.Collection != collection {
+		return ctx
+	}
+
+	return context.WithValue(ctx, mergeDebugKey{}, md)
+}
+
+// isMergeDebugging returns true if merging of record should be logged
+func isMergeDebugging(ctx context.Context, primaryKey *gravity_sdk_types_record.Value) bool {
+
+	md, ok := ctx.Value(mergeDebugKey{}).(*MergeDebug)
+	if !ok {
+		return false
+	}
+
+	return fmt.Sprint(GetValue(primaryKey)) == md.PrimaryKey
+}
@@ -0,0 +1,537 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"github.com/cockroachdb/pebble"
+)
+
+const (
+	ChecksumStatePrefix = "_checksum."
+	BucketStatePrefix   = "_bucket."
+	DigestStateName     = "_digest"
+	DigestVersion       = 3
+	DigestBucketCount   = 256
+)
+
+type BucketDigest struct {
+	ID    int    `json:"id"`
+	Hash  string `json:"hash"`
+	Count uint64 `json:"count"`
+}
+
+type TableDigest struct {
+	Table   string          `json:"table"`
+	Root    string          `json:"root"`
+	Count   uint64          `json:"count"`
+	Buckets []*BucketDigest `json:"buckets"`
+}
+
+type CollectionDigest struct {
+	Collection string         `json:"collection"`
+	Root       string         `json:"root"`
+	Count      uint64         `json:"count"`
+	Tables     []*TableDigest `json:"tables"`
+}
+
+type RecordChecksum struct {
+	Key      string `json:"key"`
+	Checksum string `json:"checksum"`
+}
+
+// ComputeChecksum returns content hash of payload, fields are sorted by name so it doesn't depend on order of merging
+func ComputeChecksum(payload *gravity_sdk_types_record.Value) ([]byte, error) {
+
+	data, err := json.Marshal(GetValue(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return sum[:], nil
+}
+
+// GetBucket returns ID of bucket which primary key belongs to
+func GetBucket(primaryKey []byte) int {
+	sum := sha256.Sum256(primaryKey)
+	return int(sum[0]) % DigestBucketCount
+}
+
+func getChecksumKey(table []byte, primaryKey []byte) []byte {
+	return bytes.Join([][]byte{
+		[]byte(ChecksumStatePrefix),
+		table,
+		[]byte("-"),
+		primaryKey,
+	}, []byte(""))
+}
+
+func getBucketKey(table []byte, bucket int) []byte {
+	return []byte(fmt.Sprintf("%s%s-%02x", BucketStatePrefix, table, bucket))
+}
+
+// getContribution returns hash which record contributes to bucket, buckets are combined with XOR so records can be updated incrementally
+func getContribution(primaryKey []byte, checksum []byte) []byte {
+
+	h := sha256.New()
+	h.Write(primaryKey)
+	h.Write(checksum)
+
+	return h.Sum(nil)
+}
+
+func xorBytes(dst []byte, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// Buckets are read and written by workers of different partitions at the same time
+var bucketLocks [DigestBucketCount]sync.Mutex
+
+func isDigestKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(ChecksumStatePrefix)) || bytes.HasPrefix(key, []byte(BucketStatePrefix))
+}
+
+// ComputeDataChecksum returns hash of encoded record, it is used if content hash of payload cannot be computed
+func ComputeDataChecksum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// writeRecord writes record to snapshot with its checksum and bucket of digest in the same batch, record is deleted if data is nil
+func writeRecord(request *eventstore.SnapshotRequest, table []byte, primaryKey []byte, data []byte, checksum []byte) error {
+
+	cf, err := request.Store.GetColumnFamailyHandle("snapshot")
+	if err != nil {
+		return err
+	}
+
+	lock := &bucketLocks[GetBucket(primaryKey)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	batch := cf.Db.NewBatch()
+	defer batch.Close()
+
+	key := bytes.Join([][]byte{
+		table,
+		primaryKey,
+	}, []byte("-"))
+
+	if data == nil {
+		batch.Delete(key, nil)
+	} else {
+		batch.Set(key, data, nil)
+	}
+
+	err = updateDigest(cf.Db, batch, table, primaryKey, checksum)
+	if err != nil {
+		return err
+	}
+
+	err = batch.Commit(pebble.NoSync)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	// Tables are listed by their snapshot states
+	return request.UpdateDurableState(table)
+}
+
+// updateDigest replaces checksum of record and updates bucket in batch, record will be removed if checksum is nil
+func updateDigest(db *pebble.DB, batch *pebble.Batch, table []byte, primaryKey []byte, checksum []byte) error {
+
+	checksumKey := getChecksumKey(table, primaryKey)
+
+	// Getting original checksum
+	var origin []byte
+	value, closer, err := db.Get(checksumKey)
+	if err == nil {
+		origin = make([]byte, len(value))
+		copy(origin, value)
+		closer.Close()
+	} else if err != pebble.ErrNotFound {
+		return err
+	}
+
+	if bytes.Equal(origin, checksum) {
+		return nil
+	}
+
+	// Getting bucket
+	bucketKey := getBucketKey(table, GetBucket(primaryKey))
+
+	hash := make([]byte, sha256.Size)
+	count := uint64(0)
+	value, closer, err = db.Get(bucketKey)
+	if err == nil {
+		if len(value) == sha256.Size+8 {
+			copy(hash, value[:sha256.Size])
+			count = binary.BigEndian.Uint64(value[sha256.Size:])
+		}
+		closer.Close()
+	} else if err != pebble.ErrNotFound {
+		return err
+	}
+
+	if origin != nil {
+		xorBytes(hash, getContribution(primaryKey, origin))
+		count--
+	}
+
+	if checksum != nil {
+		xorBytes(hash, getContribution(primaryKey, checksum))
+		count++
+		batch.Set(checksumKey, checksum, nil)
+	} else {
+		batch.Delete(checksumKey, nil)
+	}
+
+	bucketValue := make([]byte, sha256.Size+8)
+	copy(bucketValue, hash)
+	binary.BigEndian.PutUint64(bucketValue[sha256.Size:], count)
+	batch.Set(bucketKey, bucketValue, nil)
+
+	return nil
+}
+
+// clearDigest removes checksums and buckets from database
+func clearDigest(db *pebble.DB) error {
+
+	for _, prefix := range []string{ChecksumStatePrefix, BucketStatePrefix} {
+		err := db.DeleteRange([]byte(prefix), []byte(prefix[:len(prefix)-1]+"/"), pebble.NoSync)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RebuildDigest computes checksums of all records in snapshot, it is used for snapshot which was created without digest
+func RebuildDigest(store *eventstore.Store) error {
+
+	cf, err := store.GetColumnFamailyHandle("snapshot")
+	if err != nil {
+		return err
+	}
+
+	// Digest was kept separately from records by earlier versions
+	states, err := store.GetColumnFamailyHandle("states")
+	if err != nil {
+		return err
+	}
+
+	for _, db := range []*pebble.DB{cf.Db, states.Db} {
+		err := clearDigest(db)
+		if err != nil {
+			return err
+		}
+	}
+
+	tables, err := getTables(store)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+
+		err := ScanSnapshot(store, table, func(key []byte, record *gravity_sdk_types_snapshot_record.SnapshotRecord) error {
+
+			checksum, err := ComputeChecksum(record.Payload)
+			if err != nil {
+				data, _ := record.ToBytes()
+				checksum = ComputeDataChecksum(data)
+			}
+
+			batch := cf.Db.NewBatch()
+			defer batch.Close()
+
+			err = updateDigest(cf.Db, batch, []byte(table), key, checksum)
+			if err != nil {
+				return err
+			}
+
+			return batch.Commit(pebble.NoSync)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return store.UpdateDurableState(DigestStateName, DigestVersion)
+}
+
+// AssertDigest rebuilds digest if it is not available for current version
+func AssertDigest(store *eventstore.Store) (bool, error) {
+
+	version, err := store.GetDurableState(DigestStateName)
+	if err != nil {
+		return false, err
+	}
+
+	if version == DigestVersion {
+		return false, nil
+	}
+
+	return true, RebuildDigest(store)
+}
+
+// GetDigest returns bucket hashes and Merkle roots of all tables in store
+func GetDigest(collection string, store *eventstore.Store) (*CollectionDigest, error) {
+
+	cf, err := store.GetColumnFamailyHandle("snapshot")
+	if err != nil {
+		return nil, err
+	}
+
+	view := cf.Db.NewSnapshot()
+	defer view.Close()
+
+	iter := view.NewIter(&pebble.IterOptions{
+		LowerBound: []byte(BucketStatePrefix),
+		UpperBound: []byte(BucketStatePrefix[:len(BucketStatePrefix)-1] + "/"),
+	})
+	defer iter.Close()
+
+	tables := make(map[string]*TableDigest)
+	for iter.First(); iter.Valid(); iter.Next() {
+
+		value := iter.Value()
+		if len(value) != sha256.Size+8 {
+			continue
+		}
+
+		count := binary.BigEndian.Uint64(value[sha256.Size:])
+		if count == 0 {
+			continue
+		}
+
+		// Parsing table name and bucket ID from key
+		key := strings.TrimPrefix(string(iter.Key()), BucketStatePrefix)
+		idx := strings.LastIndex(key, "-")
+		if idx == -1 {
+			continue
+		}
+
+		bucket, err := hex.DecodeString(key[idx+1:])
+		if err != nil || len(bucket) != 1 {
+			continue
+		}
+
+		table := key[:idx]
+		td, ok := tables[table]
+		if !ok {
+			td = &TableDigest{
+				Table:   table,
+				Buckets: make([]*BucketDigest, 0),
+			}
+			tables[table] = td
+		}
+
+		td.Count += count
+		td.Buckets = append(td.Buckets, &BucketDigest{
+			ID:    int(bucket[0]),
+			Hash:  hex.EncodeToString(value[:sha256.Size]),
+			Count: count,
+		})
+	}
+
+	return newCollectionDigest(collection, tables), nil
+}
+
+// MergeDigests combines digests of the same collection which were taken from different nodes, buckets are combined with
+// XOR like records in bucket, so the result is the same as digest of a single node which keeps all records
+func MergeDigests(digests ...*CollectionDigest) *CollectionDigest {
+
+	collection := ""
+	tables := make(map[string]*TableDigest)
+	for _, digest := range digests {
+
+		collection = digest.Collection
+		for _, source := range digest.Tables {
+
+			td, ok := tables[source.Table]
+			if !ok {
+				td = &TableDigest{
+					Table:   source.Table,
+					Buckets: make([]*BucketDigest, 0),
+				}
+				tables[source.Table] = td
+			}
+
+			for _, b := range source.Buckets {
+				td.Count += b.Count
+				td.Buckets = mergeBucket(td.Buckets, b)
+			}
+		}
+	}
+
+	for _, td := range tables {
+		sort.Slice(td.Buckets, func(i, j int) bool {
+			return td.Buckets[i].ID < td.Buckets[j].ID
+		})
+	}
+
+	return newCollectionDigest(collection, tables)
+}
+
+func mergeBucket(buckets []*BucketDigest, bucket *BucketDigest) []*BucketDigest {
+
+	hash, err := hex.DecodeString(bucket.Hash)
+	if err != nil || len(hash) != sha256.Size {
+		return buckets
+	}
+
+	for _, b := range buckets {
+
+		if b.ID != bucket.ID {
+			continue
+		}
+
+		merged, _ := hex.DecodeString(b.Hash)
+		xorBytes(merged, hash)
+		b.Hash = hex.EncodeToString(merged)
+		b.Count += bucket.Count
+
+		return buckets
+	}
+
+	return append(buckets, &BucketDigest{
+		ID:    bucket.ID,
+		Hash:  bucket.Hash,
+		Count: bucket.Count,
+	})
+}
+
+func newCollectionDigest(collection string, tables map[string]*TableDigest) *CollectionDigest {
+
+	digest := &CollectionDigest{
+		Collection: collection,
+		Tables:     make([]*TableDigest, 0, len(tables)),
+	}
+
+	for _, td := range tables {
+		td.Root = hex.EncodeToString(computeMerkleRoot(td.Buckets))
+		digest.Count += td.Count
+		digest.Tables = append(digest.Tables, td)
+	}
+
+	sort.Slice(digest.Tables, func(i, j int) bool {
+		return digest.Tables[i].Table < digest.Tables[j].Table
+	})
+
+	// Root of collection
+	h := sha256.New()
+	for _, td := range digest.Tables {
+		h.Write([]byte(td.Table))
+		h.Write([]byte(td.Root))
+	}
+
+	digest.Root = hex.EncodeToString(h.Sum(nil))
+
+	return digest
+}
+
+// computeMerkleRoot builds binary Merkle tree on all buckets, empty bucket is zero hash
+func computeMerkleRoot(buckets []*BucketDigest) []byte {
+
+	nodes := make([][]byte, DigestBucketCount)
+	for i := range nodes {
+		nodes[i] = make([]byte, sha256.Size)
+	}
+
+	for _, b := range buckets {
+		hash, err := hex.DecodeString(b.Hash)
+		if err != nil {
+			continue
+		}
+
+		nodes[b.ID] = hash
+	}
+
+	for len(nodes) > 1 {
+		parents := make([][]byte, len(nodes)/2)
+		for i := range parents {
+			h := sha256.New()
+			h.Write(nodes[i*2])
+			h.Write(nodes[i*2+1])
+			parents[i] = h.Sum(nil)
+		}
+
+		nodes = parents
+	}
+
+	return nodes[0]
+}
+
+// GetBucketChecksums returns checksums of records which are in specific buckets of table
+func GetBucketChecksums(store *eventstore.Store, table string, buckets []int) (map[int][]*RecordChecksum, error) {
+
+	cf, err := store.GetColumnFamailyHandle("snapshot")
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := getTables(store)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := NewTableKeyFilter(table, tables)
+
+	results := make(map[int][]*RecordChecksum)
+	for _, bucket := range buckets {
+		results[bucket] = make([]*RecordChecksum, 0)
+	}
+
+	prefix := getChecksumKey([]byte(table), []byte(""))
+	upperBound := make([]byte, len(prefix))
+	copy(upperBound, prefix)
+	upperBound[len(upperBound)-1]++
+
+	iter := cf.Db.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: upperBound,
+	})
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+
+		primaryKey := iter.Key()[len(prefix):]
+		if !filter(primaryKey) {
+			continue
+		}
+
+		bucket := GetBucket(primaryKey)
+
+		records, ok := results[bucket]
+		if !ok {
+			continue
+		}
+
+		results[bucket] = append(records, &RecordChecksum{
+			Key:      FormatKey(primaryKey),
+			Checksum: hex.EncodeToString(iter.Value()),
+		})
+	}
+
+	return results, nil
+}
@@ -1,13 +1,19 @@
 package snapshot
 
 import (
+	"context"
+	"fmt"
 	"sync"
 
 	eventstore "github.com/BrobridgeOrg/EventStore"
 	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
 	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
-	log "github.com/sirupsen/logrus"
-	structpb "google.golang.org/protobuf/types/known/structpb"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/tracing"
+	"github.com/cockroachdb/pebble"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 var recordPool = sync.Pool{
@@ -22,108 +28,261 @@ var snapshotRecordPool = sync.Pool{
 	},
 }
 
+// Operations which were made by handler
+const (
+	OperationUpsert = "upsert"
+	OperationDelete = "delete"
+)
+
+// Reasons of records which were skipped by handler
+const (
+	SkipReasonInvalidRecord     = "invalid_record"
+	SkipReasonInvalidPrimaryKey = "invalid_primary_key"
+	SkipReasonNoPrimaryKey      = "no_primary_key"
+	SkipReasonEncodingFailed    = "encoding_failed"
+	SkipReasonNotFound          = "not_found"
+)
+
+// SkipError is returned when record was ignored by handler, snapshot is still consistent so event can be acknowledged
+type SkipError struct {
+	Reason string
+	Err    error
+}
+
+func (e *SkipError) Error() string {
+
+	if e.Err == nil {
+		return e.Reason
+	}
+
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *SkipError) Unwrap() error {
+	return e.Err
+}
+
 type SnapshotHandler struct {
+	logger *zap.Logger
+}
+
+func NewSnapshotHandler(opts ...func(*SnapshotHandler)) *SnapshotHandler {
+
+	handler := &SnapshotHandler{
+		logger: zap.L(),
+	}
+
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	return handler
 }
 
-func NewSnapshotHandler() *SnapshotHandler {
-	return &SnapshotHandler{}
+// WithHandlerLogger specifies logger for records which cannot be applied, global logger is used by default
+func WithHandlerLogger(l *zap.Logger) func(*SnapshotHandler) {
+	return func(handler *SnapshotHandler) {
+		handler.logger = l
+	}
 }
 
-func (handler *SnapshotHandler) handle(meta map[string]interface{}, request *eventstore.SnapshotRequest) error {
+// Apply writes record to snapshot of store synchronously, it is used to seed snapshot without events from stream
+func (handler *SnapshotHandler) Apply(store *eventstore.Store, seq uint64, data []byte, options *ApplyOptions) error {
+
+	request := eventstore.NewSnapshotRequest()
+	request.Store = store
+	request.Sequence = seq
+	request.Data = data
+
+	meta := map[string]interface{}{
+		"revision": seq,
+	}
+
+	_, err := handler.handle(context.Background(), meta, request, options)
+	if _, ok := err.(*SkipError); ok {
+		return nil
+	}
+
+	return err
+}
+
+func (handler *SnapshotHandler) handle(ctx context.Context, meta map[string]interface{}, request *eventstore.SnapshotRequest, options *ApplyOptions) (string, error) {
+
+	if options == nil {
+		options = defaultApplyOptions
+	}
 
 	// Parsing original data which from database
 	newData := recordPool.Get().(*gravity_sdk_types_record.Record)
 	defer recordPool.Put(newData)
 	err := gravity_sdk_types_record.Unmarshal(request.Data, newData)
+	if err != nil {
+		// Ignore
+		handler.logger.Error(err.Error())
+		return "", &SkipError{Reason: SkipReasonInvalidRecord, Err: err}
+	}
+
+	// Primary key of events is overridden by settings of collection
+	if len(options.PrimaryKey) > 0 {
+		newData.PrimaryKey = options.PrimaryKey
+	}
 
 	// Getting data of primary key
 	primaryKeyValue, err := newData.GetPrimaryKeyValue()
 	if err != nil {
 		// Ignore
-		log.Error(err)
-		return nil
+		handler.logger.Error(err.Error())
+		return "", &SkipError{Reason: SkipReasonInvalidPrimaryKey, Err: err}
 	}
 
 	if primaryKeyValue == nil {
 		// Ignore record which has no primary key
-		return nil
+		return "", &SkipError{Reason: SkipReasonNoPrimaryKey}
 	}
 
 	primaryKey, err := primaryKeyValue.GetBytes()
 	if err != nil {
 		// Ignore
-		log.Error(err)
-		return nil
+		handler.logger.Error(err.Error())
+		return "", &SkipError{Reason: SkipReasonInvalidPrimaryKey, Err: err}
+	}
+
+	table := StrToBytes(newData.Table)
+
+	// Inputs are kept before applying because original record is changed by merging
+	debugging := isMergeDebugging(ctx, primaryKeyValue)
+	var originPayload interface{}
+	if debugging {
+		originPayload = getOriginPayload(request, table, primaryKey)
+	}
+
+	// Record is kept and marked as deleted
+	if newData.Method == gravity_sdk_types_record.Method_DELETE && options.Tombstone == configs.TombstoneKeep {
+		operation, err := handler.markDeleted(ctx, meta, request, table, primaryKey)
+		if debugging {
+			handler.logMerge(newData, request, options, originPayload, originPayload, zap.Bool("tombstone", true), zap.Error(err))
+		}
+
+		return operation, err
 	}
 
 	// Delete handlerrecord
 	if newData.Method == gravity_sdk_types_record.Method_DELETE {
-		return request.Delete(StrToBytes(newData.Table), primaryKey)
+		_, span := tracing.Tracer().Start(ctx, "eventstore.delete", trace.WithAttributes(attribute.String("table", newData.Table)))
+		err := writeRecord(request, table, primaryKey, nil, nil)
+		span.End()
+
+		if debugging {
+			handler.logMerge(newData, request, options, originPayload, nil, zap.Error(err))
+		}
+
+		return OperationDelete, err
+	}
+
+	// Preparing original record
+	origin, err := request.Get(table, primaryKey)
+	if err != nil && err != pebble.ErrNotFound {
+		return OperationUpsert, err
 	}
 
-	// Preparing record
+	originRecord := snapshotRecordPool.Get().(*gravity_sdk_types_snapshot_record.SnapshotRecord)
+	defer snapshotRecordPool.Put(originRecord)
+	err = gravity_sdk_types_snapshot_record.Unmarshal(origin, originRecord)
+	if err != nil {
+		handler.logger.Error(err.Error())
+		return "", &SkipError{Reason: SkipReasonInvalidRecord, Err: err}
+	}
+
+	// Update meta data, record which was marked as deleted is alive again
+	updateMeta(originRecord, meta, metaDeleted)
+
+	// Preparing new record
+	newData.Fields = options.filterFields(newData.Fields, newData.PrimaryKey)
 	newRecord := snapshotRecordPool.Get().(*gravity_sdk_types_snapshot_record.SnapshotRecord)
+	defer snapshotRecordPool.Put(newRecord)
 	newRecord.Payload = newData.GetPayload()
 
-	data, err := newRecord.ToBytes()
+	// Merged new data to original data, merging is done here rather than by store so content hash of result is available
+	updatedData := handler.merge(originRecord, newRecord, options.MergeStrategy)
+
+	if debugging {
+		handler.logMerge(newData, request, options, originPayload, GetValue(originRecord.Payload))
+	}
+
+	// Record is still written if content hash is unavailable, its digest is computed from encoded record instead
+	checksum, err := ComputeChecksum(originRecord.Payload)
 	if err != nil {
-		log.Error(err)
-		return nil
+		handler.logger.Warn("Failed to compute checksum of record",
+			zap.String("table", newData.Table),
+			zap.Uint64("sequence", request.Sequence),
+			zap.Error(err),
+		)
+		checksum = ComputeDataChecksum(updatedData)
 	}
 
-	// Release record
-	snapshotRecordPool.Put(newRecord)
+	// Write to snapshot
+	_, span := tracing.Tracer().Start(ctx, "eventstore.upsert", trace.WithAttributes(attribute.String("table", newData.Table)))
+	defer span.End()
 
-	// Upsert to snapshot
-	err = request.Upsert(StrToBytes(newData.Table), primaryKey, data, func(origin []byte, newValue []byte) []byte {
+	return OperationUpsert, writeRecord(request, table, primaryKey, updatedData, checksum)
+}
 
-		// Preparing original record
-		originRecord := snapshotRecordPool.Get().(*gravity_sdk_types_snapshot_record.SnapshotRecord)
-		err := gravity_sdk_types_snapshot_record.Unmarshal(origin, originRecord)
-		if err != nil {
-			log.Error(err)
-			return origin
-		}
+// logMerge logs inputs and result of applying event to record which is being debugged
+func (handler *SnapshotHandler) logMerge(newData *gravity_sdk_types_record.Record, request *eventstore.SnapshotRequest, options *ApplyOptions, origin interface{}, result interface{}, fields ...zap.Field) {
 
-		// Preparing new record
-		newRecord := snapshotRecordPool.Get().(*gravity_sdk_types_snapshot_record.SnapshotRecord)
-		err = gravity_sdk_types_snapshot_record.Unmarshal(newValue, newRecord)
-		if err != nil {
-			log.Error(err)
-			return origin
-		}
+	fields = append([]zap.Field{
+		zap.String("table", newData.Table),
+		zap.Uint64("sequence", request.Sequence),
+		zap.String("method", newData.Method.String()),
+		zap.String("strategy", options.MergeStrategy),
+		zap.Any("origin", origin),
+		zap.Any("changes", GetValue(newData.GetPayload())),
+		zap.Any("result", result),
+	}, fields...)
 
-		// Update meta data
-		if len(meta) > 0 {
-			origMeta := originRecord.Meta.AsMap()
+	handler.logger.Info("Merged record", fields...)
+}
 
-			for k, v := range meta {
-				origMeta[k] = v
-			}
+// getOriginPayload returns payload of record in snapshot, it returns nil if record does not exist
+func getOriginPayload(request *eventstore.SnapshotRequest, table []byte, primaryKey []byte) interface{} {
 
-			// Replace old meta data
-			m, _ := structpb.NewStruct(origMeta)
-			originRecord.Meta = m
-		}
+	origin, err := request.Get(table, primaryKey)
+	if err != nil || len(origin) == 0 {
+		return nil
+	}
+
+	record := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+	err = gravity_sdk_types_snapshot_record.Unmarshal(origin, record)
+	if err != nil {
+		return nil
+	}
 
-		// Merged new data to original data
-		updatedData := handler.merge(originRecord, newRecord)
+	return GetValue(record.Payload)
+}
+
+// MergeData merges encoded snapshot records, it returns original data if failed to decode records
+func (handler *SnapshotHandler) MergeData(origin []byte, newValue []byte) []byte {
 
-		// Release record
-		snapshotRecordPool.Put(originRecord)
-		snapshotRecordPool.Put(newRecord)
+	if len(origin) == 0 {
+		return newValue
+	}
 
-		return updatedData
-	})
+	originRecord := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+	err := gravity_sdk_types_snapshot_record.Unmarshal(origin, originRecord)
+	if err != nil {
+		return origin
+	}
 
+	newRecord := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+	err = gravity_sdk_types_snapshot_record.Unmarshal(newValue, newRecord)
 	if err != nil {
-		return err
+		return origin
 	}
 
-	return nil
+	return handler.merge(originRecord, newRecord, configs.MergeDeep)
 }
 
-func (handler *SnapshotHandler) applyChanges(orig *gravity_sdk_types_record.Value, changes *gravity_sdk_types_record.Value) {
+func (handler *SnapshotHandler) applyChanges(orig *gravity_sdk_types_record.Value, changes *gravity_sdk_types_record.Value, deep bool) {
 
 	if orig == nil || changes == nil {
 		return
@@ -150,12 +309,18 @@ func (handler *SnapshotHandler) applyChanges(orig *gravity_sdk_types_record.Valu
 			continue
 		}
 
+		// Nested maps and arrays are replaced rather than merged
+		if !deep {
+			f.Value = field.Value
+			continue
+		}
+
 		// check type to update
 		switch f.Value.Type {
 		case gravity_sdk_types_record.DataType_ARRAY:
 			f.Value.Array = field.Value.Array
 		case gravity_sdk_types_record.DataType_MAP:
-			handler.applyChanges(f.Value, field.Value)
+			handler.applyChanges(f.Value, field.Value, deep)
 		default:
 			// update value
 			f.Value.Value = field.Value.Value
@@ -164,7 +329,12 @@ func (handler *SnapshotHandler) applyChanges(orig *gravity_sdk_types_record.Valu
 
 }
 
-func (handler *SnapshotHandler) merge(origRecord *gravity_sdk_types_snapshot_record.SnapshotRecord, updates *gravity_sdk_types_snapshot_record.SnapshotRecord) []byte {
+func (handler *SnapshotHandler) merge(origRecord *gravity_sdk_types_snapshot_record.SnapshotRecord, updates *gravity_sdk_types_snapshot_record.SnapshotRecord, strategy string) []byte {
+
+	// Existing payload is dropped
+	if strategy == configs.MergeReplace {
+		origRecord.Payload = nil
+	}
 
 	if origRecord.Payload == nil {
 		origRecord.Payload = &gravity_sdk_types_record.Value{
@@ -174,7 +344,7 @@ func (handler *SnapshotHandler) merge(origRecord *gravity_sdk_types_snapshot_rec
 	}
 
 	// Merge payload
-	handler.applyChanges(origRecord.Payload, updates.Payload)
+	handler.applyChanges(origRecord.Payload, updates.Payload, strategy != configs.MergeShallow)
 
 	data, _ := origRecord.ToBytes()
 
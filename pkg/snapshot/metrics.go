@@ -0,0 +1,129 @@
+package snapshot
+
+import (
+	"strconv"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	pendingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "pending_events"),
+		"Number of events which are queued for workers",
+		[]string{"collection"}, nil,
+	)
+
+	consumerPendingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "consumer_pending"),
+		"Number of messages which are not yet delivered to consumer",
+		[]string{"collection", "partition", "durable"}, nil,
+	)
+
+	consumerAckPendingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "consumer_ack_pending"),
+		"Number of messages which are delivered but not yet acknowledged",
+		[]string{"collection", "partition", "durable"}, nil,
+	)
+
+	appliedSequenceDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "applied_sequence"),
+		"The last stream sequence which was applied to snapshot",
+		[]string{"collection", "partition"}, nil,
+	)
+
+	lastEventTimestampDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "last_event_timestamp_seconds"),
+		"Timestamp of the last event which was applied to snapshot",
+		[]string{"collection", "partition"}, nil,
+	)
+
+	catchUpEstimateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "catchup_estimate_seconds"),
+		"Estimated time to apply all pending events, -1 if there is no progress",
+		[]string{"collection"}, nil,
+	)
+
+	caughtUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "caught_up"),
+		"Whether lag of collection was within snapshot.caughtUpLag at the last sampling",
+		[]string{"collection"}, nil,
+	)
+)
+
+// collector collects metrics from states of stores and consumers on scraping
+type collector struct {
+	snapshot *Snapshot
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingDesc
+	ch <- consumerPendingDesc
+	ch <- consumerAckPendingDesc
+	ch <- appliedSequenceDesc
+	ch <- lastEventTimestampDesc
+	ch <- catchUpEstimateDesc
+	ch <- caughtUpDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+
+	// Queue depth of workers and progress of applying
+	lags := make(map[string]uint64)
+	rates := make(map[string]float64)
+	c.snapshot.storeMutex.Lock()
+	for _, cs := range c.snapshot.stores {
+		pending := cs.GetPendingCount()
+		lags[cs.name] = uint64(pending)
+		rates[cs.name] = cs.progress.GetApplyRate()
+
+		ch <- prometheus.MustNewConstMetric(pendingDesc, prometheus.GaugeValue, float64(pending), cs.name)
+
+		caughtUp := 0.0
+		if cs.progress.IsCaughtUp() {
+			caughtUp = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(caughtUpDesc, prometheus.GaugeValue, caughtUp, cs.name)
+
+		for partition, state := range cs.progress.getAppliedStates() {
+			p := strconv.FormatUint(partition, 10)
+			ch <- prometheus.MustNewConstMetric(appliedSequenceDesc, prometheus.GaugeValue, float64(state.sequence), cs.name, p)
+			ch <- prometheus.MustNewConstMetric(lastEventTimestampDesc, prometheus.GaugeValue, float64(state.eventTime.UnixNano())/1e9, cs.name, p)
+		}
+	}
+	c.snapshot.storeMutex.Unlock()
+
+	// Lag of consumers which was sampled by progress monitor
+	for _, collection := range c.snapshot.watcher.GetCollections() {
+		infos, _ := collection.GetSampledConsumerInfos()
+		for partition, info := range infos {
+			p := strconv.FormatUint(partition, 10)
+			ch <- prometheus.MustNewConstMetric(consumerPendingDesc, prometheus.GaugeValue, float64(info.NumPending), collection.name, p, info.Name)
+			ch <- prometheus.MustNewConstMetric(consumerAckPendingDesc, prometheus.GaugeValue, float64(info.NumAckPending), collection.name, p, info.Name)
+			lags[collection.name] += info.NumPending + uint64(info.NumAckPending)
+		}
+	}
+
+	for collection, lag := range lags {
+		ch <- prometheus.MustNewConstMetric(catchUpEstimateDesc, prometheus.GaugeValue, estimateCatchUp(lag, rates[collection]), collection)
+	}
+}
+
+func (d *Snapshot) observeApply(collection string, operation string, err error) {
+
+	if err == nil {
+		d.metrics.Records.WithLabelValues(collection, operation).Inc()
+		return
+	}
+
+	if e, ok := err.(*SkipError); ok {
+		d.metrics.Records.WithLabelValues(collection, "skip").Inc()
+		if e.Err != nil {
+			d.metrics.HandlerErrors.WithLabelValues(collection, e.Reason).Inc()
+		}
+		return
+	}
+
+	d.metrics.HandlerErrors.WithLabelValues(collection, "store").Inc()
+}
@@ -0,0 +1,142 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"go.uber.org/zap"
+)
+
+// metaPartition is set to meta of record to keep partition of events which record was applied from
+const metaPartition = "partition"
+
+// acquirePartition makes consumer of partition continue from the last position of local store, because consumer may be
+// left by another node. Partition which has nothing applied starts from the first event of stream.
+func (d *Snapshot) acquirePartition(c *Collection, partition uint64) {
+
+	// Snapshot was seeded or restored, consumer is recreated from that position already
+	if c.isResetting(partition) {
+		return
+	}
+
+	cs, err := d.getStore(c.GetName())
+	if err != nil {
+		d.logger.Error(err.Error(), zap.String("collection", c.GetName()))
+		return
+	}
+
+	seq := cs.GetPositions()[partition]
+
+	d.logger.Info("Resuming partition",
+		zap.String("collection", c.GetName()),
+		zap.Uint64("partition", partition),
+		zap.Uint64("position", seq),
+	)
+
+	c.ResetPartition(partition, seq+1)
+}
+
+// PurgePartition deletes records which were applied from events of partition, it is called after partition was taken
+// over by another node. Records written before partition was kept in their meta data cannot be found, so they are kept.
+func (d *Snapshot) PurgePartition(ctx context.Context, collection string, partition uint64) (int, error) {
+
+	cs := d.lookupStore(collection)
+	if cs == nil {
+		return 0, nil
+	}
+
+	// Events which were dispatched before partition was released
+	err := cs.waitForPartition(ctx, partition)
+	if err != nil {
+		return 0, err
+	}
+
+	d.applyMutex.RLock()
+	defer d.applyMutex.RUnlock()
+
+	type recordKey struct {
+		table      string
+		primaryKey []byte
+	}
+
+	// Tombstones of partition are purged as well
+	keys := make([]recordKey, 0)
+	_, err = scanRecords(cs.store, nil, false, math.MaxInt32, true, func(table string, key []byte, data []byte) error {
+
+		record := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+		err := gravity_sdk_types_snapshot_record.Unmarshal(data, record)
+		if err != nil {
+			return nil
+		}
+
+		if p, ok := record.Meta.AsMap()[metaPartition].(float64); !ok || uint64(p) != partition {
+			return nil
+		}
+
+		primaryKey := make([]byte, len(key)-len(table)-1)
+		copy(primaryKey, key[len(table)+1:])
+		keys = append(keys, recordKey{table: table, primaryKey: primaryKey})
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	request := eventstore.NewSnapshotRequest()
+	request.Store = cs.store
+	for _, k := range keys {
+		err := writeRecord(request, StrToBytes(k.table), k.primaryKey, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Partition starts from the first event if it was acquired again
+	err = cs.clearPosition(partition)
+	if err != nil {
+		return len(keys), err
+	}
+
+	d.logger.Info("Purged partition",
+		zap.String("collection", collection),
+		zap.Uint64("partition", partition),
+		zap.Int("records", len(keys)),
+	)
+
+	return len(keys), nil
+}
+
+// waitForPartition blocks until all events of partition which were dispatched are applied
+func (cs *CollectionStore) waitForPartition(ctx context.Context, partition uint64) error {
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for cs.getPartitionPendingCount(partition) > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("Timeout waiting for pending events of partition %d of collection \"%s\": %v", partition, cs.name, ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+func (cs *CollectionStore) getPartitionPendingCount(partition uint64) int {
+
+	count := 0
+	cs.inflight.Range(func(key interface{}, value interface{}) bool {
+		if value.(*pendingEvent).partition == partition {
+			count++
+		}
+		return true
+	})
+
+	return count
+}
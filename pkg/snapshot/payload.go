@@ -0,0 +1,76 @@
+package snapshot
+
+import (
+	"encoding/binary"
+	"math"
+
+	gravity_sdk_types_record "github.com/BrobridgeOrg/gravity-sdk/types/record"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// GetValue converts value of record to native type, maps and arrays will be converted recursively
+func GetValue(value *gravity_sdk_types_record.Value) interface{} {
+
+	if value == nil {
+		return nil
+	}
+
+	switch value.Type {
+	case gravity_sdk_types_record.DataType_MAP:
+
+		if value.Map == nil {
+			return map[string]interface{}{}
+		}
+
+		m := make(map[string]interface{}, len(value.Map.Fields))
+		for _, field := range value.Map.Fields {
+			m[field.Name] = GetValue(field.Value)
+		}
+
+		return m
+
+	case gravity_sdk_types_record.DataType_ARRAY:
+
+		if value.Array == nil {
+			return []interface{}{}
+		}
+
+		arr := make([]interface{}, 0, len(value.Array.Elements))
+		for _, ele := range value.Array.Elements {
+			arr = append(arr, GetValue(ele))
+		}
+
+		return arr
+
+	case gravity_sdk_types_record.DataType_BOOLEAN:
+		return len(value.Value) > 0 && value.Value[0]&1 == 1
+	case gravity_sdk_types_record.DataType_FLOAT64:
+		if len(value.Value) < 8 {
+			return nil
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(value.Value))
+	case gravity_sdk_types_record.DataType_INT64:
+		if len(value.Value) < 8 {
+			return nil
+		}
+		return int64(binary.BigEndian.Uint64(value.Value))
+	case gravity_sdk_types_record.DataType_UINT64:
+		if len(value.Value) < 8 {
+			return nil
+		}
+		return binary.BigEndian.Uint64(value.Value)
+	case gravity_sdk_types_record.DataType_STRING:
+		return string(value.Value)
+	case gravity_sdk_types_record.DataType_NULL:
+		return nil
+	case gravity_sdk_types_record.DataType_TIME:
+		ts, err := ptypes.Timestamp(value.Timestamp)
+		if err != nil {
+			return nil
+		}
+		return ts
+	}
+
+	// binary
+	return value.Value
+}
@@ -0,0 +1,186 @@
+package snapshot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"go.uber.org/zap"
+)
+
+const DefaultRecoverInterval = 5
+
+// Pipeline holds events before applying to snapshot while connection is unavailable
+type Pipeline struct {
+	paused   bool
+	reason   string
+	pausedAt time.Time
+	resumed  chan struct{}
+	mutex    sync.RWMutex
+}
+
+func NewPipeline() *Pipeline {
+
+	resumed := make(chan struct{})
+	close(resumed)
+
+	return &Pipeline{
+		resumed: resumed,
+	}
+}
+
+func (p *Pipeline) Pause(reason string) {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.reason = reason
+	if p.paused {
+		return
+	}
+
+	p.paused = true
+	p.pausedAt = time.Now()
+	p.resumed = make(chan struct{})
+}
+
+// Resume resumes pipeline only if it was paused for the reason, so it will not be resumed by mistake if it was paused again
+func (p *Pipeline) Resume(reason string) bool {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.paused || p.reason != reason {
+		return false
+	}
+
+	p.paused = false
+	p.reason = ""
+	close(p.resumed)
+
+	return true
+}
+
+// IsPaused returns reason if pipeline was paused
+func (p *Pipeline) IsPaused() (bool, string) {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.paused, p.reason
+}
+
+// Wait blocks until pipeline was resumed, it returns false if closed before resuming
+func (p *Pipeline) Wait(closed <-chan struct{}) bool {
+
+	// Closing takes precedence, both channels are closed while shutting down
+	select {
+	case <-closed:
+		return false
+	default:
+	}
+
+	p.mutex.RLock()
+	resumed := p.resumed
+	p.mutex.RUnlock()
+
+	select {
+	case <-resumed:
+		return true
+	case <-closed:
+		return false
+	}
+}
+
+// IsPipelinePaused returns reason if events are not being applied to snapshot
+func (d *Snapshot) IsPipelinePaused() (bool, string) {
+	return d.pipeline.IsPaused()
+}
+
+// handleConnectionEvent pauses pipeline when connection is unavailable, then recovers collections after reconnected
+func (d *Snapshot) handleConnectionEvent(event string) {
+
+	switch event {
+	case connector.EventDisconnected:
+		d.logger.Warn("Pausing pipeline until reconnected")
+		d.pipeline.Pause("disconnected")
+	case connector.EventClosed:
+		d.pipeline.Pause("closed")
+	case connector.EventReconnected:
+		d.pipeline.Pause("recovering")
+
+		// Recovering takes a while, it should not block handlers of connection
+		go d.recover()
+	}
+}
+
+// recover verifies streams and consumers which might be lost during outage, then resumes pipeline
+func (d *Snapshot) recover() {
+
+	d.recoverMutex.Lock()
+	defer d.recoverMutex.Unlock()
+
+	attempts := d.config.Snapshot.RecoverAttempts
+
+	for attempt := 1; ; attempt++ {
+
+		// Disconnected again or closed
+		if paused, reason := d.pipeline.IsPaused(); !paused || reason != "recovering" {
+			return
+		}
+
+		err := d.recoverCollections()
+		if err == nil {
+			break
+		}
+
+		d.logger.Error("Failed to recover collections",
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+
+		// Collections which are still unavailable should not stop others forever
+		if attempt >= attempts {
+			d.logger.Warn("Resuming pipeline although some of collections were not recovered")
+			break
+		}
+
+		select {
+		case <-d.closed:
+			return
+		case <-time.After(DefaultRecoverInterval * time.Second):
+		}
+	}
+
+	if d.pipeline.Resume("recovering") {
+		d.logger.Info("Pipeline was resumed")
+	}
+}
+
+func (d *Snapshot) recoverCollections() error {
+
+	var lastErr error
+	for _, c := range d.watcher.GetCollections() {
+
+		positions := make(map[uint64]uint64)
+		cs, err := d.GetCollectionStore(c.GetName())
+		if err == nil {
+			positions = cs.GetPositions()
+		}
+
+		count, err := c.Recover(positions)
+		if err != nil {
+			d.logger.Warn(err.Error(), zap.String("collection", c.GetName()))
+			lastErr = err
+		}
+
+		if count > 0 {
+			d.logger.Info("Partitions were resubscribed",
+				zap.String("collection", c.GetName()),
+				zap.Int("count", count),
+			)
+		}
+	}
+
+	return lastErr
+}
@@ -0,0 +1,208 @@
+package snapshot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+const PositionStatePrefix = "_position."
+const RestoreStatePrefix = "_restore."
+const RestoreStateName = "_restore"
+const RestoredStatePrefix = "_restored."
+
+// Positions are flushed periodically, so events applied since the last flush are applied again after crash
+const DefaultPositionFlushInterval = 1 * time.Second
+
+// updatePosition records the last stream sequence of partition which was applied to snapshot
+func (cs *CollectionStore) updatePosition(partition uint64, seq uint64) {
+
+	cs.positionMutex.Lock()
+	defer cs.positionMutex.Unlock()
+
+	if seq > cs.positions[partition] {
+		cs.positions[partition] = seq
+		cs.positionDirty = true
+	}
+}
+
+// clearPosition removes position of partition which no data is kept for
+func (cs *CollectionStore) clearPosition(partition uint64) error {
+
+	cs.positionMutex.Lock()
+	delete(cs.positions, partition)
+	cs.positionMutex.Unlock()
+
+	// Zero means nothing was applied
+	return cs.store.UpdateDurableState(fmt.Sprintf("%s%d", PositionStatePrefix, partition), 0)
+}
+
+func (cs *CollectionStore) GetPositions() map[uint64]uint64 {
+
+	cs.positionMutex.Lock()
+	defer cs.positionMutex.Unlock()
+
+	positions := make(map[uint64]uint64, len(cs.positions))
+	for partition, seq := range cs.positions {
+		positions[partition] = seq
+	}
+
+	return positions
+}
+
+// SavePositions writes positions of all partitions to store
+func (cs *CollectionStore) SavePositions() error {
+
+	cs.positionMutex.Lock()
+	cs.positionDirty = false
+	cs.positionMutex.Unlock()
+
+	for partition, seq := range cs.GetPositions() {
+		err := cs.store.UpdateDurableState(fmt.Sprintf("%s%d", PositionStatePrefix, partition), seq)
+		if err != nil {
+
+			// Positions will be written again by next flush
+			cs.positionMutex.Lock()
+			cs.positionDirty = true
+			cs.positionMutex.Unlock()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flushPositions writes positions to store if any of them moved forward since the last writing
+func (cs *CollectionStore) flushPositions() error {
+
+	cs.positionMutex.Lock()
+	dirty := cs.positionDirty
+	cs.positionMutex.Unlock()
+
+	if !dirty {
+		return nil
+	}
+
+	return cs.SavePositions()
+}
+
+func (d *Snapshot) runPositionFlusher() {
+
+	ticker := time.NewTicker(DefaultPositionFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.flushPositions()
+		case <-d.closed:
+			return
+		}
+	}
+}
+
+// flushPositions keeps positions in store up to date, so consumers continue from them after crash
+func (d *Snapshot) flushPositions() {
+
+	// Stores are not closed while flushing
+	d.storeMutex.Lock()
+	defer d.storeMutex.Unlock()
+
+	for _, cs := range d.stores {
+		err := cs.flushPositions()
+		if err != nil {
+			d.logger.Warn(err.Error(), zap.String("collection", cs.name))
+		}
+	}
+}
+
+func LoadPositions(store *eventstore.Store) (map[uint64]uint64, error) {
+	return loadPartitionStates(store, PositionStatePrefix)
+}
+
+// SetRestorePositions makes consumers of all partitions to be recreated from specific positions on next start,
+// partitions which have no position are recreated from the first event of stream
+func SetRestorePositions(store *eventstore.Store, positions map[uint64]uint64) error {
+
+	// Positions which were kept in backup belong to another restoring
+	cf, err := store.GetColumnFamailyHandle("states")
+	if err != nil {
+		return err
+	}
+
+	err = cf.Db.DeleteRange([]byte(RestoreStatePrefix), []byte(RestoreStatePrefix[:len(RestoreStatePrefix)-1]+"/"), pebble.NoSync)
+	if err != nil {
+		return err
+	}
+
+	for partition, seq := range positions {
+
+		// Zero means nothing was applied
+		if seq == 0 {
+			continue
+		}
+
+		err := store.UpdateDurableState(fmt.Sprintf("%s%d", RestoreStatePrefix, partition), seq)
+		if err != nil {
+			return err
+		}
+	}
+
+	return store.UpdateDurableState(RestoreStateName, uint64(time.Now().UnixNano()))
+}
+
+func LoadRestorePositions(store *eventstore.Store) (map[uint64]uint64, error) {
+	return loadPartitionStates(store, RestoreStatePrefix)
+}
+
+// GetRestoreID returns ID of the last restoring, zero if datastore was never restored
+func GetRestoreID(store *eventstore.Store) (uint64, error) {
+	return store.GetDurableState(RestoreStateName)
+}
+
+// LoadRestoredPartitions returns ID of restoring which consumer of each partition was recreated for
+func LoadRestoredPartitions(store *eventstore.Store) (map[uint64]uint64, error) {
+	return loadPartitionStates(store, RestoredStatePrefix)
+}
+
+func setRestoredPartition(store *eventstore.Store, partition uint64, id uint64) error {
+	return store.UpdateDurableState(fmt.Sprintf("%s%d", RestoredStatePrefix, partition), id)
+}
+
+func loadPartitionStates(store *eventstore.Store, prefix string) (map[uint64]uint64, error) {
+
+	cf, err := store.GetColumnFamailyHandle("states")
+	if err != nil {
+		return nil, err
+	}
+
+	iter := cf.Db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte(prefix),
+		UpperBound: []byte(prefix[:len(prefix)-1] + "/"),
+	})
+	defer iter.Close()
+
+	states := make(map[uint64]uint64)
+	for iter.First(); iter.Valid(); iter.Next() {
+
+		partition, err := strconv.ParseUint(strings.TrimPrefix(string(iter.Key()), prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		seq := eventstore.BytesToUint64(iter.Value())
+		if seq == 0 {
+			continue
+		}
+
+		states[partition] = seq
+	}
+
+	return states, nil
+}
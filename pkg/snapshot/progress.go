@@ -0,0 +1,481 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const rateSmoothingFactor = 0.3
+
+type PartitionStatus struct {
+	Partition          uint64    `json:"partition"`
+	AppliedSequence    uint64    `json:"appliedSequence"`
+	DispatchedSequence uint64    `json:"dispatchedSequence"`
+	LastEventTime      time.Time `json:"lastEventTime"`
+	LastAppliedAt      time.Time `json:"lastAppliedAt"`
+	Pending            uint64    `json:"pending"`
+	AckPending         int       `json:"ackPending"`
+}
+
+type CollectionStatus struct {
+	Collection         string             `json:"collection"`
+	StreamLastSequence uint64             `json:"streamLastSequence"`
+	Partitions         []*PartitionStatus `json:"partitions"`
+	Lag                uint64             `json:"lag"`
+	WorkerPending      int64              `json:"workerPending"`
+	ApplyRate          float64            `json:"applyRate"`
+	EstimatedCatchUp   float64            `json:"estimatedCatchUpSeconds"`
+	CaughtUp           bool               `json:"caughtUp"`
+	CaughtUpAt         *time.Time         `json:"caughtUpAt,omitempty"`
+}
+
+type appliedState struct {
+	sequence  uint64
+	eventTime time.Time
+	appliedAt time.Time
+}
+
+// Progress tracks events which were applied to snapshot of collection
+type Progress struct {
+	applied     map[uint64]*appliedState
+	count       uint64
+	lastCount   uint64
+	lastSampled time.Time
+	rate        float64
+	caughtUp    chan struct{}
+	caughtUpAt  time.Time
+	mutex       sync.RWMutex
+}
+
+func NewProgress() *Progress {
+	return &Progress{
+		applied:     make(map[uint64]*appliedState),
+		lastSampled: time.Now(),
+		caughtUp:    make(chan struct{}),
+	}
+}
+
+func (p *Progress) markApplied(partition uint64, seq uint64, eventTime time.Time) {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.count++
+
+	state, ok := p.applied[partition]
+	if !ok {
+		state = &appliedState{}
+		p.applied[partition] = state
+	}
+
+	if seq > state.sequence {
+		state.sequence = seq
+		state.eventTime = eventTime
+	}
+
+	state.appliedAt = time.Now()
+}
+
+// sample updates apply rate with exponential moving average
+func (p *Progress) sample() {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(p.lastSampled).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	rate := float64(p.count-p.lastCount) / elapsed
+	if p.lastCount == 0 && p.rate == 0 {
+		p.rate = rate
+	} else {
+		p.rate = rateSmoothingFactor*rate + (1-rateSmoothingFactor)*p.rate
+	}
+
+	p.lastCount = p.count
+	p.lastSampled = now
+}
+
+// GetApplyRate returns number of events applied per second
+func (p *Progress) GetApplyRate() float64 {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.rate
+}
+
+func (p *Progress) getApplied(partition uint64) appliedState {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if state, ok := p.applied[partition]; ok {
+		return *state
+	}
+
+	return appliedState{}
+}
+
+func (p *Progress) getAppliedStates() map[uint64]appliedState {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	states := make(map[uint64]appliedState, len(p.applied))
+	for partition, state := range p.applied {
+		states[partition] = *state
+	}
+
+	return states
+}
+
+// updateCaughtUp changes whether collection is caught up with stream, it returns true if state was changed
+func (p *Progress) updateCaughtUp(caughtUp bool) bool {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if caughtUp == !p.caughtUpAt.IsZero() {
+		return false
+	}
+
+	if caughtUp {
+		p.caughtUpAt = time.Now()
+		close(p.caughtUp)
+		return true
+	}
+
+	// Fell behind stream, waiting for catching up again
+	p.caughtUpAt = time.Time{}
+	p.caughtUp = make(chan struct{})
+
+	return true
+}
+
+// IsCaughtUp returns true if lag of collection was within threshold at the last sampling
+func (p *Progress) IsCaughtUp() bool {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return !p.caughtUpAt.IsZero()
+}
+
+// CaughtUp returns channel which will be closed once collection is caught up with stream, channel is closed already
+// if collection is caught up currently
+func (p *Progress) CaughtUp() <-chan struct{} {
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.caughtUp
+}
+
+// AppliedEvent describes event of collection which was taken by snapshot
+type AppliedEvent struct {
+	Collection string
+	Partition  uint64
+	Sequence   uint64
+	Operation  string
+	EventTime  time.Time
+	AppliedAt  time.Time
+	Err        error
+}
+
+// WatchApplied registers handler to be called after every event of collections was applied or skipped,
+// handler is called by workers so it should return quickly
+func (d *Snapshot) WatchApplied(fn func(*AppliedEvent)) {
+	d.handlerMutex.Lock()
+	d.appliedHandlers = append(d.appliedHandlers, fn)
+	d.handlerMutex.Unlock()
+}
+
+func (d *Snapshot) emitApplied(collection string, event *pendingEvent, seq uint64, operation string, err error) {
+
+	d.handlerMutex.RLock()
+	handlers := d.appliedHandlers
+	d.handlerMutex.RUnlock()
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	e := &AppliedEvent{
+		Collection: collection,
+		Partition:  event.partition,
+		Sequence:   seq,
+		Operation:  operation,
+		EventTime:  event.timestamp,
+		AppliedAt:  time.Now(),
+		Err:        err,
+	}
+
+	for _, fn := range handlers {
+		fn(e)
+	}
+}
+
+// estimateCatchUp returns seconds to apply events which are still pending
+func estimateCatchUp(lag uint64, rate float64) float64 {
+
+	if lag == 0 {
+		return 0
+	}
+
+	if rate <= 0 {
+		return -1
+	}
+
+	return float64(lag) / rate
+}
+
+// GetCollectionStatus returns progress of applying events of collection
+func (d *Snapshot) GetCollectionStatus(collection string) (*CollectionStatus, error) {
+
+	c := d.watcher.GetCollection(collection)
+	if c == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFoundCollection, collection)
+	}
+
+	cs := d.lookupStore(collection)
+	if cs == nil {
+		return nil, fmt.Errorf("Store of collection %s is not opened", collection)
+	}
+
+	status := &CollectionStatus{
+		Collection:    collection,
+		Partitions:    make([]*PartitionStatus, 0),
+		WorkerPending: cs.GetPendingCount(),
+		ApplyRate:     cs.progress.GetApplyRate(),
+		CaughtUp:      cs.progress.IsCaughtUp(),
+	}
+
+	if status.CaughtUp {
+		cs.progress.mutex.RLock()
+		caughtUpAt := cs.progress.caughtUpAt
+		cs.progress.mutex.RUnlock()
+		status.CaughtUpAt = &caughtUpAt
+	}
+
+	// Getting the last sequence of stream
+	js, err := d.connector.GetJetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := js.StreamInfo(c.getStreamName())
+	if err != nil {
+		return nil, err
+	}
+
+	status.StreamLastSequence = stream.State.LastSeq
+
+	infos, err := c.GetConsumerInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	positions := cs.GetPositions()
+	for partition, info := range infos {
+
+		applied := cs.progress.getApplied(partition)
+
+		ps := &PartitionStatus{
+			Partition:          partition,
+			AppliedSequence:    applied.sequence,
+			DispatchedSequence: positions[partition],
+			LastEventTime:      applied.eventTime,
+			LastAppliedAt:      applied.appliedAt,
+			Pending:            info.NumPending,
+			AckPending:         info.NumAckPending,
+		}
+
+		status.Lag += ps.Pending + uint64(ps.AckPending)
+		status.Partitions = append(status.Partitions, ps)
+	}
+
+	sort.Slice(status.Partitions, func(i, j int) bool {
+		return status.Partitions[i].Partition < status.Partitions[j].Partition
+	})
+
+	status.Lag += uint64(status.WorkerPending)
+	status.EstimatedCatchUp = estimateCatchUp(status.Lag, status.ApplyRate)
+
+	return status, nil
+}
+
+// MergeCollectionStatus combines status of collection which was reported by instances owning different partitions
+func MergeCollectionStatus(statuses ...*CollectionStatus) *CollectionStatus {
+
+	merged := &CollectionStatus{
+		Partitions: make([]*PartitionStatus, 0),
+		CaughtUp:   len(statuses) > 0,
+	}
+
+	for _, status := range statuses {
+
+		merged.Collection = status.Collection
+		merged.Partitions = append(merged.Partitions, status.Partitions...)
+		merged.Lag += status.Lag
+		merged.WorkerPending += status.WorkerPending
+		merged.ApplyRate += status.ApplyRate
+
+		if status.StreamLastSequence > merged.StreamLastSequence {
+			merged.StreamLastSequence = status.StreamLastSequence
+		}
+
+		if !status.CaughtUp {
+			merged.CaughtUp = false
+			continue
+		}
+
+		if merged.CaughtUpAt == nil || (status.CaughtUpAt != nil && status.CaughtUpAt.After(*merged.CaughtUpAt)) {
+			merged.CaughtUpAt = status.CaughtUpAt
+		}
+	}
+
+	if !merged.CaughtUp {
+		merged.CaughtUpAt = nil
+	}
+
+	sort.Slice(merged.Partitions, func(i, j int) bool {
+		return merged.Partitions[i].Partition < merged.Partitions[j].Partition
+	})
+
+	merged.EstimatedCatchUp = estimateCatchUp(merged.Lag, merged.ApplyRate)
+
+	return merged
+}
+
+// WaitForCaughtUp blocks until collection is caught up with stream, store of collection might not be opened yet
+func (d *Snapshot) WaitForCaughtUp(ctx context.Context, collection string) error {
+
+	if d.watcher.GetCollection(collection) == nil {
+		return fmt.Errorf("%w: %s", ErrNotFoundCollection, collection)
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+
+		var caughtUp <-chan struct{}
+		if cs := d.lookupStore(collection); cs != nil {
+			caughtUp = cs.progress.CaughtUp()
+		}
+
+		select {
+		case <-caughtUp:
+			return nil
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("Collection %s has not caught up with stream: %v", collection, ctx.Err())
+		}
+	}
+}
+
+// IsCaughtUp returns true if all collections are caught up with streams
+func (d *Snapshot) IsCaughtUp() bool {
+
+	for _, c := range d.watcher.GetCollections() {
+
+		cs := d.lookupStore(c.GetName())
+		if cs == nil || !cs.progress.IsCaughtUp() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *Snapshot) runProgressMonitor() {
+
+	interval := time.Duration(d.config.Snapshot.ProgressInterval) * time.Second
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	d.updateProgress()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.updateProgress()
+		case <-d.closed:
+			return
+		}
+	}
+}
+
+func (d *Snapshot) updateProgress() {
+
+	threshold := uint64(d.config.Snapshot.CaughtUpLag)
+
+	for _, c := range d.watcher.GetCollections() {
+
+		cs := d.lookupStore(c.GetName())
+		if cs == nil {
+			continue
+		}
+
+		cs.progress.sample()
+
+		// State is kept if consumers are unavailable
+		infos, err := c.sampleConsumers()
+		if err != nil {
+			continue
+		}
+
+		lag := getLag(infos, cs)
+		caughtUp := lag <= threshold
+		if !cs.progress.updateCaughtUp(caughtUp) {
+			continue
+		}
+
+		if !caughtUp {
+			d.logger.Info("Collection fell behind stream", zap.String("collection", c.GetName()), zap.Uint64("lag", lag))
+			continue
+		}
+
+		d.logger.Info("Collection caught up with stream", zap.String("collection", c.GetName()), zap.Uint64("lag", lag))
+
+		d.publishCaughtUp(c.GetName(), cs.GetPositions())
+	}
+}
+
+// getLag returns number of events which are not delivered, not acknowledged and queued for workers
+func getLag(infos map[uint64]*nats.ConsumerInfo, cs *CollectionStore) uint64 {
+
+	lag := uint64(cs.GetPendingCount())
+	for _, info := range infos {
+		lag += info.NumPending + uint64(info.NumAckPending)
+	}
+
+	return lag
+}
+
+// publishCaughtUp notifies subscribers that snapshot of collection is up to date
+func (d *Snapshot) publishCaughtUp(collection string, positions map[uint64]uint64) {
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"collection": collection,
+		"positions":  positions,
+	})
+
+	subject := fmt.Sprintf("$GRAVITY.%s.SNAPSHOT.EVENT.CAUGHTUP", d.connector.GetDomain())
+	err := d.connector.GetConnection().Publish(subject, data)
+	if err != nil {
+		d.logger.Warn(err.Error(), zap.String("collection", collection))
+	}
+}
@@ -0,0 +1,131 @@
+package snapshot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/reloader"
+	"go.uber.org/zap"
+)
+
+// checkConfig rejects changes of collection blocks which were taken already, because streams, consumers
+// and stores were prepared with them
+func (d *Snapshot) checkConfig(config *configs.Config, changes []string) error {
+
+	restarts := make([]string, 0)
+	for _, setting := range changes {
+
+		parts := strings.SplitN(setting, ".", 3)
+		if parts[0] != "collection" || len(parts) < 2 {
+			continue
+		}
+
+		if d.isConfigured(parts[1]) {
+			restarts = append(restarts, setting)
+		}
+	}
+
+	if len(restarts) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Settings of collections which were watched require restart: %s", strings.Join(restarts, ", "))
+}
+
+// isConfigured returns true if settings of collection were taken by watcher or store
+func (d *Snapshot) isConfigured(name string) bool {
+
+	for _, c := range d.watcher.GetCollections() {
+		if strings.EqualFold(c.GetName(), name) {
+			return true
+		}
+	}
+
+	d.storeMutex.Lock()
+	defer d.storeMutex.Unlock()
+
+	for collection := range d.stores {
+		if strings.EqualFold(collection, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *Snapshot) applyConfig(config *configs.Config, changes []string) {
+
+	if !reloader.HasChanged(changes, "collections") {
+		return
+	}
+
+	d.reloadCollections(config.GetCollections())
+}
+
+// reloadCollections starts watching collections which were added, and stops watching collections which were removed.
+// Consumers and data of removed collections are kept, so they can be resumed if collections were added again.
+func (d *Snapshot) reloadCollections(collections []string) {
+
+	names := make(map[string]bool)
+	patterns := make(map[string]bool)
+	for _, e := range collections {
+		if IsCollectionPattern(e) {
+			patterns[e] = true
+		} else {
+			names[e] = true
+		}
+	}
+
+	// Patterns
+	for _, expr := range d.watcher.GetPatterns() {
+
+		if patterns[expr] {
+			delete(patterns, expr)
+			continue
+		}
+
+		d.watcher.UnregisterPattern(expr)
+		d.logger.Info(fmt.Sprintf("Unregistered collection pattern: %s", expr))
+	}
+
+	// Collections which are no longer specified or matched
+	for _, c := range d.watcher.GetCollections() {
+
+		name := c.GetName()
+		if names[name] || d.watcher.matchPatterns(name) {
+			continue
+		}
+
+		err := d.UnregisterCollection(name)
+		if err != nil {
+			d.logger.Error(err.Error(), zap.String("collection", name))
+		}
+	}
+
+	// New collections
+	for name := range names {
+
+		if d.watcher.GetCollection(name) != nil {
+			continue
+		}
+
+		d.logger.Info(fmt.Sprintf("Registered collection: %s", name))
+
+		err := d.watcher.AddCollection(name)
+		if err != nil {
+			d.logger.Error(err.Error(), zap.String("collection", name))
+		}
+	}
+
+	for expr := range patterns {
+
+		err := d.watcher.RegisterPattern(expr)
+		if err != nil {
+			d.logger.Error(err.Error(), zap.String("pattern", expr))
+			continue
+		}
+
+		d.logger.Info(fmt.Sprintf("Registered collection pattern: %s", expr))
+	}
+}
@@ -0,0 +1,223 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+)
+
+const DefaultScanBatchSize = 1000
+
+// OpenDatastore opens snapshot datastore without connecting to Gravity
+func OpenDatastore(datastorePath string) (*eventstore.EventStore, error) {
+
+	options := eventstore.NewOptions()
+	options.DatabasePath = datastorePath
+	options.EnabledSnapshot = true
+
+	return eventstore.CreateEventStore(options)
+}
+
+// ScanSnapshot iterates all records of specific table in store with a consistent view
+func ScanSnapshot(store *eventstore.Store, table string, fn func([]byte, *gravity_sdk_types_snapshot_record.SnapshotRecord) error) error {
+
+	scanner, err := NewScanner(store, table)
+	if err != nil {
+		return err
+	}
+	defer scanner.Release()
+
+	return scanner.Scan(fn)
+}
+
+// Scanner iterates records of specific table, all scans see the same view of snapshot until it was released
+type Scanner struct {
+	view   *eventstore.SnapshotView
+	table  string
+	filter func([]byte) bool
+}
+
+func NewScanner(store *eventstore.Store, table string) (*Scanner, error) {
+
+	tables, err := getTables(store)
+	if err != nil {
+		return nil, err
+	}
+
+	view := store.CreateSnapshotView()
+	err = view.Initialize()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scanner{
+		view:   view,
+		table:  table,
+		filter: NewTableKeyFilter(table, tables),
+	}, nil
+}
+
+// Release releases view of snapshot
+func (scanner *Scanner) Release() {
+	scanner.view.Release()
+}
+
+// Scan iterates all records of table, tombstones are skipped
+func (scanner *Scanner) Scan(fn func([]byte, *gravity_sdk_types_snapshot_record.SnapshotRecord) error) error {
+
+	var lastKey []byte
+	offset := uint64(0)
+	for {
+
+		// Skipped records are counted by Fetch as well
+		records, err := scanner.view.Fetch(StrToBytes(scanner.table), lastKey, offset, DefaultScanBatchSize+int(offset))
+		if err != nil {
+			return err
+		}
+
+		for _, r := range records {
+
+			lastKey = r.Key
+
+			// Record of another table which has the same prefix
+			if !scanner.filter(r.Key) {
+				r.Release()
+				continue
+			}
+
+			sr := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+			err := gravity_sdk_types_snapshot_record.Unmarshal(r.Data, sr)
+			r.Release()
+			if err != nil {
+				return err
+			}
+
+			if IsTombstone(sr) {
+				continue
+			}
+
+			err = fn(lastKey, sr)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(records) < DefaultScanBatchSize {
+			return nil
+		}
+
+		// Skip the last key which was handled already
+		offset = 1
+	}
+}
+
+// ScanRecords iterates records of all tables in order of keys, starting from specific key of snapshot. It stops after
+// count records and returns number of records, key and data are only valid until fn returned. Tombstones are skipped.
+func ScanRecords(store *eventstore.Store, startKey []byte, afterStartKey bool, count int, fn func(table string, key []byte, data []byte) error) (int, error) {
+	return scanRecords(store, startKey, afterStartKey, count, false, fn)
+}
+
+func scanRecords(store *eventstore.Store, startKey []byte, afterStartKey bool, count int, withTombstones bool, fn func(table string, key []byte, data []byte) error) (int, error) {
+
+	tables, err := getTables(store)
+	if err != nil {
+		return 0, err
+	}
+
+	cf, err := store.GetColumnFamailyHandle("snapshot")
+	if err != nil {
+		return 0, err
+	}
+
+	iter := cf.Db.NewIter(nil)
+	defer iter.Close()
+
+	n := 0
+	for valid := iter.SeekGE(startKey); valid && n < count; valid = iter.Next() {
+
+		key := iter.Key()
+		if afterStartKey && bytes.Equal(key, startKey) {
+			continue
+		}
+
+		// Digest is kept with records
+		table := resolveTable(tables, key)
+		if len(table) == 0 || isDigestKey(key) {
+			continue
+		}
+
+		if !withTombstones && IsTombstoneData(iter.Value()) {
+			continue
+		}
+
+		err := fn(table, key, iter.Value())
+		if err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, iter.Error()
+}
+
+// resolveTable returns table which key belongs to, the longest name is taken because tables can be nested
+func resolveTable(tables []string, key []byte) string {
+
+	table := ""
+	for _, t := range tables {
+		if len(t) > len(table) && bytes.HasPrefix(key, []byte(t+"-")) {
+			table = t
+		}
+	}
+
+	return table
+}
+
+// NewTableKeyFilter returns function which reports whether key in range of "<table>-" belongs to table, because
+// keys of snapshot are "<table>-<primary key>", records of table "user-profile" are in range of table "user" as well
+func NewTableKeyFilter(table string, tables []string) func([]byte) bool {
+
+	prefix := table + "-"
+	nested := make([][]byte, 0)
+	for _, t := range tables {
+		if strings.HasPrefix(t, prefix) {
+			nested = append(nested, []byte(t[len(prefix):]+"-"))
+		}
+	}
+
+	return func(key []byte) bool {
+		for _, n := range nested {
+			if bytes.HasPrefix(key, n) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// FormatKey returns primary key as string if it is printable, otherwise as hex string
+func FormatKey(key []byte) string {
+
+	if utf8.Valid(key) {
+		printable := true
+		for _, r := range string(key) {
+			if !unicode.IsPrint(r) {
+				printable = false
+				break
+			}
+		}
+
+		if printable {
+			return string(key)
+		}
+	}
+
+	return "0x" + hex.EncodeToString(key)
+}
@@ -0,0 +1,28 @@
+package snapshot
+
+import (
+	"fmt"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+)
+
+const SeedStateName = "_seed"
+const SeededStatePrefix = "_seeded."
+
+// SetSeedRevision records the stream sequence which seeded snapshot is matching
+func SetSeedRevision(store *eventstore.Store, revision uint64) error {
+	return store.UpdateDurableState(SeedStateName, revision)
+}
+
+func GetSeedRevision(store *eventstore.Store) (uint64, error) {
+	return store.GetDurableState(SeedStateName)
+}
+
+// LoadSeededPartitions returns seed revisions which consumers of partitions were reset to
+func LoadSeededPartitions(store *eventstore.Store) (map[uint64]uint64, error) {
+	return loadPartitionStates(store, SeededStatePrefix)
+}
+
+func setSeededPartition(store *eventstore.Store, partition uint64, revision uint64) error {
+	return store.UpdateDurableState(fmt.Sprintf("%s%d", SeededStatePrefix, partition), revision)
+}
@@ -2,49 +2,113 @@ package snapshot
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	eventstore "github.com/BrobridgeOrg/EventStore"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/metrics"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/reloader"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/tracing"
 	"github.com/nats-io/nats.go"
-	"github.com/spf13/viper"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
-var logger *zap.Logger
+var (
+	ErrNotFoundCollection = errors.New("Not found collection")
+	ErrStoreClosed        = errors.New("Store was closed")
+)
 
 type Snapshot struct {
 	config     *configs.Config
+	logger     *zap.Logger
 	connector  *connector.Connector
 	watcher    *CollectionWatcher
 	eventstore *eventstore.EventStore
-	store      *eventstore.Store
+	workers    *workerPool
+	stores     map[string]*CollectionStore
+	storeIndex sync.Map
+	storeMutex sync.Mutex
+	applyMutex sync.RWMutex
 	handler    *SnapshotHandler
+	pipeline   *Pipeline
+	metrics    *metrics.Registry
+	closed     chan struct{}
+
+	recoverMutex sync.Mutex
+	mergeDebug   atomic.Value
+
+	appliedHandlers []func(*AppliedEvent)
+	handlerMutex    sync.RWMutex
+}
+
+type UnregisterOptions struct {
+	DeleteDurable bool
+	DeleteData    bool
 }
 
-func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *connector.Connector) *Snapshot {
+func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *connector.Connector, r *reloader.Reloader, m *metrics.Registry) *Snapshot {
 
-	logger = l.Named("Snapshot")
+	logger := l.Named("Snapshot")
 
 	d := &Snapshot{
 		config:    config,
+		logger:    logger,
 		connector: c,
-		handler:   NewSnapshotHandler(),
+		stores:    make(map[string]*CollectionStore),
+		handler:   NewSnapshotHandler(WithHandlerLogger(logger.Named("Handler"))),
+		pipeline:  NewPipeline(),
+		metrics:   m,
+		closed:    make(chan struct{}),
 	}
 
+	d.connector.Watch(d.handleConnectionEvent)
+
 	// Initializing event watcher
-	d.watcher = NewCollectionWatcher(d.connector.GetClient(), d.connector.GetDomain())
+	d.watcher = NewCollectionWatcher(d.connector, d.connector.GetDomain(), logger)
 	d.registerCollections()
 
+	// Collections can be added or removed without restart
+	r.Register(d.checkConfig, d.applyConfig)
+
+	err := m.Register(&collector{snapshot: d})
+	if err != nil {
+		d.logger.Warn(err.Error())
+	}
+
+	err = m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "pipeline_paused",
+		Help:      "Whether applying events is paused because connection is unavailable",
+	}, func() float64 {
+		if paused, _ := d.pipeline.IsPaused(); paused {
+			return 1
+		}
+		return 0
+	}))
+	if err != nil {
+		d.logger.Warn(err.Error())
+	}
+
 	lifecycle.Append(
 		fx.Hook{
 			OnStart: func(context.Context) error {
 				return d.Run()
 			},
 			OnStop: func(ctx context.Context) error {
-				return nil
+				return d.Stop(ctx)
 			},
 		},
 	)
@@ -55,19 +119,18 @@ func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *conne
 func (d *Snapshot) initializeStore() error {
 
 	options := eventstore.NewOptions()
-	options.DatabasePath = viper.GetString("datastore.path")
+	options.DatabasePath = d.config.Datastore.Path
 	options.EnabledSnapshot = true
 
-	// Snapshot options
-	viper.SetDefault("snapshot.workerCount", 8)
-	viper.SetDefault("snapshot.workerBufferSize", 102400)
-	options.SnapshotOptions.WorkerCount = viper.GetInt32("snapshot.workerCount")
-	options.SnapshotOptions.BufferSize = viper.GetInt("snapshot.workerBufferSize")
+	// Events are applied by workers of snapshot instead of event store, because requests of event store are reused
+	// before they were done and its workers cannot be stopped
+	options.SnapshotOptions.WorkerCount = 1
+	options.SnapshotOptions.BufferSize = 1
 
-	logger.Info("Initialize store",
+	d.logger.Info("Initialize store",
 		zap.String("databasePath", options.DatabasePath),
-		zap.Int32("storeWorkerCount", options.SnapshotOptions.WorkerCount),
-		zap.Int("storeBufferSize", options.SnapshotOptions.BufferSize),
+		zap.Int("workerCount", d.config.Snapshot.WorkerCount),
+		zap.Int("workerBufferSize", d.config.Snapshot.WorkerBufferSize),
 	)
 
 	// Initialize event store
@@ -76,32 +139,281 @@ func (d *Snapshot) initializeStore() error {
 		return err
 	}
 
+	d.storeMutex.Lock()
 	d.eventstore = es
+	d.workers = newWorkerPool(d.config.Snapshot.WorkerCount, d.config.Snapshot.WorkerBufferSize, d.handleWorkerRequest)
+	d.storeMutex.Unlock()
+
+	return nil
+}
+
+// apply writes event to snapshot, it is called by shared workers or workers of collection
+func (d *Snapshot) apply(request *eventstore.SnapshotRequest) error {
+
+	ctx := context.Background()
+	collection := ""
+	var cs *CollectionStore
+	var event *pendingEvent
+	var options *ApplyOptions
+	if v, ok := d.storeIndex.Load(request.Store); ok {
+		cs = v.(*CollectionStore)
+		collection = cs.name
+		options = cs.options
+		event = cs.takePendingEvent(request.Sequence)
+		defer cs.decreasePending()
+	}
+
+	if event != nil {
+		ctx = trace.ContextWithSpanContext(ctx, event.spanContext)
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "snapshot.apply",
+		trace.WithAttributes(
+			attribute.String("collection", collection),
+			attribute.Int64("sequence", int64(request.Sequence)),
+		),
+	)
+	defer span.End()
+
+	meta := map[string]interface{}{
+		"revision": request.Sequence,
+	}
+
+	// Records are purged by partition when partition was taken over by another node
+	if event != nil {
+		meta[metaPartition] = event.partition
+	}
+
+	ctx = d.withMergeDebug(ctx, collection)
+
+	start := time.Now()
+	operation, err := d.handler.handle(ctx, meta, request, options)
+	d.metrics.ApplyDuration.WithLabelValues(collection).Observe(time.Since(start).Seconds())
+	d.observeApply(collection, operation, err)
+
+	if event != nil {
+		cs.progress.markApplied(event.partition, request.Sequence, event.timestamp)
+		d.emitApplied(collection, event, request.Sequence, operation, err)
+	}
+
+	// Record was ignored
+	if e, ok := err.(*SkipError); ok {
+		span.SetAttributes(attribute.String("skipped", e.Reason))
+		err = nil
+	}
+
+	// Position moves forward only after event was written to snapshot
+	if event != nil && err == nil {
+		cs.updatePosition(event.partition, request.Sequence)
+	}
 
-	// Setup snapshot
-	es.SetSnapshotHandler(func(request *eventstore.SnapshotRequest) error {
-		meta := map[string]interface{}{
-			"revision": request.Sequence,
+	// Event is acknowledged only after it was written to snapshot
+	if event != nil {
+		ackErr := event.complete(err)
+		if ackErr != nil {
+			d.logger.Warn(ackErr.Error())
 		}
-		return d.handler.handle(meta, request)
-	})
+	}
 
-	return nil
+	if err == nil {
+		return nil
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	return err
+}
+
+func (d *Snapshot) getStore(collection string) (*CollectionStore, error) {
+
+	d.storeMutex.Lock()
+	defer d.storeMutex.Unlock()
+
+	if cs, ok := d.stores[collection]; ok {
+		return cs, nil
+	}
+
+	// Store was closed by shutdown, events which are still being delivered should not reopen it
+	if d.eventstore == nil {
+		return nil, ErrStoreClosed
+	}
+
+	store, err := d.eventstore.GetStore(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	// Snapshot was created without digest
+	rebuilt, err := AssertDigest(store)
+	if err != nil {
+		return nil, err
+	}
+
+	if rebuilt {
+		d.logger.Info("Rebuilt digest of snapshot", zap.String("collection", collection))
+	}
+
+	cs := NewCollectionStore(collection, store)
+
+	// Settings of collection
+	config := d.config.GetCollectionConfig(collection)
+	cs.options = NewApplyOptions(config)
+	if config.Workers > 0 {
+		d.logger.Info("Applying events of collection by dedicated workers",
+			zap.String("collection", collection),
+			zap.Int("workers", config.Workers),
+		)
+		cs.workers = newWorkerPool(config.Workers, d.config.Snapshot.WorkerBufferSize, d.handleWorkerRequest)
+	}
+
+	// Restore positions of partitions from the last run
+	positions, err := LoadPositions(store)
+	if err != nil {
+		return nil, err
+	}
+
+	for partition, seq := range positions {
+		cs.updatePosition(partition, seq)
+	}
+
+	d.stores[collection] = cs
+	d.storeIndex.Store(store, cs)
+
+	return cs, nil
+}
+
+// lookupStore returns store of collection which was opened already, store will not be opened
+func (d *Snapshot) lookupStore(collection string) *CollectionStore {
+
+	d.storeMutex.Lock()
+	defer d.storeMutex.Unlock()
+
+	return d.stores[collection]
+}
+
+// GetCollectionStore returns store of specific collection which is being watched
+func (d *Snapshot) GetCollectionStore(collection string) (*CollectionStore, error) {
+
+	if d.watcher.GetCollection(collection) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFoundCollection, collection)
+	}
+
+	return d.getStore(collection)
+}
+
+func (d *Snapshot) deleteStore(collection string) error {
+
+	d.storeMutex.Lock()
+	cs, ok := d.stores[collection]
+	d.storeMutex.Unlock()
+
+	// Events which were dispatched already should be applied before closing store
+	if ok {
+		cs.closeWorkers()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.config.Snapshot.ShutdownTimeout)*time.Second)
+		err := cs.waitForPendings(ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	d.storeMutex.Lock()
+	defer d.storeMutex.Unlock()
+
+	if cs, ok := d.stores[collection]; ok {
+		cs.store.Close()
+		d.storeIndex.Delete(cs.store)
+		delete(d.stores, collection)
+	}
+
+	storePath := filepath.Join(d.config.Datastore.Path, collection)
+
+	d.logger.Info("Deleting snapshot data...",
+		zap.String("collection", collection),
+		zap.String("path", storePath),
+	)
+
+	return os.RemoveAll(storePath)
 }
 
 func (d *Snapshot) registerCollections() error {
 
+	// Settings of collection blocks are applied when each collection is registered
+	d.watcher.SetCollectionConfigurator(d.configureCollection)
+	d.watcher.SetDiscoveryInterval(time.Duration(d.config.Snapshot.DiscoveryInterval) * time.Second)
+
+	// Partitions of collection streams
+	partitions := make([]uint64, d.config.Snapshot.PartitionCount)
+	for i := range partitions {
+		partitions[i] = uint64(i)
+	}
+
+	d.watcher.SetPartitions(partitions)
+
+	// Permissions are validated after connected
+	domain := d.connector.GetDomain()
+	d.connector.RequirePublish(fmt.Sprintf("$GRAVITY.%s.SNAPSHOT.EVENT.CAUGHTUP", domain))
+
 	// Default events
-	for _, e := range d.config.Collections {
-		logger.Info(fmt.Sprintf("Regiserted collection: %s", e))
+	for _, e := range d.config.GetCollections() {
+
+		// Collections will be discovered from streams by pattern
+		if IsCollectionPattern(e) {
+			d.connector.RequirePublish("$JS.API.STREAM.NAMES")
+			err := d.watcher.RegisterPattern(e)
+			if err != nil {
+				d.logger.Error(err.Error(), zap.String("pattern", e))
+				continue
+			}
+
+			d.logger.Info(fmt.Sprintf("Regiserted collection pattern: %s", e))
+			continue
+		}
+
+		d.logger.Info(fmt.Sprintf("Regiserted collection: %s", e))
 		d.watcher.RegisterCollection(e)
+		d.connector.RequireStream(GetStreamName(domain, e))
 	}
 
 	return nil
 }
 
+func (d *Snapshot) UnregisterCollection(name string, opts ...func(*UnregisterOptions)) error {
+
+	options := &UnregisterOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	d.logger.Info(fmt.Sprintf("Unregistering collection: %s", name),
+		zap.Bool("deleteDurable", options.DeleteDurable),
+		zap.Bool("deleteData", options.DeleteData),
+	)
+
+	// Stop consuming events of all partitions
+	err := d.watcher.UnregisterCollection(name, options.DeleteDurable)
+	if err != nil {
+		return err
+	}
+
+	if !options.DeleteData {
+		return nil
+	}
+
+	return d.deleteStore(name)
+}
+
 func (d *Snapshot) Run() error {
 
+	err := d.initializeStore()
+	if err != nil {
+		return err
+	}
+
+	d.watcher.SetCollectionInitializer(d.initializeCollection)
 	d.watcher.Watch(func(collection string, partition uint64, msg *nats.Msg) {
 
 		meta, err := msg.Metadata()
@@ -109,10 +421,312 @@ func (d *Snapshot) Run() error {
 			return
 		}
 
-		// take snapshot
-		d.eventstore.TakeSnapshot(d.store, meta.Sequence.Consumer, msg.Data)
+		_, span := tracing.Tracer().Start(tracing.Extract(context.Background(), msg), "collection.event",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("collection", collection),
+				attribute.Int64("partition", int64(partition)),
+				attribute.Int64("sequence", int64(meta.Sequence.Stream)),
+			),
+		)
+		defer span.End()
+
+		d.metrics.EventsConsumed.WithLabelValues(collection, strconv.FormatUint(partition, 10)).Inc()
+
+		// Pausing while connection is unavailable, event is delivered again if shutting down
+		if !d.pipeline.Wait(d.closed) {
+			msg.Nak()
+			return
+		}
+
+		// Pausing when backup is in progress
+		d.applyMutex.RLock()
+		defer d.applyMutex.RUnlock()
+
+		cs, err := d.getStore(collection)
+		if err != nil {
+			d.logger.Error(err.Error(), zap.String("collection", collection))
+			msg.Nak()
+			return
+		}
+
+		// Event was delivered again before it was applied, it will be acknowledged by workers
+		if cs.isPending(meta.Sequence.Stream) {
+			return
+		}
+
+		// take snapshot, event is acknowledged by workers after it was applied
+		cs.dispatch(meta.Sequence.Stream, &pendingEvent{
+			partition:   partition,
+			timestamp:   meta.Timestamp,
+			spanContext: span.SpanContext(),
+			msg:         msg,
+		})
+		err = d.takeSnapshot(cs, partition, meta.Sequence.Stream, msg.Data)
+		if err != nil {
+			cs.takePendingEvent(meta.Sequence.Stream)
+			cs.decreasePending()
+			span.RecordError(err)
+			d.logger.Error(err.Error(), zap.String("collection", collection))
+			msg.Nak()
+		}
+
+	})
+
+	go d.runProgressMonitor()
+	go d.runPositionFlusher()
+
+	return nil
+}
+
+func (d *Snapshot) initializeCollection(collection *Collection) error {
+
+	cs, err := d.getStore(collection.GetName())
+	if err != nil {
+		return err
+	}
+
+	// Snapshot was seeded, so consumers should resume from the matching stream sequence
+	revision, err := GetSeedRevision(cs.store)
+	if err != nil {
+		return err
+	}
+
+	if revision > 0 {
+
+		// Consumers which exist already are recreated once for every seed revision
+		seeded, err := LoadSeededPartitions(cs.store)
+		if err != nil {
+			return err
+		}
+
+		for _, partition := range collection.GetPartitions() {
+
+			if seeded[partition] == revision {
+				continue
+			}
+
+			d.logger.Info("Partition was seeded",
+				zap.String("collection", collection.GetName()),
+				zap.Uint64("partition", partition),
+				zap.Uint64("revision", revision),
+			)
+			collection.ResetPartition(partition, revision+1)
+		}
+	}
+
+	// Datastore was restored from backup, consumers should be recreated from positions of backup
+	restoreID, err := GetRestoreID(cs.store)
+	if err != nil {
+		return err
+	}
+
+	if restoreID > 0 {
+
+		positions, err := LoadRestorePositions(cs.store)
+		if err != nil {
+			return err
+		}
+
+		restored, err := LoadRestoredPartitions(cs.store)
+		if err != nil {
+			return err
+		}
+
+		for _, partition := range collection.GetPartitions() {
+
+			if restored[partition] == restoreID {
+				continue
+			}
+
+			// Nothing of partition was applied before backup was taken, so it starts from the first event
+			seq := positions[partition]
 
+			d.logger.Info("Partition was restored",
+				zap.String("collection", collection.GetName()),
+				zap.Uint64("partition", partition),
+				zap.Uint64("position", seq),
+			)
+			collection.ResetPartition(partition, seq+1)
+		}
+	}
+
+	collection.SetResetHandler(func(c *Collection, partition uint64) {
+
+		if restoreID > 0 {
+			err := setRestoredPartition(cs.store, partition, restoreID)
+			if err != nil {
+				d.logger.Error(err.Error())
+			}
+		}
+
+		if revision > 0 {
+			err := setSeededPartition(cs.store, partition, revision)
+			if err != nil {
+				d.logger.Error(err.Error())
+			}
+		}
 	})
 
 	return nil
 }
+
+func (d *Snapshot) Stop(ctx context.Context) error {
+
+	timeout := time.Duration(d.config.Snapshot.ShutdownTimeout) * time.Second
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	d.logger.Info("Shutting down snapshot...",
+		zap.Duration("timeout", timeout),
+	)
+
+	close(d.closed)
+
+	// Stop receiving events from all collections
+	d.watcher.Stop()
+	err := d.watcher.Drain(ctx)
+	if err != nil {
+		d.logger.Warn(err.Error())
+	}
+
+	// Waiting for workers to apply pending events
+	err = d.waitForPendings(ctx)
+	if err != nil {
+		d.logger.Warn(err.Error())
+
+		d.storeMutex.Lock()
+		for _, cs := range d.stores {
+			pending := cs.GetPendingCount()
+			if pending == 0 {
+				continue
+			}
+
+			d.logger.Warn("Events are still pending on shutdown",
+				zap.String("collection", cs.name),
+				zap.Int64("pending", pending),
+			)
+		}
+		d.storeMutex.Unlock()
+	}
+
+	if d.eventstore == nil {
+		return nil
+	}
+
+	// Flush and close all stores
+	d.logger.Info("Closing store...")
+	d.storeMutex.Lock()
+	for _, cs := range d.stores {
+		cs.closeWorkers()
+	}
+
+	// Events of collections without dedicated workers are applied before closing store
+	d.workers.close()
+
+	for _, cs := range d.stores {
+		err := cs.SavePositions()
+		if err != nil {
+			d.logger.Error(err.Error(), zap.String("collection", cs.name))
+		}
+	}
+
+	d.eventstore.Close()
+	d.eventstore = nil
+	d.stores = make(map[string]*CollectionStore)
+	d.storeMutex.Unlock()
+
+	return nil
+}
+
+// GetCollections returns all collections which are being watched
+func (d *Snapshot) GetCollections() []*Collection {
+	return d.watcher.GetCollections()
+}
+
+// SetPartitionFilter specifies function to decide whether partition of collection is owned by this instance, consumers
+// of partitions are shared by instances, so partition continues from data of local store whenever it was acquired
+func (d *Snapshot) SetPartitionFilter(fn func(string, uint64) bool) {
+	d.watcher.SetPartitionFilter(fn)
+	d.watcher.SetAcquireHandler(d.acquirePartition)
+}
+
+// RefreshPartitions starts or stops consuming partitions after assignment was changed, it returns partitions of
+// collections which are no longer consumed
+func (d *Snapshot) RefreshPartitions() map[string][]uint64 {
+	return d.watcher.Refresh()
+}
+
+// IsStoreOpened returns true if store is ready for applying events
+func (d *Snapshot) IsStoreOpened() bool {
+
+	d.storeMutex.Lock()
+	defer d.storeMutex.Unlock()
+
+	return d.eventstore != nil
+}
+
+// GetConsumerLags returns number of events which are not yet applied for each collection, including events which are
+// not delivered, not acknowledged and queued for workers. Lag is taken from the last sampling of progress.
+func (d *Snapshot) GetConsumerLags() (map[string]uint64, error) {
+
+	lags := make(map[string]uint64)
+	for _, c := range d.watcher.GetCollections() {
+
+		infos, err := c.GetSampledConsumerInfos()
+		if err != nil {
+			return nil, err
+		}
+
+		cs := d.lookupStore(c.GetName())
+		if cs == nil {
+			return nil, fmt.Errorf("Store of collection %s is not opened", c.GetName())
+		}
+
+		lags[c.GetName()] = getLag(infos, cs)
+	}
+
+	return lags, nil
+}
+
+func (d *Snapshot) getPendingCount() int64 {
+
+	d.storeMutex.Lock()
+	defer d.storeMutex.Unlock()
+
+	pending := int64(0)
+	for _, cs := range d.stores {
+		pending += cs.GetPendingCount()
+	}
+
+	return pending
+}
+
+func (d *Snapshot) waitForPendings(ctx context.Context) error {
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for d.getPendingCount() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("Timeout waiting for pending events: %v", ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+func WithDurableDeletion() func(*UnregisterOptions) {
+	return func(options *UnregisterOptions) {
+		options.DeleteDurable = true
+	}
+}
+
+func WithDataDeletion() func(*UnregisterOptions) {
+	return func(options *UnregisterOptions) {
+		options.DeleteData = true
+	}
+}
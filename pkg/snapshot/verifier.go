@@ -0,0 +1,507 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	gravity_sdk_types_snapshot_record "github.com/BrobridgeOrg/gravity-sdk/types/snapshot_record"
+	"github.com/cockroachdb/pebble"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const DefaultMaxDifferences = 1000
+
+type FieldDifference struct {
+	Name     string      `json:"name"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+}
+
+type RecordDifference struct {
+	Table  string             `json:"table"`
+	Key    string             `json:"key"`
+	Fields []*FieldDifference `json:"fields,omitempty"`
+}
+
+type VerificationReport struct {
+	Collection     string              `json:"collection"`
+	Positions      map[uint64]uint64   `json:"positions"`
+	ReplayedEvents int                 `json:"replayedEvents"`
+	ComparedCount  int                 `json:"comparedCount"`
+	MissingCount   int                 `json:"missingCount"`
+	ExtraCount     int                 `json:"extraCount"`
+	DifferentCount int                 `json:"differentCount"`
+	Missing        []*RecordDifference `json:"missing"`
+	Extra          []*RecordDifference `json:"extra"`
+	Different      []*RecordDifference `json:"different"`
+	Incomplete     bool                `json:"incomplete"`
+	Warnings       []string            `json:"warnings,omitempty"`
+
+	maxDifferences int
+}
+
+type VerifyOptions struct {
+	MaxDifferences int
+}
+
+func NewVerificationReport(collection string, maxDifferences int) *VerificationReport {
+	return &VerificationReport{
+		Collection:     collection,
+		Missing:        make([]*RecordDifference, 0),
+		Extra:          make([]*RecordDifference, 0),
+		Different:      make([]*RecordDifference, 0),
+		maxDifferences: maxDifferences,
+	}
+}
+
+// IsConsistent returns true if no difference was found
+func (report *VerificationReport) IsConsistent() bool {
+	return report.MissingCount == 0 && report.ExtraCount == 0 && report.DifferentCount == 0
+}
+
+// MergeVerificationReports combines reports of the same collection which were verified by different nodes
+func MergeVerificationReports(reports ...*VerificationReport) *VerificationReport {
+
+	merged := NewVerificationReport("", 0)
+	merged.Positions = make(map[uint64]uint64)
+
+	for _, report := range reports {
+
+		merged.Collection = report.Collection
+		merged.ReplayedEvents += report.ReplayedEvents
+		merged.ComparedCount += report.ComparedCount
+		merged.MissingCount += report.MissingCount
+		merged.ExtraCount += report.ExtraCount
+		merged.DifferentCount += report.DifferentCount
+		merged.Missing = append(merged.Missing, report.Missing...)
+		merged.Extra = append(merged.Extra, report.Extra...)
+		merged.Different = append(merged.Different, report.Different...)
+		merged.Incomplete = merged.Incomplete || report.Incomplete
+		merged.Warnings = append(merged.Warnings, report.Warnings...)
+
+		for partition, seq := range report.Positions {
+			if seq > merged.Positions[partition] {
+				merged.Positions[partition] = seq
+			}
+		}
+	}
+
+	return merged
+}
+
+func (report *VerificationReport) addMissing(diff *RecordDifference) {
+	report.MissingCount++
+	if len(report.Missing) < report.maxDifferences {
+		report.Missing = append(report.Missing, diff)
+	}
+}
+
+func (report *VerificationReport) addExtra(diff *RecordDifference) {
+	report.ExtraCount++
+	if len(report.Extra) < report.maxDifferences {
+		report.Extra = append(report.Extra, diff)
+	}
+}
+
+func (report *VerificationReport) addDifferent(diff *RecordDifference) {
+	report.DifferentCount++
+	if len(report.Different) < report.maxDifferences {
+		report.Different = append(report.Different, diff)
+	}
+}
+
+// Verify replays events of collection from the beginning into scratch store, then compares it with snapshot
+func (d *Snapshot) Verify(ctx context.Context, collection string, opts ...func(*VerifyOptions)) (*VerificationReport, error) {
+
+	options := &VerifyOptions{
+		MaxDifferences: DefaultMaxDifferences,
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	c := d.watcher.GetCollection(collection)
+	if c == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFoundCollection, collection)
+	}
+
+	cs, err := d.GetCollectionStore(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	report := NewVerificationReport(collection, options.MaxDifferences)
+
+	// Preparing scratch store
+	tmpDir, err := ioutil.TempDir("", "gravity-snapshot-verify")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	storeOptions := eventstore.NewOptions()
+	storeOptions.DatabasePath = tmpDir
+	storeOptions.EnabledSnapshot = true
+	storeOptions.SnapshotOptions.WorkerCount = 1
+
+	scratch, err := eventstore.CreateEventStore(storeOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+
+	scratchStore, err := scratch.GetStore(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	// Take a consistent view of snapshot with positions
+	view, positions, err := d.takeView(ctx, cs)
+	if err != nil {
+		return nil, err
+	}
+	defer view.Close()
+
+	report.Positions = positions
+
+	revision, err := GetSeedRevision(cs.store)
+	if err != nil {
+		return nil, err
+	}
+
+	if revision > 0 {
+		report.Incomplete = true
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Snapshot was seeded at revision %d, records from seed cannot be replayed", revision))
+	}
+
+	// Events which were removed by retention policy of stream cannot be replayed
+	js, err := d.connector.GetJetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := js.StreamInfo(c.getStreamName())
+	if err != nil {
+		return nil, err
+	}
+
+	if stream.State.FirstSeq > 1 {
+		report.Incomplete = true
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Events before sequence %d were removed from stream", stream.State.FirstSeq))
+	}
+
+	d.logger.Info("Verifying collection...",
+		zap.String("collection", collection),
+	)
+
+	// Replay events of all partitions
+	for _, partition := range c.partitions {
+
+		target, ok := positions[partition]
+		if !ok || target == 0 {
+			continue
+		}
+
+		count, err := d.replay(ctx, js, c, partition, target, scratchStore, cs.options)
+		if err != nil {
+			return nil, err
+		}
+
+		report.ReplayedEvents += count
+	}
+
+	// Compare records
+	scratchCF, err := scratchStore.GetColumnFamailyHandle("snapshot")
+	if err != nil {
+		return nil, err
+	}
+
+	scratchView := scratchCF.Db.NewSnapshot()
+	defer scratchView.Close()
+
+	tables, err := getTables(cs.store, scratchStore)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		err := compareTable(table, NewTableKeyFilter(table, tables), scratchView, view, report)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d.logger.Info("Verification completed",
+		zap.String("collection", collection),
+		zap.Int("replayed", report.ReplayedEvents),
+		zap.Int("compared", report.ComparedCount),
+		zap.Int("missing", report.MissingCount),
+		zap.Int("extra", report.ExtraCount),
+		zap.Int("different", report.DifferentCount),
+	)
+
+	return report, nil
+}
+
+func (d *Snapshot) takeView(ctx context.Context, cs *CollectionStore) (*pebble.Snapshot, map[uint64]uint64, error) {
+
+	// Pausing to apply new events
+	d.applyMutex.Lock()
+	defer d.applyMutex.Unlock()
+
+	err := d.waitForPendings(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cf, err := cs.store.GetColumnFamailyHandle("snapshot")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cf.Db.NewSnapshot(), cs.GetPositions(), nil
+}
+
+func (d *Snapshot) replay(ctx context.Context, js nats.JetStreamContext, c *Collection, partition uint64, target uint64, store *eventstore.Store, options *ApplyOptions) (int, error) {
+
+	subject := fmt.Sprintf("%s.%d.EVENT.*", c.getSubjectPrefix(), partition)
+
+	// Events of partition were removed from stream, there is nothing to wait for
+	last, err := getLastMsg(js, c.getStreamName(), subject)
+	if err == nats.ErrMsgNotFound {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if last.Sequence < target {
+		target = last.Sequence
+	}
+
+	sub, err := js.SubscribeSync(subject, nats.OrderedConsumer(), nats.DeliverAll())
+	if err != nil {
+		return 0, err
+	}
+	defer sub.Unsubscribe()
+
+	count := 0
+	for {
+
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return count, err
+		}
+
+		meta, err := msg.Metadata()
+		if err != nil {
+			return count, err
+		}
+
+		if meta.Sequence.Stream > target {
+			return count, nil
+		}
+
+		err = d.handler.Apply(store, meta.Sequence.Stream, msg.Data, options)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+
+		if meta.Sequence.Stream >= target || meta.NumPending == 0 {
+			return count, nil
+		}
+	}
+}
+
+func getTables(stores ...*eventstore.Store) ([]string, error) {
+
+	tables := make(map[string]struct{})
+	for _, store := range stores {
+
+		cf, err := store.GetColumnFamailyHandle("snapshot_states")
+		if err != nil {
+			return nil, err
+		}
+
+		iter := cf.Db.NewIter(nil)
+		for iter.First(); iter.Valid(); iter.Next() {
+			key := string(iter.Key())
+			if strings.HasSuffix(key, "-seq") {
+				tables[strings.TrimSuffix(key, "-seq")] = struct{}{}
+			}
+		}
+		iter.Close()
+	}
+
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// getLastMsg returns the last message of subject in stream, it is implemented by JetStream context
+// although it is not a part of interface in this version of client
+func getLastMsg(js nats.JetStreamContext, streamName string, subject string) (*nats.RawStreamMsg, error) {
+
+	getter, ok := js.(interface {
+		GetLastMsg(string, string, ...nats.JSOpt) (*nats.RawStreamMsg, error)
+	})
+	if !ok {
+		return nil, errors.New("Getting the last message is not supported")
+	}
+
+	return getter.GetLastMsg(streamName, subject)
+}
+
+func compareTable(table string, filter func([]byte) bool, expected *pebble.Snapshot, actual *pebble.Snapshot, report *VerificationReport) error {
+
+	prefix := []byte(table + "-")
+	upperBound := make([]byte, len(prefix))
+	copy(upperBound, prefix)
+	upperBound[len(upperBound)-1]++
+
+	options := &pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: upperBound,
+	}
+
+	expectedIter := expected.NewIter(options)
+	defer expectedIter.Close()
+
+	actualIter := actual.NewIter(options)
+	defer actualIter.Close()
+
+	expectedIter.First()
+	actualIter.First()
+
+	for expectedIter.Valid() || actualIter.Valid() {
+
+		// Records of other tables which have the same prefix
+		if expectedIter.Valid() && !filter(expectedIter.Key()[len(prefix):]) {
+			expectedIter.Next()
+			continue
+		}
+
+		if actualIter.Valid() && !filter(actualIter.Key()[len(prefix):]) {
+			actualIter.Next()
+			continue
+		}
+
+		cmp := 0
+		switch {
+		case !actualIter.Valid():
+			cmp = -1
+		case !expectedIter.Valid():
+			cmp = 1
+		default:
+			cmp = bytes.Compare(expectedIter.Key(), actualIter.Key())
+		}
+
+		switch {
+		case cmp < 0:
+			report.addMissing(&RecordDifference{
+				Table: table,
+				Key:   FormatKey(expectedIter.Key()[len(prefix):]),
+			})
+			expectedIter.Next()
+		case cmp > 0:
+			report.addExtra(&RecordDifference{
+				Table: table,
+				Key:   FormatKey(actualIter.Key()[len(prefix):]),
+			})
+			actualIter.Next()
+		default:
+			report.ComparedCount++
+
+			fields, err := compareRecords(expectedIter.Value(), actualIter.Value())
+			if err != nil {
+				return err
+			}
+
+			if len(fields) > 0 {
+				report.addDifferent(&RecordDifference{
+					Table:  table,
+					Key:    FormatKey(expectedIter.Key()[len(prefix):]),
+					Fields: fields,
+				})
+			}
+
+			expectedIter.Next()
+			actualIter.Next()
+		}
+	}
+
+	return nil
+}
+
+func compareRecords(expectedData []byte, actualData []byte) ([]*FieldDifference, error) {
+
+	expected := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+	err := gravity_sdk_types_snapshot_record.Unmarshal(expectedData, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	actual := &gravity_sdk_types_snapshot_record.SnapshotRecord{}
+	err = gravity_sdk_types_snapshot_record.Unmarshal(actualData, actual)
+	if err != nil {
+		return nil, err
+	}
+
+	expectedFields, _ := GetValue(expected.Payload).(map[string]interface{})
+	actualFields, _ := GetValue(actual.Payload).(map[string]interface{})
+
+	names := make(map[string]struct{})
+	for name := range expectedFields {
+		names[name] = struct{}{}
+	}
+
+	for name := range actualFields {
+		names[name] = struct{}{}
+	}
+
+	diffs := make([]*FieldDifference, 0)
+	for name := range names {
+
+		e, eok := expectedFields[name]
+		a, aok := actualFields[name]
+		if eok == aok && reflect.DeepEqual(e, a) {
+			continue
+		}
+
+		diffs = append(diffs, &FieldDifference{
+			Name:     name,
+			Expected: e,
+			Actual:   a,
+		})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Name < diffs[j].Name
+	})
+
+	return diffs, nil
+}
+
+func WithMaxDifferences(max int) func(*VerifyOptions) {
+	return func(options *VerifyOptions) {
+		options.MaxDifferences = max
+	}
+}
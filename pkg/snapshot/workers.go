@@ -0,0 +1,117 @@
+package snapshot
+
+import (
+	"errors"
+	"sync"
+
+	eventstore "github.com/BrobridgeOrg/EventStore"
+	"go.uber.org/zap"
+)
+
+var ErrWorkersClosed = errors.New("Workers were closed")
+
+// workerPool applies events in parallel, events with the same key are applied in order
+type workerPool struct {
+	queues []chan *eventstore.SnapshotRequest
+	wg     sync.WaitGroup
+	closed bool
+	mutex  sync.RWMutex
+}
+
+func newWorkerPool(count int, bufferSize int, fn func(*eventstore.SnapshotRequest)) *workerPool {
+
+	wp := &workerPool{
+		queues: make([]chan *eventstore.SnapshotRequest, count),
+	}
+
+	for i := range wp.queues {
+		queue := make(chan *eventstore.SnapshotRequest, bufferSize)
+		wp.queues[i] = queue
+
+		wp.wg.Add(1)
+		go func() {
+			defer wp.wg.Done()
+			for request := range queue {
+				fn(request)
+			}
+		}()
+	}
+
+	return wp
+}
+
+// push queues event for worker of key, it fails if workers were closed
+func (wp *workerPool) push(key uint64, request *eventstore.SnapshotRequest) error {
+
+	wp.mutex.RLock()
+	defer wp.mutex.RUnlock()
+
+	if wp.closed {
+		return ErrWorkersClosed
+	}
+
+	wp.queues[key%uint64(len(wp.queues))] <- request
+
+	return nil
+}
+
+// close stops workers after all queued events were applied
+func (wp *workerPool) close() {
+
+	wp.mutex.Lock()
+	if wp.closed {
+		wp.mutex.Unlock()
+		return
+	}
+
+	wp.closed = true
+	for _, queue := range wp.queues {
+		close(queue)
+	}
+	wp.mutex.Unlock()
+
+	wp.wg.Wait()
+}
+
+// closeWorkers stops workers of collection, events which are dispatched later are rejected
+func (cs *CollectionStore) closeWorkers() {
+
+	if cs.workers == nil {
+		return
+	}
+
+	cs.workers.close()
+}
+
+// takeSnapshot dispatches event to workers of collection by partition, or to shared workers by collection if collection
+// has no dedicated workers
+func (d *Snapshot) takeSnapshot(cs *CollectionStore, partition uint64, seq uint64, data []byte) error {
+
+	d.storeMutex.Lock()
+	opened := d.eventstore != nil
+	d.storeMutex.Unlock()
+
+	// Workers were closed with store, so event should not be dispatched
+	if !opened {
+		return ErrStoreClosed
+	}
+
+	request := eventstore.NewSnapshotRequest()
+	request.Store = cs.store
+	request.Sequence = seq
+	request.Data = data
+
+	if cs.workers == nil {
+		return d.workers.push(cs.key, request)
+	}
+
+	return cs.workers.push(partition, request)
+}
+
+func (d *Snapshot) handleWorkerRequest(request *eventstore.SnapshotRequest) {
+
+	err := d.apply(request)
+	if err != nil {
+		d.logger.Error(err.Error(), zap.Uint64("sequence", request.Sequence))
+	}
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"net/textproto"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+)
+
+// HeaderCarrier adapts headers of NATS message to carry trace context
+type HeaderCarrier nats.Header
+
+func (hc HeaderCarrier) Get(key string) string {
+
+	if v, ok := hc[key]; ok && len(v) > 0 {
+		return v[0]
+	}
+
+	// Headers might be canonicalized by publisher
+	if v, ok := hc[textproto.CanonicalMIMEHeaderKey(key)]; ok && len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
+
+func (hc HeaderCarrier) Set(key string, value string) {
+	hc[key] = []string{value}
+}
+
+func (hc HeaderCarrier) Keys() []string {
+
+	keys := make([]string, 0, len(hc))
+	for k := range hc {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// Extract returns context with trace context which is carried by message
+func Extract(ctx context.Context, msg *nats.Msg) context.Context {
+
+	if msg.Header == nil {
+		return ctx
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(msg.Header))
+}
+
+// Inject writes trace context to headers of message
+func Inject(ctx context.Context, msg *nats.Msg) {
+
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(msg.Header))
+}
+
+// NewMsg creates message which carries trace context, it is used for publishing to streams of views
+func NewMsg(ctx context.Context, subject string, data []byte) *nats.Msg {
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	Inject(ctx, msg)
+
+	return msg
+}
@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+const (
+	ServiceName = "gravity-snapshot"
+
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+type Tracing struct {
+	config   *configs.TracingConfig
+	provider *sdktrace.TracerProvider
+	file     io.Closer
+}
+
+func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger) *Tracing {
+
+	logger = l.Named("Tracing")
+
+	t := &Tracing{
+		config: &config.Tracing,
+	}
+
+	// Trace context will be propagated even though tracing is disabled
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	lifecycle.Append(
+		fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				return t.initialize(ctx)
+			},
+			OnStop: func(ctx context.Context) error {
+
+				if t.provider == nil {
+					return nil
+				}
+
+				// Flush spans which are still buffered
+				err := t.provider.Shutdown(ctx)
+				if err != nil {
+					logger.Warn(err.Error())
+				}
+
+				if t.file != nil {
+					t.file.Close()
+				}
+
+				return nil
+			},
+		},
+	)
+
+	return t
+}
+
+func (t *Tracing) initialize(ctx context.Context) error {
+
+	if !t.config.Enabled {
+		return nil
+	}
+
+	exporterName := t.config.Exporter
+	exporter, err := t.createExporter(ctx, exporterName)
+	if err != nil {
+		return err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(ServiceName),
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	sampleRatio := t.config.SampleRatio
+
+	logger.Info("Initializing tracing",
+		zap.String("exporter", exporterName),
+		zap.Float64("sampleRatio", sampleRatio),
+	)
+
+	t.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	otel.SetTracerProvider(t.provider)
+
+	return nil
+}
+
+func (t *Tracing) createExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+
+	switch name {
+	case ExporterOTLP:
+
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(t.config.Endpoint),
+		}
+
+		if t.config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		return otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+
+		f, err := os.OpenFile(t.config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		t.file = f
+
+		return stdouttrace.New(stdouttrace.WithWriter(f))
+	}
+
+	return nil, fmt.Errorf("Unsupported tracing exporter: %s", name)
+}
+
+// Tracer returns tracer of component, it does nothing if tracing is disabled
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
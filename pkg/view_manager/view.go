@@ -1,31 +1,100 @@
 package view_manager
 
 import (
+	"context"
+	"encoding/base64"
+	"sync/atomic"
 	"time"
+
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type View struct {
 	vm *ViewManager
 
-	ID         string
-	Subscriber string
-	Collection string
-	CreatedAt  time.Time
+	ID         string    `json:"id"`
+	Subscriber string    `json:"subscriber"`
+	Collection string    `json:"collection"`
+	CreatedAt  time.Time `json:"createdAt"`
+
+	// Unix time in nanoseconds of the last access by this instance
+	accessedAt int64
 }
 
 func NewView() *View {
+
+	now := time.Now()
+
 	return &View{
-		CreatedAt: time.Now(),
+		CreatedAt:  now,
+		accessedAt: now.UnixNano(),
 	}
 }
 
-func (view *View) Fetch(lastKey []byte, afterLastKey bool) (int, error) {
+func (view *View) touch() {
+	atomic.StoreInt64(&view.accessedAt, time.Now().UnixNano())
+}
+
+// GetAccessedAt returns time when view was accessed last time
+func (view *View) GetAccessedAt() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&view.accessedAt))
+}
+
+// Fetch pushes a batch of records after the last key to stream of view, it returns number of records which were pushed.
+// Every message carries trace context of request, table and key of record in headers.
+func (view *View) Fetch(ctx context.Context, lastKey []byte, afterLastKey bool) (int, error) {
 
 	err := view.vm.assertStream(view.ID)
 	if err != nil {
 		return 0, err
 	}
 
-	// TODO: Fetch data from store and push it to stream of view
-	return 0, nil
+	// Snapshot should be up to date before the first batch
+	if timeout := view.vm.config.GetViewConfig().CaughtUpTimeout; len(lastKey) == 0 && timeout > 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		err := view.vm.snapshot.WaitForCaughtUp(waitCtx, view.Collection)
+		cancel()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	cs, err := view.vm.snapshot.GetCollectionStore(view.Collection)
+	if err != nil {
+		return 0, err
+	}
+
+	js, err := view.vm.connector.GetJetStream()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "view.fetch", trace.WithAttributes(
+		attribute.String("view", view.ID),
+		attribute.String("collection", view.Collection),
+	))
+	defer span.End()
+
+	subject := GetSubject(view.vm.connector.GetDomain(), view.ID)
+	count, err := snapshot.ScanRecords(cs.GetStore(), lastKey, afterLastKey, view.vm.config.GetViewConfig().BatchSize, func(table string, key []byte, data []byte) error {
+
+		msg := tracing.NewMsg(ctx, subject, data)
+		msg.Header.Set(HeaderTable, table)
+		msg.Header.Set(HeaderKey, base64.StdEncoding.EncodeToString(key))
+
+		_, err := js.PublishMsg(msg)
+
+		return err
+	})
+	if err != nil {
+		span.RecordError(err)
+		return count, err
+	}
+
+	span.SetAttributes(attribute.Int("count", count))
+
+	return count, nil
 }
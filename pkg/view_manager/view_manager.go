@@ -1,42 +1,202 @@
 package view_manager
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/configs"
 	"github.com/BrobridgeOrg/gravity-snapshot/pkg/connector"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/metrics"
+	"github.com/BrobridgeOrg/gravity-snapshot/pkg/snapshot"
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
 var logger *zap.Logger
 
+// Interval in seconds of checking views which were expired
+const DefaultExpiryInterval = 10
+
 type ViewManager struct {
 	config    *configs.Config
 	connector *connector.Connector
+	snapshot  *snapshot.Snapshot
 	views     map[string]*View
+	store     nats.KeyValue
+	closed    chan struct{}
+	mutex     sync.RWMutex
 }
 
-func New(config *configs.Config, l *zap.Logger, c *connector.Connector) *ViewManager {
+func New(lifecycle fx.Lifecycle, config *configs.Config, l *zap.Logger, c *connector.Connector, s *snapshot.Snapshot, m *metrics.Registry) *ViewManager {
 
 	logger = l.Named("ViewManager")
 
 	vm := &ViewManager{
 		config:    config,
 		connector: c,
+		snapshot:  s,
 		views:     make(map[string]*View),
+		closed:    make(chan struct{}),
 	}
 
+	// Streams of views are created on demand
+	domain := c.GetDomain()
+	c.RequireKeyValue(GetBucketName(domain))
+	c.RequirePublish(
+		fmt.Sprintf("$JS.API.STREAM.INFO.%s", GetStreamName(domain, connector.PermissionProbe)),
+		fmt.Sprintf("$JS.API.STREAM.CREATE.%s", GetStreamName(domain, connector.PermissionProbe)),
+		GetSubject(domain, connector.PermissionProbe),
+	)
+
+	err := m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "active_views",
+		Help:      "Number of active snapshot views",
+	}, func() float64 {
+		return float64(vm.GetViewCount())
+	}))
+	if err != nil {
+		logger.Warn(err.Error())
+	}
+
+	lifecycle.Append(
+		fx.Hook{
+			OnStart: func(context.Context) error {
+				go vm.runExpiry()
+				go vm.watchViews()
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				close(vm.closed)
+				return nil
+			},
+		},
+	)
+
 	return vm
 }
 
+// runExpiry deletes views which were not accessed within TTL, TTL can be changed by reloading config
+func (vm *ViewManager) runExpiry() {
+
+	ticker := time.NewTicker(DefaultExpiryInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			vm.deleteExpiredViews()
+		case <-vm.closed:
+			return
+		}
+	}
+}
+
+// watchViews drops views from cache after they were deleted by any instance, so deleted views are not served anymore
+func (vm *ViewManager) watchViews() {
+
+	store, err := vm.getStore()
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	watcher, err := store.WatchAll()
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case entry, ok := <-watcher.Updates():
+			if !ok {
+				return
+			}
+
+			// Views are never updated after they were created
+			if entry == nil || entry.Operation() == nats.KeyValuePut {
+				continue
+			}
+
+			vm.mutex.Lock()
+			delete(vm.views, entry.Key())
+			vm.mutex.Unlock()
+		case <-vm.closed:
+			return
+		}
+	}
+}
+
+func (vm *ViewManager) deleteExpiredViews() {
+
+	ttl := time.Duration(vm.config.GetViewConfig().TTL) * time.Second
+
+	// Views never expire
+	if ttl == 0 {
+		return
+	}
+
+	expired := make([]string, 0)
+
+	vm.mutex.RLock()
+	for id, v := range vm.views {
+		if time.Since(v.GetAccessedAt()) > ttl {
+			expired = append(expired, id)
+		}
+	}
+	vm.mutex.RUnlock()
+
+	for _, id := range expired {
+
+		logger.Info("Deleting expired view", zap.String("view", id), zap.Duration("ttl", ttl))
+
+		err := vm.DeleteView(id)
+		if err != nil {
+			logger.Warn(err.Error(), zap.String("view", id))
+		}
+	}
+}
+
+// GetBucketName returns name of bucket which keeps views
+func GetBucketName(domain string) string {
+	return fmt.Sprintf("GRAVITY_%s_SNAPSHOT_VIEWS", domain)
+}
+
+// GetStreamName returns name of stream which keeps data of view
+func GetStreamName(domain string, viewID string) string {
+	return fmt.Sprintf("GRAVITY_%s_SNAPSHOT_VIEW_%s", domain, viewID)
+}
+
+// getLegacyStreamName returns name of stream which kept data of view before streams were renamed
+func getLegacyStreamName(domain string, viewID string) string {
+	return fmt.Sprintf("GRAVITY.%s.SNAPSHOT.VIEW.%s", domain, viewID)
+}
+
+// Headers of messages which are published to stream of view
+const (
+	HeaderTable = "Gravity-Snapshot-Table"
+	HeaderKey   = "Gravity-Snapshot-Key"
+)
+
+// GetSubject returns subject which data of view is published to
+func GetSubject(domain string, viewID string) string {
+	return fmt.Sprintf("GRAVITY.%s.SNAPSHOT.VIEW.%s", domain, viewID)
+}
+
 func (vm *ViewManager) assertStream(viewID string) error {
 
-	streamName := fmt.Sprintf("GRAVITY.%s.SNAPSHOT.VIEW.%s", vm.connector.GetDomain(), viewID)
+	streamName := GetStreamName(vm.connector.GetDomain(), viewID)
 
 	// Preparing JetStream
-	nc := vm.connector.GetClient().GetConnection()
+	nc := vm.connector.GetConnection()
 	js, err := nc.JetStream()
 	if err != nil {
 		return err
@@ -48,10 +208,18 @@ func (vm *ViewManager) assertStream(viewID string) error {
 		logger.Warn(err.Error())
 	}
 
+	// Stream of legacy name captures subject of view, it should be deleted before view is pulled again
+	if err == nats.ErrStreamNotFound {
+		legacyName := getLegacyStreamName(vm.connector.GetDomain(), viewID)
+		if snapshot.HasStream(js, legacyName) {
+			return fmt.Errorf("Found stream of legacy name: %s should be deleted", legacyName)
+		}
+	}
+
 	// New stream
 	if stream == nil {
 
-		subject := fmt.Sprintf("%s", streamName)
+		subject := GetSubject(vm.connector.GetDomain(), viewID)
 
 		// Initializing stream
 		logger.Info("Creating stream for snapshot view...",
@@ -75,9 +243,45 @@ func (vm *ViewManager) assertStream(viewID string) error {
 	return nil
 }
 
+// getStore returns bucket which keeps views, so views are still available after failover or restart
+func (vm *ViewManager) getStore() (nats.KeyValue, error) {
+
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+
+	if vm.store != nil {
+		return vm.store, nil
+	}
+
+	js, err := vm.connector.GetJetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := GetBucketName(vm.connector.GetDomain())
+	kv, err := js.KeyValue(bucket)
+	if err == nats.ErrBucketNotFound {
+		logger.Info("Creating bucket for snapshot views...", zap.String("bucket", bucket))
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Gravity snapshot views",
+			History:     1,
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	vm.store = kv
+
+	return kv, nil
+}
+
 func (vm *ViewManager) CreateView(opts ...func(*ViewManager, *View)) (*View, error) {
 
 	view := NewView()
+	view.vm = vm
 
 	// Generate view ID
 	id, _ := uuid.NewUUID()
@@ -87,33 +291,92 @@ func (vm *ViewManager) CreateView(opts ...func(*ViewManager, *View)) (*View, err
 		opt(vm, view)
 	}
 
-	// TODO: Register on distributed data store
+	// Register on distributed data store
+	store, err := vm.getStore()
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.Marshal(view)
+	_, err = store.Put(view.ID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	vm.mutex.Lock()
 	vm.views[id.String()] = view
+	vm.mutex.Unlock()
 
 	return view, nil
 }
 
 func (vm *ViewManager) DeleteView(id string) error {
 
-	// TODO: delete from distributed data store
+	vm.mutex.Lock()
 	delete(vm.views, id)
+	vm.mutex.Unlock()
+
+	store, err := vm.getStore()
+	if err != nil {
+		return err
+	}
+
+	err = store.Delete(id)
+	if err != nil && err != nats.ErrInvalidKey {
+		return err
+	}
 
 	return nil
 }
 
 func (vm *ViewManager) GetView(id string) (*View, error) {
 
-	// TODO: get from distributed data store
+	vm.mutex.RLock()
 	v, ok := vm.views[id]
-	if !ok {
+	vm.mutex.RUnlock()
+	if ok {
+		v.touch()
+		return v, nil
+	}
+
+	// View might be created by another instance
+	store, err := vm.getStore()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := store.Get(id)
+	if err == nats.ErrKeyNotFound || err == nats.ErrInvalidKey {
 		return nil, nil
 	}
 
-	//return nil, errors.New(fmt.Sprintf("No such view: %s", id))
+	if err != nil {
+		return nil, err
+	}
+
+	v = NewView()
+	err = json.Unmarshal(entry.Value(), v)
+	if err != nil {
+		return nil, err
+	}
+
+	v.vm = vm
+
+	vm.mutex.Lock()
+	vm.views[id] = v
+	vm.mutex.Unlock()
 
 	return v, nil
 }
 
+func (vm *ViewManager) GetViewCount() int {
+
+	vm.mutex.RLock()
+	defer vm.mutex.RUnlock()
+
+	return len(vm.views)
+}
+
 func WithSubscriber(subscriberID string) func(vm *ViewManager, view *View) {
 	return func(vm *ViewManager, view *View) {
 		view.Subscriber = subscriberID